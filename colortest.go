@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dpup/logista/internal/formatter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// colorTestCmd shows which colors and styles the terminal can display, to
+// help diagnose colors that don't look right
+var colorTestCmd = &cobra.Command{
+	Use:   "colortest",
+	Short: "Show the colors and styles your terminal supports",
+	Long: `Colortest prints the terminal settings logista detects, followed by
+swatches of the named colors, text styles, the 256-color palette and a
+truecolor gradient, so you can see what your terminal displays.`,
+	Args: cobra.NoArgs,
+	RunE: runColorTest,
+}
+
+func init() { //nolint:gochecknoinits // Required for cobra command initialization
+	rootCmd.AddCommand(colorTestCmd)
+}
+
+// runColorTest writes the detected terminal settings and the color swatches
+func runColorTest(cmd *cobra.Command, _ []string) error {
+	w := cmd.OutOrStdout()
+
+	term, colorTerm := os.Getenv("TERM"), os.Getenv("COLORTERM")
+	depth := viper.GetString(keyColorDepth)
+	source := "set with --" + keyColorDepth
+	if depth == formatter.ColorDepthAuto {
+		depth = formatter.DetectColorDepth(term, colorTerm)
+		source = "detected from TERM and COLORTERM"
+	}
+
+	fmt.Fprintln(w, "Terminal")
+	fmt.Fprintf(w, "  TERM:        %s\n", envValue(term))
+	fmt.Fprintf(w, "  COLORTERM:   %s\n", envValue(colorTerm))
+	fmt.Fprintf(w, "  NO_COLOR:    %s\n", envValue(os.Getenv("NO_COLOR")))
+	fmt.Fprintf(w, "  stdout:      %s\n", terminalStatus(os.Stdout))
+	fmt.Fprintf(w, "  Color depth: %s (%s)\n", depth, source)
+	if !stdoutSupportsANSI() {
+		fmt.Fprintln(w, "  This console can't show colors, so logista writes plain output to it")
+	}
+	if noColorEnv() && !viper.IsSet(keyNoColors) {
+		fmt.Fprintln(w, "  NO_COLOR is set, so logista writes plain output unless --no_colors=false is given")
+	}
+	fmt.Fprintln(w)
+
+	return formatter.WriteColorTest(w, depth)
+}
+
+// envValue describes the value of an environment variable
+func envValue(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+	return value
+}
+
+// terminalStatus describes whether f is a terminal, and what that means for
+// colors
+func terminalStatus(f *os.File) string {
+	if formatter.IsTerminal(f) {
+		return "terminal"
+	}
+	return "not a terminal (colors are still written unless --no_colors is set)"
+}
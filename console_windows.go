@@ -0,0 +1,42 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing is the console mode flag that makes a
+// Windows console interpret ANSI escape sequences
+const enableVirtualTerminalProcessing = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableVirtualTerminal turns on ANSI escape sequence processing if f is a
+// Windows console, reporting whether f can show escape sequences. Consoles on
+// Windows versions before 10 can't, and neither can terminals where turning it
+// on fails. Files and pipes are written to as-is, so they report true.
+func enableVirtualTerminal(f *os.File) bool {
+	handle := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		// Not a console
+		return true
+	}
+	mode, enabled := virtualTerminalMode(mode)
+	if enabled {
+		return true
+	}
+
+	set, _, _ := setConsoleMode.Call(uintptr(handle), uintptr(mode))
+	return set != 0
+}
+
+// virtualTerminalMode returns a console mode with ANSI escape sequence
+// processing turned on, and whether mode already had it, in which case the
+// mode doesn't need to be set
+func virtualTerminalMode(mode uint32) (uint32, bool) {
+	return mode | enableVirtualTerminalProcessing, mode&enableVirtualTerminalProcessing != 0
+}
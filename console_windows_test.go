@@ -0,0 +1,40 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+func TestVirtualTerminalMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     uint32
+		expected uint32
+		enabled  bool
+	}{
+		{
+			name:     "turned on",
+			mode:     0x0001 | 0x0002,
+			expected: 0x0001 | 0x0002 | enableVirtualTerminalProcessing,
+		},
+		{
+			name:     "already on",
+			mode:     0x0001 | enableVirtualTerminalProcessing,
+			expected: 0x0001 | enableVirtualTerminalProcessing,
+			enabled:  true,
+		},
+		{
+			name:     "no other flags",
+			mode:     0,
+			expected: enableVirtualTerminalProcessing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, enabled := virtualTerminalMode(tt.mode)
+			if mode != tt.expected || enabled != tt.enabled {
+				t.Errorf("virtualTerminalMode(%#x) = %#x, %v, want %#x, %v", tt.mode, mode, enabled, tt.expected, tt.enabled)
+			}
+		})
+	}
+}
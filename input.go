@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// stdinFormat is the --format value that reads the template from stdin
+const stdinFormat = "@-"
+
+// reconnectDelay is how long to wait between attempts to reconnect to a
+// socket that has no listener
+const reconnectDelay = time.Second
+
+// openInput opens the input stream named by path, which is stdin if path is
+// empty or "-". Unix sockets are connected to rather than opened. If
+// reconnect is set and the input is a named pipe or socket, the returned
+// reader reopens it at EOF instead of ending the stream, so output resumes
+// when a producer restarts.
+func openInput(path string, reconnect bool) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		if reconnect {
+			diagnostics.Warnf("--%s requires an input file argument, ignoring it for stdin", keyReconnect)
+		}
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+
+	var open func() (io.ReadCloser, error)
+	switch mode := info.Mode(); {
+	case mode&os.ModeSocket != 0:
+		open = func() (io.ReadCloser, error) { return net.Dial("unix", path) }
+	case mode&os.ModeNamedPipe != 0:
+		open = func() (io.ReadCloser, error) { return os.Open(path) } //nolint:gosec // Reading a user-specified input is intended
+	default:
+		if reconnect {
+			diagnostics.Warnf("--%s only applies to named pipes and sockets, ignoring it for %s", keyReconnect, path)
+		}
+		file, err := os.Open(path) //nolint:gosec // Reading a user-specified input is intended
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input: %w", err)
+		}
+		return file, nil
+	}
+
+	current, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+	if !reconnect {
+		return current, nil
+	}
+	return &reconnectingReader{path: path, open: open, current: current}, nil
+}
+
+// reconnectingReader reads from a named pipe or socket, reopening it
+// whenever the producer disconnects. Reads block until a new producer
+// connects, so the stream only ends when the reader is closed.
+type reconnectingReader struct {
+	path    string
+	open    func() (io.ReadCloser, error)
+	current io.ReadCloser
+	// last is the last byte read, used to terminate a partial line when a
+	// producer disconnects mid-record
+	last byte
+}
+
+// Read reads from the current connection, reconnecting at EOF
+func (r *reconnectingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.current.Read(p)
+		if n > 0 {
+			r.last = p[n-1]
+			return n, nil
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		// Don't let the next producer's first record join a truncated one
+		if r.last != 0 && r.last != '\n' && len(p) > 0 {
+			r.last = '\n'
+			p[0] = '\n'
+			return 1, nil
+		}
+
+		if err := r.reconnect(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reconnect closes the current connection and opens a new one, retrying
+// until the input accepts it. Named pipes block in open until a writer
+// appears, while sockets are retried every reconnectDelay.
+func (r *reconnectingReader) reconnect() error {
+	_ = r.current.Close()
+	diagnostics.Infof("Input closed, waiting to reconnect: %s", r.path)
+
+	for {
+		current, err := r.open()
+		if err == nil {
+			r.current = current
+			diagnostics.Infof("Reconnected input: %s", r.path)
+			return nil
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to reopen input: %w", err)
+		}
+		time.Sleep(reconnectDelay)
+	}
+}
+
+// Close closes the current connection
+func (r *reconnectingReader) Close() error {
+	return r.current.Close()
+}
+
+// readStdinFormat replaces a --format of "@-" with the template read from
+// stdin, so a long template can be piped in while logs are read from the
+// input file argument. The template is read once, and takes precedence over
+// config files like any other --format value, so it survives reloads.
+func readStdinFormat(args []string, stdin io.Reader) error {
+	if viper.GetString(keyFormat) != stdinFormat {
+		return nil
+	}
+	if viper.GetString(keyFormatFile) != "" {
+		return fmt.Errorf("--%s %s can't be combined with --%s", keyFormat, stdinFormat, keyFormatFile)
+	}
+	if len(args) == 0 || slices.Contains(args, "-") {
+		return fmt.Errorf("--%s %s reads the template from stdin, so logs must be read from a file argument", keyFormat, stdinFormat)
+	}
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read format from stdin: %w", err)
+	}
+	viper.Set(keyFormat, strings.TrimRight(string(content), "\r\n"))
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReadStdinFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		formatFile string
+		args       []string
+		expected   string
+		wantErr    bool
+	}{
+		{
+			name:     "reads the template from stdin",
+			format:   stdinFormat,
+			args:     []string{"server.log"},
+			expected: "{level} {message}",
+		},
+		{
+			name:     "other formats are unchanged",
+			format:   "{message}",
+			expected: "{message}",
+		},
+		{
+			name:    "requires a file argument",
+			format:  stdinFormat,
+			wantErr: true,
+		},
+		{
+			name:    "logs can't also come from stdin",
+			format:  stdinFormat,
+			args:    []string{"-"},
+			wantErr: true,
+		},
+		{
+			name:       "can't be combined with a format file",
+			format:     stdinFormat,
+			formatFile: "template.tmpl",
+			args:       []string{"server.log"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(keyFormat, tt.format)
+			viper.Set(keyFormatFile, tt.formatFile)
+
+			err := readStdinFormat(tt.args, strings.NewReader("{level} {message}\n\n"))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readStdinFormat failed: %v", err)
+			}
+			if format := viper.GetString(keyFormat); format != tt.expected {
+				t.Errorf("Expected format %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Package diag writes logista's own diagnostic messages, such as warnings
+// about invalid configuration, to stderr. Messages can be silenced, or
+// written as JSON when logista runs inside another log pipeline.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Message levels
+const (
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// Logger writes leveled diagnostic messages. It is safe for concurrent use.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	quiet  bool
+	format string
+	now    func() time.Time
+}
+
+// New creates a Logger that writes text messages to w
+func New(w io.Writer) *Logger {
+	return &Logger{
+		w:      w,
+		format: FormatText,
+		now:    time.Now,
+	}
+}
+
+// SetQuiet controls whether info and warning messages are suppressed. Errors
+// are always written.
+func (l *Logger) SetQuiet(quiet bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.quiet = quiet
+}
+
+// SetFormat sets the output format to FormatText or FormatJSON
+func (l *Logger) SetFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q (expected %s or %s)", format, FormatText, FormatJSON)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.format = format
+	return nil
+}
+
+// Infof writes an informational message, such as which config file is in use
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.write(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// Warnf writes a warning about a non-fatal problem
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.write(LevelWarning, fmt.Sprintf(format, args...))
+}
+
+// Errorf writes an error message, even when the logger is quiet
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.write(LevelError, fmt.Sprintf(format, args...))
+}
+
+// write formats and writes a single message
+func (l *Logger) write(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.quiet && level != LevelError {
+		return
+	}
+
+	var line string
+	if l.format == FormatJSON {
+		encoded, err := json.Marshal(struct {
+			Time    string `json:"timestamp"`
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}{
+			Time:    l.now().Format(time.RFC3339),
+			Level:   level,
+			Message: msg,
+		})
+		if err != nil {
+			return
+		}
+		line = string(encoded)
+	} else {
+		switch level {
+		case LevelWarning:
+			line = "Warning: " + msg
+		case LevelError:
+			line = "Error: " + msg
+		default:
+			line = msg
+		}
+	}
+
+	// Diagnostics are best effort, there's nowhere to report a failed write
+	fmt.Fprintln(l.w, line) //nolint:errcheck // see above
+}
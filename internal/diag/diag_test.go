@@ -0,0 +1,68 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		quiet    bool
+		expected string
+	}{
+		{
+			name:     "text",
+			format:   FormatText,
+			expected: "Using config file: a.yaml\nWarning: bad pattern\nError: failed\n",
+		},
+		{
+			name:     "quiet text",
+			format:   FormatText,
+			quiet:    true,
+			expected: "Error: failed\n",
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			expected: `{"timestamp":"2024-03-10T15:04:05Z","level":"info","message":"Using config file: a.yaml"}` + "\n" +
+				`{"timestamp":"2024-03-10T15:04:05Z","level":"warning","message":"bad pattern"}` + "\n" +
+				`{"timestamp":"2024-03-10T15:04:05Z","level":"error","message":"failed"}` + "\n",
+		},
+		{
+			name:     "quiet json",
+			format:   FormatJSON,
+			quiet:    true,
+			expected: `{"timestamp":"2024-03-10T15:04:05Z","level":"error","message":"failed"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf)
+			logger.now = func() time.Time { return time.Date(2024, time.March, 10, 15, 4, 5, 0, time.UTC) }
+			logger.SetQuiet(tt.quiet)
+			if err := logger.SetFormat(tt.format); err != nil {
+				t.Fatalf("SetFormat failed: %v", err)
+			}
+
+			logger.Infof("Using config file: %s", "a.yaml")
+			logger.Warnf("bad pattern")
+			logger.Errorf("failed")
+
+			if buf.String() != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestSetFormatInvalid(t *testing.T) {
+	logger := New(&bytes.Buffer{})
+	if err := logger.SetFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
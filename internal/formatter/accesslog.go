@@ -0,0 +1,87 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// accessLogRe matches the Common Log Format, optionally followed by the
+// referer and user agent of the Combined Log Format. Servers such as nginx
+// may append further fields, which are ignored.
+var accessLogRe = regexp.MustCompile(
+	`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}|-) (\d+|-)` +
+		`(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// AccessLogParser parses web server access logs in the Common or Combined
+// Log Format, as written by Apache and nginx. Fields containing the "-"
+// placeholder are set to nil.
+type AccessLogParser struct{}
+
+// Parse decodes an access log line, returning an error if it isn't in the
+// Common or Combined Log Format
+func (AccessLogParser) Parse(line []byte) (map[string]interface{}, error) {
+	text := string(line)
+	loc := accessLogRe.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil, fmt.Errorf("invalid access log: %s", line)
+	}
+
+	// Extract the submatches, noting whether the optional Combined Log Format
+	// fields, starting with the referer in group 8, were present
+	m := make([]string, len(loc)/2)
+	for i := range m {
+		if loc[2*i] >= 0 {
+			m[i] = text[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	combined := loc[2*8] >= 0
+
+	data := map[string]interface{}{
+		"remote_addr": accessLogValue(m[1]),
+		"ident":       accessLogValue(m[2]),
+		"user":        accessLogValue(m[3]),
+		"timestamp":   m[4],
+		"request":     accessLogValue(unescapeAccessLog(m[5])),
+		"status":      accessLogNumber(m[6]),
+		"bytes":       accessLogNumber(m[7]),
+	}
+
+	// Split the request line into its parts, if it is well formed
+	if parts := strings.Fields(unescapeAccessLog(m[5])); len(parts) == 3 {
+		data["method"] = parts[0]
+		data["path"] = parts[1]
+		data["protocol"] = parts[2]
+	}
+
+	if combined {
+		data["referer"] = accessLogValue(unescapeAccessLog(m[8]))
+		data["user_agent"] = accessLogValue(unescapeAccessLog(m[9]))
+	}
+
+	return data, nil
+}
+
+// accessLogValue returns nil for the "-" placeholder, or the value otherwise
+func accessLogValue(value string) interface{} {
+	if value == "-" {
+		return nil
+	}
+	return value
+}
+
+// accessLogNumber parses a numeric field, returning nil for the "-"
+// placeholder
+func accessLogNumber(value string) interface{} {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return nil
+}
+
+// unescapeAccessLog removes the backslash escapes used for quotes and
+// backslashes within quoted fields
+func unescapeAccessLog(value string) string {
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
+}
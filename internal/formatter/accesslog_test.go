@@ -0,0 +1,136 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:  "common log format",
+			input: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			expected: map[string]interface{}{
+				"remote_addr": "127.0.0.1",
+				"ident":       nil,
+				"user":        "frank",
+				"timestamp":   "10/Oct/2000:13:55:36 -0700",
+				"request":     "GET /apache_pb.gif HTTP/1.0",
+				"method":      "GET",
+				"path":        "/apache_pb.gif",
+				"protocol":    "HTTP/1.0",
+				"status":      200,
+				"bytes":       2326,
+			},
+		},
+		{
+			name:  "combined log format from nginx",
+			input: `203.0.113.7 - - [10/Mar/2024:15:04:05 +0000] "POST /api/v1/login?next=%2F HTTP/1.1" 401 0 "https://example.com/login" "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0 Safari/537.36"`,
+			expected: map[string]interface{}{
+				"remote_addr": "203.0.113.7",
+				"ident":       nil,
+				"user":        nil,
+				"timestamp":   "10/Mar/2024:15:04:05 +0000",
+				"request":     "POST /api/v1/login?next=%2F HTTP/1.1",
+				"method":      "POST",
+				"path":        "/api/v1/login?next=%2F",
+				"protocol":    "HTTP/1.1",
+				"status":      401,
+				"bytes":       0,
+				"referer":     "https://example.com/login",
+				"user_agent":  "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0 Safari/537.36",
+			},
+		},
+		{
+			name:  "combined with placeholders, escapes and extra fields",
+			input: `::1 - - [10/Mar/2024:15:04:05 +0000] "GET /search?q=\"x\" HTTP/2.0" 304 - "-" "curl/8.5.0" "-" rt=0.003`,
+			expected: map[string]interface{}{
+				"remote_addr": "::1",
+				"ident":       nil,
+				"user":        nil,
+				"timestamp":   "10/Mar/2024:15:04:05 +0000",
+				"request":     `GET /search?q="x" HTTP/2.0`,
+				"method":      "GET",
+				"path":        `/search?q="x"`,
+				"protocol":    "HTTP/2.0",
+				"status":      304,
+				"bytes":       nil,
+				"referer":     nil,
+				"user_agent":  "curl/8.5.0",
+			},
+		},
+		{
+			name:  "malformed request line",
+			input: `198.51.100.2 - - [10/Mar/2024:15:04:05 +0000] "\x16\x03\x01" 400 157 "-" "-"`,
+			expected: map[string]interface{}{
+				"remote_addr": "198.51.100.2",
+				"ident":       nil,
+				"user":        nil,
+				"timestamp":   "10/Mar/2024:15:04:05 +0000",
+				"request":     `\x16\x03\x01`,
+				"status":      400,
+				"bytes":       157,
+				"referer":     nil,
+				"user_agent":  nil,
+			},
+		},
+		{
+			name:    "json line",
+			input:   `{"level":"info"}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing status",
+			input:   `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := AccessLogParser{}.Parse([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithAccessLog(t *testing.T) {
+	formatter, err := NewTemplateFormatter(
+		"{timestamp | date} {method} {path} {status}",
+		WithNoColors(true),
+		WithInputParser(AccessLogParser{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	input := `10.0.0.1 - - [10/Mar/2024:15:04:05 +0000] "GET /health HTTP/1.1" 200 2 "-" "kube-probe/1.29"` + "\n"
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "2024-03-10 15:04:05 GET /health 200\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
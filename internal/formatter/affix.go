@@ -0,0 +1,75 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// WithLineAffixes makes ProcessStream and ProcessSingle add a prefix and a
+// suffix to each line they write, such as to tag streams from several
+// sources before merging them. Both are templates, with the same syntax and functions as the format
+// template, evaluated against each record, so a prefix can include a field;
+// plain strings are written as they are. Each line of a multi-line record
+// gets the same prefix and suffix. Non-JSON lines and comments are given the
+// prefix and suffix of the record they follow, or of an empty record before
+// the first one. Either may be empty.
+func WithLineAffixes(prefix, suffix string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.linePrefix = prefix
+		tf.lineSuffix = suffix
+	}
+}
+
+// parseLineAffix parses the template of a line prefix or suffix, returning
+// nil if it is empty
+func parseLineAffix(name, text string, funcs template.FuncMap, options PreProcessTemplateOptions) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := newTemplate(funcs)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err = tmpl.Parse(PreProcessTemplate(text, options))
+	if err != nil {
+		return nil, fmt.Errorf("line %s: %w", name, newTemplateError(text, err, funcNames(funcs)))
+	}
+	return tmpl, nil
+}
+
+// affixLines adds the line prefix and suffix, evaluated against data, to each
+// line of text
+func (f *TemplateFormatter) affixLines(data map[string]interface{}, text string) (string, error) {
+	if f.prefixTemplate == nil && f.suffixTemplate == nil {
+		return text, nil
+	}
+
+	prefix, err := executeAffix("prefix", f.prefixTemplate, data)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := executeAffix("suffix", f.suffixTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line + suffix
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// executeAffix evaluates a line prefix or suffix template against data
+func executeAffix(name string, tmpl *template.Template, data map[string]interface{}) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("line %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
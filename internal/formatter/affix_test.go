@@ -0,0 +1,77 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithLineAffixes(t *testing.T) {
+	input := "# started\n" +
+		`{"service":"api","n":1}` + "\n" +
+		"panic: boom\n" +
+		`{"service":"worker","n":2}` + "\n"
+
+	tests := []struct {
+		name     string
+		template string
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{
+			name:     "none",
+			template: "{n}",
+			expected: "# started\n1\n>>> panic: boom\n2\n",
+		},
+		{
+			name:     "plain prefix",
+			template: "{n}",
+			prefix:   "[db-1] ",
+			expected: "[db-1] # started\n[db-1] 1\n[db-1] >>> panic: boom\n[db-1] 2\n",
+		},
+		{
+			name:     "templated prefix and suffix",
+			template: "{n}",
+			prefix:   "{{with .service}}{{.}}: {{end}}",
+			suffix:   " ({service | trimPrefix \"w\"})",
+			expected: "# started ()\napi: 1 (api)\napi: >>> panic: boom (api)\nworker: 2 (orker)\n",
+		},
+		{
+			name:     "every line of a multi-line record",
+			template: "{n}\n  from {service}",
+			prefix:   "| ",
+			expected: "| # started\n| 1\n|   from api\n| >>> panic: boom\n| 2\n|   from worker\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(true), WithCompactNonJSON(true),
+				WithComments(CommentsPass, ""), WithLineAffixes(tt.prefix, tt.suffix))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestLineAffixErrors(t *testing.T) {
+	_, err := NewTemplateFormatter("{n}", WithLineAffixes("{{.n", ""))
+	if err == nil || !strings.Contains(err.Error(), "line prefix") {
+		t.Errorf("Expected a line prefix template error, got %v", err)
+	}
+
+	_, err = NewTemplateFormatter("{n}", WithLineAffixes("", "{n | nosuchfunc}"))
+	if err == nil || !strings.Contains(err.Error(), "line suffix") {
+		t.Errorf("Expected a line suffix template error, got %v", err)
+	}
+}
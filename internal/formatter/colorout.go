@@ -0,0 +1,78 @@
+package formatter
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// WithColorOutput makes ProcessStream strip colors and other ANSI styles
+// from the output written to each writer for which supportsColor returns
+// false, even though the template produced them. This separates whether a
+// template uses color from whether a destination can show it, so one
+// formatter can write to a terminal and a file, such as with WithLevelWriter.
+// supportsColor is called once for each writer in a stream; IsTerminal is a
+// suitable default. A nil function keeps colors everywhere, which is the
+// default.
+func WithColorOutput(supportsColor func(w io.Writer) bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.supportsColor = supportsColor
+	}
+}
+
+// IsTerminal reports whether w writes to a terminal. Writers that wrap
+// another writer and have an Unwrap method, such as a BufferedWriter, are
+// looked through.
+func IsTerminal(w io.Writer) bool {
+	for {
+		switch v := w.(type) {
+		case interface{ Stat() (os.FileInfo, error) }:
+			info, err := v.Stat()
+			return err == nil && info.Mode()&os.ModeCharDevice != 0
+		case interface{ Unwrap() io.Writer }:
+			w = v.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// stripANSI removes ANSI escape sequences, such as colors, from s
+func stripANSI(s string) string {
+	if strings.IndexByte(s, '\033') < 0 {
+		return s
+	}
+	return ansiEscapeRe.ReplaceAllString(s, "")
+}
+
+// colorFilter strips colors from output written to writers that can't show
+// them, remembering which writers can
+type colorFilter struct {
+	supportsColor func(w io.Writer) bool
+	plain         map[io.Writer]bool
+}
+
+// newColorFilter returns a color filter for a stream, or nil if colors are
+// written to every writer
+func (f *TemplateFormatter) newColorFilter() *colorFilter {
+	if f.supportsColor == nil || f.noColors {
+		return nil
+	}
+	return &colorFilter{supportsColor: f.supportsColor, plain: map[io.Writer]bool{}}
+}
+
+// apply returns s as it should be written to w
+func (c *colorFilter) apply(w io.Writer, s string) string {
+	if c == nil {
+		return s
+	}
+	plain, ok := c.plain[w]
+	if !ok {
+		plain = !c.supportsColor(w)
+		c.plain[w] = plain
+	}
+	if plain {
+		return stripANSI(s)
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package formatter
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fauxTerminal is a buffer that claims to be a terminal
+type fauxTerminal struct {
+	bytes.Buffer
+}
+
+func TestProcessStreamWithColorOutput(t *testing.T) {
+	input := `{"level":"error","msg":"boom"}` + "\n" + "panic: boom\n" + `{"level":"info","msg":"ok"}` + "\n"
+	supportsColor := func(w io.Writer) bool {
+		_, ok := w.(*fauxTerminal)
+		return ok
+	}
+
+	var file bytes.Buffer
+	var terminal fauxTerminal
+	formatter, err := NewTemplateFormatter(`{level | level 5} {msg | bold}`,
+		WithColorOutput(supportsColor), WithCompactNonJSON(true), WithLevelWriter(&terminal, "error"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	if err := formatter.ProcessStream(strings.NewReader(input), &file, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "\033[31mERROR\033[0m \033[1mboom\033[0m\n\033[31m>>>\033[0m panic: boom\n"
+	if terminal.String() != expected {
+		t.Errorf("Expected colors on the terminal, got %q", terminal.String())
+	}
+	if expected := "INFO  ok\n"; file.String() != expected {
+		t.Errorf("Expected %q without colors, got %q", expected, file.String())
+	}
+
+	// The same formatter writes colors to a terminal
+	terminal.Reset()
+	if err := formatter.ProcessStream(strings.NewReader(`{"level":"info","msg":"ok"}`+"\n"), &terminal, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if expected := "\033[32mINFO \033[0m \033[1mok\033[0m\n"; terminal.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, terminal.String())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close() //nolint:errcheck // Only read from
+
+	if IsTerminal(file) || IsTerminal(NewBufferedWriter(file, 0)) || IsTerminal(&bytes.Buffer{}) {
+		t.Error("Expected files and buffers not to be terminals")
+	}
+}
@@ -2,7 +2,10 @@ package formatter
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Color name constants
@@ -67,19 +70,250 @@ var colorCodes = map[string]string{
 // Reset code
 const ansiReset = "\033[0m"
 
-// ApplyColorToString applies a specific color to a string value
+// ansiEscapeRe matches ANSI SGR escape sequences, such as color codes
+var ansiEscapeRe = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// visibleWidth returns the number of characters in s as displayed in a
+// terminal, ignoring ANSI escape sequences
+func visibleWidth(s string) int {
+	if strings.IndexByte(s, '\033') >= 0 {
+		s = ansiEscapeRe.ReplaceAllString(s, "")
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// bgPrefix marks a color as a background color
+const bgPrefix = "bg-"
+
+// Color depths a terminal may support, from fewest colors to most
+const (
+	// ColorDepth16 limits output to the 16 named colors
+	ColorDepth16 = "16"
+	// ColorDepth256 allows the 256-color palette, approximating hex colors
+	ColorDepth256 = "256"
+	// ColorDepthTrueColor allows any hex color
+	ColorDepthTrueColor = "truecolor"
+	// ColorDepthAuto detects the color depth from the environment
+	ColorDepthAuto = "auto"
+)
+
+// sixteenColorTerms are TERM values of terminals known to show only the 8 or
+// 16 named colors
+var sixteenColorTerms = map[string]bool{
+	"ansi":   true,
+	"cons25": true,
+	"dumb":   true,
+	"linux":  true,
+	"vt100":  true,
+	"vt102":  true,
+	"vt220":  true,
+}
+
+// DetectColorDepth returns the color depth of a terminal from the values of
+// the TERM and COLORTERM environment variables. Almost every terminal in use
+// shows the 256-color palette, whatever TERM says, so that is assumed unless
+// TERM names a terminal known to show only 16 colors.
+func DetectColorDepth(term, colorTerm string) string {
+	switch strings.ToLower(colorTerm) {
+	case "truecolor", "24bit":
+		return ColorDepthTrueColor
+	}
+
+	term = strings.ToLower(term)
+	switch {
+	case strings.Contains(term, "truecolor"), strings.Contains(term, "24bit"), strings.HasSuffix(term, "-direct"):
+		return ColorDepthTrueColor
+	case sixteenColorTerms[term], strings.HasSuffix(term, "-16color"), strings.HasSuffix(term, "-8color"):
+		return ColorDepth16
+	default:
+		return ColorDepth256
+	}
+}
+
+// rgb is a color as red, green and blue components
+type rgb struct {
+	r, g, b int
+}
+
+// namedColors are the 16 named colors with the values xterm uses for them,
+// in palette order, so the first 16 colors of the 256-color palette are
+// these too
+var namedColors = []struct {
+	name  string
+	color rgb
+}{
+	{"black", rgb{0, 0, 0}},
+	{"red", rgb{205, 0, 0}},
+	{"green", rgb{0, 205, 0}},
+	{"yellow", rgb{205, 205, 0}},
+	{"blue", rgb{0, 0, 238}},
+	{"magenta", rgb{205, 0, 205}},
+	{"cyan", rgb{0, 205, 205}},
+	{"white", rgb{229, 229, 229}},
+	{"gray", rgb{127, 127, 127}},
+	{"brightred", rgb{255, 0, 0}},
+	{"brightgreen", rgb{0, 255, 0}},
+	{"brightyellow", rgb{255, 255, 0}},
+	{"brightblue", rgb{92, 92, 255}},
+	{"brightmagenta", rgb{255, 0, 255}},
+	{"brightcyan", rgb{0, 255, 255}},
+	{"brightwhite", rgb{255, 255, 255}},
+}
+
+// paletteLevels are the component values of the 6x6x6 color cube in the
+// 256-color palette
+var paletteLevels = []int{0, 95, 135, 175, 215, 255}
+
+// paletteColor returns the value of a color in the 256-color palette
+func paletteColor(n int) rgb {
+	switch {
+	case n < 16:
+		return namedColors[n].color
+	case n < 232:
+		n -= 16
+		return rgb{paletteLevels[n/36], paletteLevels[n/6%6], paletteLevels[n%6]}
+	default:
+		level := 8 + (n-232)*10
+		return rgb{level, level, level}
+	}
+}
+
+// colorDistance returns how different two colors look, using the "redmean"
+// approximation, which weights the components by how sensitive eyes are to
+// them. Only the order of the results is meaningful.
+func colorDistance(a, b rgb) int {
+	redMean := (a.r + b.r) / 2
+	dr, dg, db := a.r-b.r, a.g-b.g, a.b-b.b
+	return (512+redMean)*dr*dr>>8 + 4*dg*dg + (767-redMean)*db*db>>8
+}
+
+// nearestLevel returns the index of the palette cube level closest to v
+func nearestLevel(v int) int {
+	best := 0
+	for i, level := range paletteLevels {
+		if abs(level-v) < abs(paletteLevels[best]-v) {
+			best = i
+		}
+	}
+	return best
+}
+
+// nearestPaletteColor returns the color in the 256-color palette that looks
+// most like c, from the color cube or the grayscale ramp. The first 16
+// colors are skipped since terminals often change them.
+func nearestPaletteColor(c rgb) int {
+	cube := 16 + 36*nearestLevel(c.r) + 6*nearestLevel(c.g) + nearestLevel(c.b)
+
+	gray := 232 + min(max((c.r+c.g+c.b)/3-3, 0)/10, 23)
+	if colorDistance(c, paletteColor(gray)) < colorDistance(c, paletteColor(cube)) {
+		return gray
+	}
+	return cube
+}
+
+// nearestNamedColor returns the name of the named color that looks most like c
+func nearestNamedColor(c rgb) string {
+	best := 0
+	for i, named := range namedColors {
+		if colorDistance(c, named.color) < colorDistance(c, namedColors[best].color) {
+			best = i
+		}
+	}
+	return namedColors[best].name
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// colorCode returns the ANSI SGR code for a color, which may be a named color
+// such as "red" or "bg-blue", a hex color such as "#ff8800" or "#f80" for
+// terminals with truecolor support, or a number from 0 to 255 in the
+// 256-color palette, such as "208". Hex and palette colors can be prefixed
+// with "bg-" to set the background. Colors the given depth can't show are
+// replaced by the nearest color it can; an empty depth allows every color.
+// The second result is false if the color isn't recognized.
+func colorCode(colorName, depth string) (string, bool) {
+	if code, ok := colorCodes[colorName]; ok {
+		return code, true
+	}
+
+	layer := "38"
+	spec := colorName
+	prefix := ""
+	if rest, ok := strings.CutPrefix(colorName, bgPrefix); ok {
+		layer = "48"
+		spec = rest
+		prefix = bgPrefix
+	}
+
+	if hex, ok := strings.CutPrefix(spec, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return "", false
+		}
+		value, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", false
+		}
+		c := rgb{int(value >> 16), int(value >> 8 & 0xff), int(value & 0xff)}
+
+		switch depth {
+		case ColorDepth16:
+			return colorCodes[prefix+nearestNamedColor(c)], true
+		case ColorDepth256:
+			return fmt.Sprintf("%s;5;%d", layer, nearestPaletteColor(c)), true
+		}
+		return fmt.Sprintf("%s;2;%d;%d;%d", layer, c.r, c.g, c.b), true
+	}
+
+	if n, err := strconv.ParseUint(spec, 10, 8); err == nil {
+		if depth == ColorDepth16 {
+			return colorCodes[prefix+nearestNamedColor(paletteColor(int(n)))], true
+		}
+		return fmt.Sprintf("%s;5;%d", layer, n), true
+	}
+
+	return "", false
+}
+
+// ApplyColorToString applies a specific color to a string value. Colors are
+// given as for colorCode.
 func ApplyColorToString(content, colorName string) string {
+	return applyColor(content, colorName, "")
+}
+
+// applyColor applies a color to a string value, limited to the colors a
+// terminal with the given color depth can show
+func applyColor(content, colorName, depth string) string {
 	if colorName == "none" {
 		return content
 	}
 
-	if code, ok := colorCodes[colorName]; ok {
+	if code, ok := colorCode(colorName, depth); ok {
 		return fmt.Sprintf("\033[%sm%s%s", code, content, ansiReset)
 	}
 
 	return content // Return unchanged if color not found
 }
 
+// levelBadges maps the color of a level to the background and text colors of
+// its badge
+var levelBadges = map[string][2]string{
+	colorRed:    {"bg-red", "brightwhite"},
+	colorYellow: {"bg-yellow", "black"},
+	colorGreen:  {"bg-green", "black"},
+	colorCyan:   {"bg-cyan", "black"},
+	colorBlue:   {"bg-blue", "brightwhite"},
+	colorWhite:  {"bg-white", "black"},
+}
+
 // ColorByLevelName returns the appropriate color for a log level
 func ColorByLevelName(level string) string {
 	levelStr := strings.ToLower(level)
@@ -89,7 +323,7 @@ func ColorByLevelName(level string) string {
 		return colorRed
 	case "warn", "warning":
 		return colorYellow
-	case "info", "information":
+	case "info", "information", "notice":
 		return colorGreen
 	case "debug":
 		return colorCyan
@@ -47,6 +47,48 @@ func TestApplyColorToString(t *testing.T) {
 			noColors:  false,
 			expected:  "No color",
 		},
+		{
+			name:      "hex color",
+			content:   "orange",
+			colorName: "#ff8800",
+			expected:  "\033[38;2;255;136;0morange\033[0m",
+		},
+		{
+			name:      "short hex color",
+			content:   "orange",
+			colorName: "#F80",
+			expected:  "\033[38;2;255;136;0morange\033[0m",
+		},
+		{
+			name:      "hex background",
+			content:   "orange",
+			colorName: "bg-#ff8800",
+			expected:  "\033[48;2;255;136;0morange\033[0m",
+		},
+		{
+			name:      "palette color",
+			content:   "orange",
+			colorName: "208",
+			expected:  "\033[38;5;208morange\033[0m",
+		},
+		{
+			name:      "palette background",
+			content:   "orange",
+			colorName: "bg-208",
+			expected:  "\033[48;5;208morange\033[0m",
+		},
+		{
+			name:      "invalid hex color",
+			content:   "Not colored",
+			colorName: "#ff880",
+			expected:  "Not colored",
+		},
+		{
+			name:      "palette color out of range",
+			content:   "Not colored",
+			colorName: "256",
+			expected:  "Not colored",
+		},
 	}
 
 	for _, tt := range tests {
@@ -111,3 +153,79 @@ func TestColorByLevelName(t *testing.T) {
 		})
 	}
 }
+
+func TestColorCodeWithDepth(t *testing.T) {
+	tests := []struct {
+		colorName string
+		depth     string
+		expected  string
+	}{
+		{colorName: "red", depth: ColorDepth16, expected: "31"},
+		{colorName: "#ff8800", depth: ColorDepthTrueColor, expected: "38;2;255;136;0"},
+		{colorName: "#ff8800", depth: ColorDepth256, expected: "38;5;208"},
+		{colorName: "#767676", depth: ColorDepth256, expected: "38;5;243"},
+		{colorName: "bg-#000080", depth: ColorDepth256, expected: "48;5;18"},
+		{colorName: "208", depth: ColorDepth256, expected: "38;5;208"},
+		{colorName: "#ff0000", depth: ColorDepth16, expected: "91"},
+		{colorName: "#cc0000", depth: ColorDepth16, expected: "31"},
+		{colorName: "#808080", depth: ColorDepth16, expected: "90"},
+		{colorName: "#fafafa", depth: ColorDepth16, expected: "97"},
+		{colorName: "bg-#0000ee", depth: ColorDepth16, expected: "44"},
+		{colorName: "196", depth: ColorDepth16, expected: "91"},
+		{colorName: "2", depth: ColorDepth16, expected: "32"},
+		{colorName: "bg-236", depth: ColorDepth16, expected: "40"},
+	}
+
+	for _, tt := range tests {
+		code, ok := colorCode(tt.colorName, tt.depth)
+		if !ok || code != tt.expected {
+			t.Errorf("colorCode(%q, %q) = %q, %v, expected %q", tt.colorName, tt.depth, code, ok, tt.expected)
+		}
+	}
+}
+
+func TestDetectColorDepth(t *testing.T) {
+	tests := []struct {
+		term      string
+		colorTerm string
+		expected  string
+	}{
+		{term: "xterm", expected: ColorDepth256},
+		{term: "", expected: ColorDepth256},
+		{term: "linux", expected: ColorDepth16},
+		{term: "vt100", expected: ColorDepth16},
+		{term: "ansi", expected: ColorDepth16},
+		{term: "rxvt-16color", expected: ColorDepth16},
+		{term: "linux", colorTerm: "truecolor", expected: ColorDepthTrueColor},
+		{term: "xterm-256color", expected: ColorDepth256},
+		{term: "screen-256color", expected: ColorDepth256},
+		{term: "xterm-256color", colorTerm: "truecolor", expected: ColorDepthTrueColor},
+		{term: "xterm", colorTerm: "24bit", expected: ColorDepthTrueColor},
+		{term: "xterm-direct", expected: ColorDepthTrueColor},
+	}
+
+	for _, tt := range tests {
+		if depth := DetectColorDepth(tt.term, tt.colorTerm); depth != tt.expected {
+			t.Errorf("DetectColorDepth(%q, %q) = %q, expected %q", tt.term, tt.colorTerm, depth, tt.expected)
+		}
+	}
+}
+
+func TestColorFuncWithColorDepth(t *testing.T) {
+	formatter, err := NewTemplateFormatter(`{{color "#ff8800" .msg}}`, WithColorDepth(ColorDepth16))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	result, err := formatter.Format(map[string]interface{}{"msg": "orange"})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if expected := "\033[33morange\033[0m"; result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	if _, err := NewTemplateFormatter("{msg}", WithColorDepth("64")); err == nil {
+		t.Error("Expected an error for an unknown color depth")
+	}
+}
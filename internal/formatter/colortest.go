@@ -0,0 +1,87 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// colorTestStyles are the text styles shown by WriteColorTest
+var colorTestStyles = []string{"bold", "dim", "italic", "underline"}
+
+// WriteColorTest writes swatches of the colors and styles available to
+// templates, so users can see what their terminal shows: the named colors
+// and their backgrounds, the text styles, and, if the color depth allows,
+// the 256-color palette and a truecolor gradient. Colors are written whether
+// or not w is a terminal.
+func WriteColorTest(w io.Writer, depth string) error {
+	var b strings.Builder
+
+	b.WriteString("Named colors\n")
+	for _, named := range namedColors {
+		fmt.Fprintf(&b, "  %s  %s\n",
+			ApplyColorToString(padString(named.name, 14, false), named.name),
+			ApplyColorToString(padString(bgPrefix+named.name, 17, false), bgPrefix+named.name))
+	}
+
+	b.WriteString("\nStyles\n ")
+	for _, style := range colorTestStyles {
+		b.WriteString(" " + ApplyColorToString(style, style))
+	}
+	b.WriteString("\n")
+
+	if depth == ColorDepth16 {
+		b.WriteString("\nThe 256-color palette and truecolor are not shown for a 16-color terminal.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("\n256-color palette\n")
+	writePaletteRow(&b, 0, 16)
+	for row := 16; row < 232; row += 36 {
+		writePaletteRow(&b, row, row+36)
+	}
+	writePaletteRow(&b, 232, 256)
+
+	if depth == ColorDepth256 {
+		b.WriteString("\nTruecolor is not shown for a 256-color terminal.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("\nTruecolor\n  ")
+	const steps = 72
+	for i := range steps {
+		c := gradientColor(float64(i) / steps)
+		b.WriteString(ApplyColorToString(" ", fmt.Sprintf("bg-#%02x%02x%02x", c.r, c.g, c.b)))
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writePaletteRow writes a row of swatches for the palette colors from start
+// up to end
+func writePaletteRow(b *strings.Builder, start, end int) {
+	b.WriteString("  ")
+	for n := start; n < end; n++ {
+		b.WriteString(ApplyColorToString(" ", fmt.Sprintf("bg-%d", n)))
+	}
+	fmt.Fprintf(b, "  %d-%d\n", start, end-1)
+}
+
+// gradientColor returns the color at position t, from 0 to 1, of a rainbow
+// running from red through green and blue back towards red
+func gradientColor(t float64) rgb {
+	segment := t * 3
+	mix := int((segment - float64(int(segment))) * 255)
+	switch int(segment) {
+	case 0:
+		return rgb{255 - mix, mix, 0}
+	case 1:
+		return rgb{0, 255 - mix, mix}
+	default:
+		return rgb{mix, 0, 255 - mix}
+	}
+}
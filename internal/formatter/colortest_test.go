@@ -0,0 +1,41 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteColorTest(t *testing.T) {
+	tests := []struct {
+		depth      string
+		palette    bool
+		trueColors bool
+	}{
+		{depth: ColorDepth16},
+		{depth: ColorDepth256, palette: true},
+		{depth: ColorDepthTrueColor, palette: true, trueColors: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.depth, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteColorTest(&buf, tt.depth); err != nil {
+				t.Fatalf("WriteColorTest failed: %v", err)
+			}
+			out := buf.String()
+
+			for _, want := range []string{"\033[31mred", "\033[41mbg-red", "\033[107mbg-brightwhite", "\033[4munderline"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("Expected output to contain %q", want)
+				}
+			}
+			if got := strings.Contains(out, "\033[48;5;255m"); got != tt.palette {
+				t.Errorf("Expected the palette to be shown: %v, got %v", tt.palette, got)
+			}
+			if got := strings.Contains(out, "\033[48;2;255;0;0m"); got != tt.trueColors {
+				t.Errorf("Expected the truecolor gradient to be shown: %v, got %v", tt.trueColors, got)
+			}
+		})
+	}
+}
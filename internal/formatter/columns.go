@@ -0,0 +1,167 @@
+package formatter
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultColumnsBatch is how many records are aligned together in columns
+const DefaultColumnsBatch = 50
+
+// columnsIdleFlush is how long input may pause before the records waiting to
+// be aligned are written without filling their batch
+const columnsIdleFlush = 200 * time.Millisecond
+
+// columnGap separates the columns of a row
+const columnGap = "  "
+
+// WithColumns makes ProcessStream write the given fields of each record as
+// aligned columns under a header, like the column command, instead of
+// formatting records with the template. Records are collected in batches of
+// up to batch records, and the width of each column is the widest value in
+// the batch, so columns line up across records rather than within one. A new
+// header is written for each batch, since the widths may change. Columns
+// holding only numbers are right-aligned. Fields may be dotted paths into
+// nested maps, and missing fields are left blank.
+//
+// A batch is written early when a non-JSON line arrives, when no input
+// arrives for a moment and when the stream ends, so records from a slow
+// stream aren't held back waiting for a batch to fill. With WithTail, the
+// records kept are aligned as a single batch once the stream ends.
+func WithColumns(columns []string, batch int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.columns = columns
+		tf.columnsBatch = batch
+	}
+}
+
+// columnBatch collects records to be written as aligned columns
+type columnBatch struct {
+	columns  []string
+	size     int
+	noColors bool
+	records  []map[string]interface{}
+	// tail is the number of records kept when only the last are written, in
+	// which case the batch is written only when the stream ends
+	tail int
+}
+
+// newColumnBatch returns a columnBatch for a stream, or nil if records are
+// formatted with the template
+func (f *TemplateFormatter) newColumnBatch() *columnBatch {
+	if len(f.columns) == 0 {
+		return nil
+	}
+
+	size := f.columnsBatch
+	if size <= 0 {
+		size = DefaultColumnsBatch
+	}
+	return &columnBatch{columns: f.columns, size: size, noColors: f.noColors, tail: f.tail}
+}
+
+// columnsIdle returns how long a read may wait for input before the records
+// waiting to be aligned are written, or 0 if they are only written once their
+// batch is full
+func (f *TemplateFormatter) columnsIdle() time.Duration {
+	if len(f.columns) == 0 || f.tail > 0 {
+		return 0
+	}
+	return columnsIdleFlush
+}
+
+// add adds a record to the batch
+func (c *columnBatch) add(data map[string]interface{}) {
+	c.records = append(c.records, data)
+	if c.tail > 0 && len(c.records) > c.tail {
+		c.records = c.records[1:]
+	}
+}
+
+// full reports whether the batch should be written
+func (c *columnBatch) full() bool {
+	return c != nil && c.tail == 0 && len(c.records) >= c.size
+}
+
+// take returns the records in the batch, each with its aligned row, and
+// empties it. The first row is preceded by the header.
+func (c *columnBatch) take() ([]map[string]interface{}, []string) {
+	if c == nil || len(c.records) == 0 {
+		return nil, nil
+	}
+	records := c.records
+	c.records = nil
+
+	cells := make([][]string, len(records))
+	widths := make([]int, len(c.columns))
+	numeric := make([]bool, len(c.columns))
+	text := make([]bool, len(c.columns))
+	for i, column := range c.columns {
+		widths[i] = visibleWidth(column)
+	}
+
+	for r, data := range records {
+		cells[r] = make([]string, len(c.columns))
+		for i, column := range c.columns {
+			value, ok := lookupField(data, column)
+			if !ok || value == nil {
+				continue
+			}
+			if _, isNumber := value.(float64); isNumber {
+				numeric[i] = true
+			} else {
+				text[i] = true
+			}
+			cells[r][i] = columnValue(value)
+			widths[i] = max(widths[i], visibleWidth(cells[r][i]))
+		}
+	}
+
+	// Right-align columns holding only numbers
+	for i := range numeric {
+		numeric[i] = numeric[i] && !text[i]
+	}
+
+	header := alignColumns(c.columns, widths, numeric)
+	if !c.noColors {
+		header = ApplyColorToString(header, "bold")
+	}
+
+	rows := make([]string, len(records))
+	for r := range records {
+		rows[r] = alignColumns(cells[r], widths, numeric)
+	}
+	rows[0] = header + "\n" + rows[0]
+	return records, rows
+}
+
+// columnValue returns the text of a value shown in a column, on a single line
+func columnValue(value interface{}) string {
+	text := fieldCommandInput(value)
+	return strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\t", " ").Replace(text)
+}
+
+// alignColumns pads cells to the column widths and joins them into a row,
+// without trailing spaces
+func alignColumns(cells []string, widths []int, rightAlign []bool) string {
+	var row strings.Builder
+	pending := 0
+	for i, cell := range cells {
+		padding := widths[i] - visibleWidth(cell)
+		if i > 0 {
+			pending += len(columnGap)
+		}
+		if rightAlign[i] {
+			pending += padding
+		}
+		if cell != "" {
+			row.WriteString(strings.Repeat(" ", pending))
+			row.WriteString(cell)
+			pending = 0
+		}
+		if !rightAlign[i] {
+			pending += padding
+		}
+	}
+	return row.String()
+}
@@ -0,0 +1,196 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessStreamWithColumns(t *testing.T) {
+	input := `{"level":"info","status":200,"path":"/","ms":3}` + "\n" +
+		`{"level":"warning","status":404,"path":"/missing/page","ms":12.5}` + "\n" +
+		`{"level":"error","path":"/api","ms":1500,"user":{"id":"u1"}}` + "\n"
+
+	tests := []struct {
+		name     string
+		input    string
+		columns  []string
+		batch    int
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:    "aligned across records",
+			input:   input,
+			columns: []string{"level", "path", "status"},
+			expected: "" +
+				"level    path           status\n" +
+				"info     /                 200\n" +
+				"warning  /missing/page     404\n" +
+				"error    /api\n",
+		},
+		{
+			name:    "numbers are right-aligned",
+			input:   input,
+			columns: []string{"ms", "level"},
+			expected: "" +
+				"  ms  level\n" +
+				"   3  info\n" +
+				"12.5  warning\n" +
+				"1500  error\n",
+		},
+		{
+			name:    "nested and missing fields",
+			input:   input,
+			columns: []string{"user.id", "level", "host"},
+			expected: "" +
+				"user.id  level    host\n" +
+				"         info\n" +
+				"         warning\n" +
+				"u1       error\n",
+		},
+		{
+			name:    "widths are computed per batch",
+			input:   input,
+			columns: []string{"path", "level"},
+			batch:   2,
+			expected: "" +
+				"path           level\n" +
+				"/              info\n" +
+				"/missing/page  warning\n" +
+				"path  level\n" +
+				"/api  error\n",
+		},
+		{
+			name:    "non-JSON lines end a batch",
+			input:   `{"level":"info"}` + "\n" + "oops\n" + `{"level":"warning"}` + "\n",
+			columns: []string{"level"},
+			expected: "" +
+				"level\n" +
+				"info\n" +
+				">>> oops\n" +
+				"level\n" +
+				"warning\n",
+		},
+		{
+			name:    "head",
+			input:   input,
+			columns: []string{"level"},
+			options: []FormatterOption{WithHead(2)},
+			expected: "" +
+				"level\n" +
+				"info\n" +
+				"warning\n",
+		},
+		{
+			name:    "values are kept on one line",
+			input:   `{"msg":"one\ntwo","n":1}` + "\n",
+			columns: []string{"msg", "n"},
+			expected: "" +
+				"msg       n\n" +
+				`one\ntwo  1` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{
+				WithNoColors(true), WithCompactNonJSON(true), WithColumns(tt.columns, tt.batch),
+			}, tt.options...)
+			formatter, err := NewTemplateFormatter("{message}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(tt.input), &buf, formatter, nil, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestColumnsWithTail(t *testing.T) {
+	input := `{"n":1}` + "\n" + `{"n":2}` + "\n" + `{"n":1000}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{n}", WithNoColors(true), WithColumns([]string{"n"}, 1), WithTail(2))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	// The last records are aligned together, regardless of the batch size
+	if expected := "   n\n   2\n1000\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestColumnsWrittenWhenInputPauses(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{n}", WithNoColors(true), WithColumns([]string{"n"}, 50))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		process func(r io.Reader, w io.Writer) error
+	}{
+		{
+			name: "stream",
+			process: func(r io.Reader, w io.Writer) error {
+				return formatter.ProcessStream(r, w, formatter, nil, false)
+			},
+		},
+		{
+			name: "merged streams",
+			process: func(r io.Reader, w io.Writer) error {
+				inputs := []NamedInput{{Name: "a", Reader: r}, {Name: "b", Reader: strings.NewReader("")}}
+				return formatter.ProcessMergedStreams(context.Background(), inputs, w, formatter, nil, false)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			var buf syncBuffer
+			done := make(chan error, 1)
+			go func() {
+				done <- tt.process(pr, &buf)
+			}()
+
+			// Write two records, then leave the read blocked
+			if _, err := io.WriteString(pw, `{"n":1}`+"\n"+`{"n":1000}`+"\n"); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+			expected := "   n\n   1\n1000\n"
+			deadline := time.Now().Add(2 * time.Second)
+			for buf.String() != expected && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if buf.String() != expected {
+				t.Fatalf("Expected %q before the input ends, got %q", expected, buf.String())
+			}
+
+			if _, err := io.WriteString(pw, `{"n":22}`+"\n"); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+			pw.Close()
+			if err := <-done; err != nil {
+				t.Fatalf("Processing failed: %v", err)
+			}
+			if expected += " n\n22\n"; buf.String() != expected {
+				t.Errorf("Expected %q, got %q", expected, buf.String())
+			}
+		})
+	}
+}
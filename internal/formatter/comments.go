@@ -0,0 +1,42 @@
+package formatter
+
+import "strings"
+
+// DefaultCommentPrefix starts comment lines unless another prefix is given
+const DefaultCommentPrefix = "#"
+
+// How ProcessStream handles comment lines
+const (
+	// CommentsPass writes comment lines unchanged
+	CommentsPass = "pass"
+	// CommentsDrop leaves comment lines out of the output
+	CommentsDrop = "drop"
+)
+
+// WithComments makes ProcessStream recognize lines starting with prefix,
+// ignoring indentation, as comments, such as annotations added to a stream
+// by a pipeline. With CommentsPass they are written unchanged, without the
+// marker and spacing of other non-JSON lines, and with CommentsDrop they are
+// left out. Comments are never parsed as records, and don't count towards
+// limits such as WithHead. An empty prefix means DefaultCommentPrefix, and an
+// empty mode, the default, treats comments like any other line.
+func WithComments(mode, prefix string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.comments = mode
+		tf.commentPrefix = prefix
+	}
+}
+
+// isComment reports whether a line is a comment to be handled separately
+// from records and other non-JSON lines
+func (f *TemplateFormatter) isComment(line string) bool {
+	if f.comments == "" {
+		return false
+	}
+
+	prefix := f.commentPrefix
+	if prefix == "" {
+		prefix = DefaultCommentPrefix
+	}
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), prefix)
+}
@@ -0,0 +1,90 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithComments(t *testing.T) {
+	input := "# started 2024-03-10\n" +
+		`{"n":1}` + "\n" +
+		"  # indented\n" +
+		"oops\n" +
+		`{"n":2}` + "\n" +
+		"// other style\n"
+
+	tests := []struct {
+		name          string
+		options       []FormatterOption
+		handleNonJSON bool
+		expected      string
+	}{
+		{
+			name:          "not recognized by default",
+			handleNonJSON: true,
+			expected:      ">>> # started 2024-03-10\n1\n>>>   # indented\n>>> oops\n2\n>>> // other style\n",
+		},
+		{
+			name:          "passed through",
+			options:       []FormatterOption{WithComments(CommentsPass, "")},
+			handleNonJSON: true,
+			expected:      "# started 2024-03-10\n1\n  # indented\n>>> oops\n2\n>>> // other style\n",
+		},
+		{
+			name:          "dropped",
+			options:       []FormatterOption{WithComments(CommentsDrop, "")},
+			handleNonJSON: true,
+			expected:      "1\n>>> oops\n2\n>>> // other style\n",
+		},
+		{
+			name:          "custom prefix",
+			options:       []FormatterOption{WithComments(CommentsPass, "//")},
+			handleNonJSON: true,
+			expected:      ">>> # started 2024-03-10\n1\n>>>   # indented\n>>> oops\n2\n// other style\n",
+		},
+		{
+			name:     "don't count towards head",
+			options:  []FormatterOption{WithComments(CommentsDrop, ""), WithHead(1)},
+			expected: "1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true), WithCompactNonJSON(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, tt.handleNonJSON); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestCommentsWithoutNonJSONHandling(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{n}", WithComments(CommentsPass, ""))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	input := "# header\n" + `{"n":1}` + "\n"
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+		t.Fatalf("Expected comments not to be treated as invalid JSON, got %v", err)
+	}
+	if expected := "# header\n1\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	if _, err := NewTemplateFormatter("{n}", WithComments("hide", "")); err == nil {
+		t.Error("Expected an error for unknown comment handling")
+	}
+}
@@ -0,0 +1,100 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldCounter counts the records in a stream by the value of a field, which
+// may be a dotted path into nested objects, such as the number of requests with each status code. It is safe
+// for concurrent use, so a summary can be written while a stream is running.
+type FieldCounter struct {
+	field string
+
+	mu     sync.Mutex
+	counts map[string]int
+	total  int
+}
+
+// NewFieldCounter returns a counter that groups records by the value of field
+func NewFieldCounter(field string) *FieldCounter {
+	return &FieldCounter{
+		field:  field,
+		counts: make(map[string]int),
+	}
+}
+
+// WithFieldCounter sets a counter that ProcessStream updates with every
+// record it formats. Records that are skipped or left out by sampling, or
+// that aren't JSON, aren't counted.
+func WithFieldCounter(counter *FieldCounter) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.fieldCounter = counter
+	}
+}
+
+// Add counts a record. Values are compared by their string form, like skip
+// patterns, and records without the field are counted as "<no value>".
+func (c *FieldCounter) Add(data map[string]interface{}) {
+	if c == nil {
+		return
+	}
+
+	key := noValueStr
+	if value, ok := lookupField(data, c.field); ok {
+		key = fmt.Sprintf("%v", value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[key]++
+	c.total++
+}
+
+// WriteSummary writes a table of the values seen and how many records had
+// each, most frequent first, with ties ordered by value
+func (c *FieldCounter) WriteSummary(w io.Writer, noColors bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.counts))
+	keyWidth := 0
+	for key := range c.counts {
+		keys = append(keys, key)
+		keyWidth = max(keyWidth, utf8.RuneCountInString(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c.counts[keys[i]] != c.counts[keys[j]] {
+			return c.counts[keys[i]] > c.counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	countWidth := len(fmt.Sprint(c.total))
+
+	records := "records"
+	if c.total == 1 {
+		records = "record"
+	}
+	header := fmt.Sprintf("Count by %s (%d %s)", c.field, c.total, records)
+	if !noColors {
+		header = "\033[1m" + header + ansiReset
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		count := fmt.Sprintf("%*d", countWidth, c.counts[key])
+		if !noColors {
+			count = ApplyColorToString(count, "cyan")
+		}
+		padding := keyWidth - utf8.RuneCountInString(key)
+		if _, err := fmt.Fprintf(w, "  %s: %*s%s\n", key, padding, "", count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFieldCounterSummary(t *testing.T) {
+	counter := NewFieldCounter("status")
+	for _, data := range []map[string]interface{}{
+		{"status": float64(200)},
+		{"status": float64(500)},
+		{"status": float64(200)},
+		{"status": "404"},
+		{"message": "no status"},
+		{"status": float64(200)},
+		{"status": float64(404)},
+	} {
+		counter.Add(data)
+	}
+
+	tests := []struct {
+		name     string
+		noColors bool
+		expected string
+	}{
+		{
+			name:     "no colors",
+			noColors: true,
+			expected: "Count by status (7 records)\n" +
+				"  200:        3\n" +
+				"  404:        2\n" +
+				"  500:        1\n" +
+				"  <no value>: 1\n",
+		},
+		{
+			name:     "colors",
+			noColors: false,
+			expected: "\033[1mCount by status (7 records)\033[0m\n" +
+				"  200:        \033[36m3\033[0m\n" +
+				"  404:        \033[36m2\033[0m\n" +
+				"  500:        \033[36m1\033[0m\n" +
+				"  <no value>: \033[36m1\033[0m\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := counter.WriteSummary(&buf, tt.noColors); err != nil {
+				t.Fatalf("WriteSummary failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, buf.String())
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := NewFieldCounter("level").WriteSummary(&buf, true); err != nil {
+			t.Fatalf("WriteSummary failed: %v", err)
+		}
+		if expected := "Count by level (0 records)\n"; buf.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, buf.String())
+		}
+	})
+}
+
+func TestProcessStreamWithFieldCounter(t *testing.T) {
+	input := `{"level":"info","http":{"path":"/health"}}` + "\n" +
+		`{"level":"error","http":{"path":"/users"}}` + "\n" +
+		"not json\n" +
+		`{"level":"info","http":{"path":"/users"}}` + "\n" +
+		`{"level":"debug","http":{"path":"/health"}}` + "\n"
+
+	tests := []struct {
+		name     string
+		field    string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:  "skipped records",
+			field: "level",
+			expected: "Count by level (3 records)\n" +
+				"  info:  2\n" +
+				"  error: 1\n",
+		},
+		{
+			name:    "sampled records",
+			field:   "level",
+			options: []FormatterOption{WithSample(2)},
+			expected: "Count by level (2 records)\n" +
+				"  info: 2\n",
+		},
+		{
+			name:  "dotted path",
+			field: "http.path",
+			expected: "Count by http.path (3 records)\n" +
+				"  /users:  2\n" +
+				"  /health: 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := NewFieldCounter(tt.field)
+			options := append([]FormatterOption{WithNoColors(true), WithFieldCounter(counter)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{level}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			skip := []SkipPattern{{Field: "level", Value: "debug"}}
+			var out bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &out, formatter, skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+
+			var summary bytes.Buffer
+			if err := counter.WriteSummary(&summary, true); err != nil {
+				t.Fatalf("WriteSummary failed: %v", err)
+			}
+			if summary.String() != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, summary.String())
+			}
+		})
+	}
+}
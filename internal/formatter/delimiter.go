@@ -0,0 +1,55 @@
+package formatter
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// WithRecordDelimiter sets the delimiter ProcessStream splits its input into
+// records on, for producers that frame records with something other than a
+// newline, such as "\x00" for NUL-delimited records. Newlines and carriage
+// returns around each record are ignored, so records may span several lines
+// and a delimiter may be followed by a newline. An empty delimiter, the
+// default, splits the input into lines.
+func WithRecordDelimiter(delim string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.recordDelimiter = delim
+	}
+}
+
+// splitFunc returns the function the input is split into records with, or
+// nil to split it into lines
+func (f *TemplateFormatter) splitFunc() bufio.SplitFunc {
+	if f.recordDelimiter == "" {
+		return nil
+	}
+	return splitOn([]byte(f.recordDelimiter))
+}
+
+// splitOn returns a bufio.SplitFunc that splits its input on delim, trimming
+// newlines and carriage returns from around each record and skipping empty
+// records. A final record without a delimiter is returned at the end of the
+// input.
+func splitOn(delim []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		advance, record := 0, []byte(nil)
+		if i := bytes.Index(data, delim); i >= 0 {
+			advance, record = i+len(delim), data[:i]
+		} else if atEOF {
+			advance, record = len(data), data
+		} else {
+			// Read more of the input
+			return 0, nil, nil
+		}
+
+		// A nil token tells the scanner to keep going without returning one
+		if record = bytes.Trim(record, "\r\n"); len(record) == 0 {
+			return advance, nil, nil
+		}
+		return advance, record, nil
+	}
+}
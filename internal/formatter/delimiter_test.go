@@ -0,0 +1,86 @@
+package formatter
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSplitOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		delim    string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "nul",
+			delim:    "\x00",
+			input:    "a\x00b\x00",
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "final record without a delimiter",
+			delim:    "\x00",
+			input:    "a\x00b",
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "newlines around records",
+			delim:    "\x00",
+			input:    "a\n\x00\r\nb\nc\n",
+			expected: []string{"a", "b\nc"},
+		},
+		{
+			name:     "multi-byte delimiter",
+			delim:    "--\n",
+			input:    "a--\nb-c--\n",
+			expected: []string{"a", "b-c"},
+		},
+		{
+			name:     "empty records are skipped",
+			delim:    "|",
+			input:    "||a|\n|",
+			expected: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Read a byte at a time so delimiters are split across reads
+			scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(tt.input)))
+			scanner.Split(splitOn([]byte(tt.delim)))
+
+			var records []string
+			for scanner.Scan() {
+				records = append(records, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, records)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithRecordDelimiter(t *testing.T) {
+	input := `{"n":1}` + "\x00" + "{\n  \"n\": 2\n}\n\x00" + "not json\x00" + `{"n":3}`
+
+	formatter, err := NewTemplateFormatter("{n}", WithNoColors(true), WithCompactNonJSON(true), WithRecordDelimiter("\x00"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if expected := "1\n2\n>>> not json\n3\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,68 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DerivedField computes a field from the other fields of a record
+type DerivedField struct {
+	// Name is the top-level field the result is stored in
+	Name string
+	// Template is evaluated against the record, with the same syntax and
+	// functions as the format template
+	Template string
+}
+
+// WithDerivedFields sets fields that are computed before each record is
+// formatted, so the format template can show a value without the logic to
+// work it out, e.g. {{if gt .duration_ms 500.0}}slow{{end}}. Each template's
+// output is stored as a string, replacing any existing value, and fields are
+// computed in order, so later templates can use the results of earlier ones.
+// Skip patterns are matched against the record before fields are derived.
+func WithDerivedFields(fields ...DerivedField) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.derivedFields = append(tf.derivedFields, fields...)
+	}
+}
+
+// derivedTemplate is a DerivedField with its template parsed
+type derivedTemplate struct {
+	name     string
+	template *template.Template
+}
+
+// parseDerivedFields parses the templates of the formatter's derived fields
+// with the given functions
+func (f *TemplateFormatter) parseDerivedFields(funcs template.FuncMap, options PreProcessTemplateOptions) ([]derivedTemplate, error) {
+	parsed := make([]derivedTemplate, 0, len(f.derivedFields))
+	for _, field := range f.derivedFields {
+		if field.Name == "" {
+			return nil, fmt.Errorf("derived field with template %q has no name", field.Template)
+		}
+
+		tmpl, err := newTemplate(funcs)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = tmpl.Parse(PreProcessTemplate(field.Template, options))
+		if err != nil {
+			return nil, fmt.Errorf("derived field %s: %w", field.Name, newTemplateError(field.Template, err, funcNames(funcs)))
+		}
+		parsed = append(parsed, derivedTemplate{name: field.Name, template: tmpl})
+	}
+	return parsed, nil
+}
+
+// applyDerivedFields computes the formatter's derived fields for a record
+func (f *TemplateFormatter) applyDerivedFields(data map[string]interface{}) error {
+	for _, field := range f.derivedTemplates {
+		var buf strings.Builder
+		if err := field.template.Execute(&buf, data); err != nil {
+			return fmt.Errorf("derived field %s: %w", field.name, err)
+		}
+		data[field.name] = buf.String()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithDerivedFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		fields   []DerivedField
+		input    string
+		expected string
+	}{
+		{
+			name:     "simple syntax",
+			format:   "{request} {status}",
+			fields:   []DerivedField{{Name: "request", Template: "{method} {path}"}},
+			input:    `{"method":"GET","path":"/users","status":200}`,
+			expected: "GET /users 200\n",
+		},
+		{
+			name:   "template functions",
+			format: "{message}{speed}",
+			fields: []DerivedField{
+				{Name: "speed", Template: `{{if gt .duration_ms 500.0}} (slow){{end}}`},
+			},
+			input:    `{"message":"done","duration_ms":750}` + "\n" + `{"message":"done","duration_ms":20}`,
+			expected: "done (slow)\ndone\n",
+		},
+		{
+			name:   "later fields use earlier ones",
+			format: "{summary}",
+			fields: []DerivedField{
+				{Name: "seconds", Template: "{duration_ms | mult 0.001 1}"},
+				{Name: "summary", Template: "{message} in {seconds}s"},
+			},
+			input:    `{"message":"done","duration_ms":1500}`,
+			expected: "done in 1.5s\n",
+		},
+		{
+			name:     "replaces an existing field",
+			format:   "{level}",
+			fields:   []DerivedField{{Name: "level", Template: `{level | printf "%.1s"}`}},
+			input:    `{"level":"warn"}`,
+			expected: "w\n",
+		},
+		{
+			name:     "skip patterns see the original record",
+			format:   "{level}",
+			fields:   []DerivedField{{Name: "level", Template: "debug"}},
+			input:    `{"level":"info"}` + "\n" + `{"level":"debug"}`,
+			expected: "debug\n",
+		},
+	}
+
+	skip := []SkipPattern{{Field: "level", Value: "debug"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format, WithNoColors(true), WithDerivedFields(tt.fields...))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(tt.input), &buf, formatter, skip, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestDerivedFieldErrors(t *testing.T) {
+	_, err := NewTemplateFormatter("{msg}", WithDerivedFields(DerivedField{Name: "x", Template: "{{.a | nope}}"}))
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) || !strings.HasPrefix(err.Error(), "derived field x: ") {
+		t.Errorf("Expected a template error for the derived field, got %v", err)
+	}
+
+	if _, err := NewTemplateFormatter("{msg}", WithDerivedFields(DerivedField{Template: "{a}"})); err == nil {
+		t.Error("Expected an error for a derived field without a name")
+	}
+}
@@ -0,0 +1,147 @@
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Styles in which durations can be shown
+const (
+	// DurationStyleShort picks a unit by magnitude, such as 250.00ms, 1.50s
+	// or 1h30m45.123s
+	DurationStyleShort = "short"
+	// DurationStyleLong spells out each unit, such as 1 hour 30 minutes 45.123
+	// seconds
+	DurationStyleLong = "long"
+	// DurationStyleMillis always shows milliseconds, such as 1500.00ms
+	DurationStyleMillis = "ms"
+	// DurationStyleClock shows hours, minutes and seconds like a stopwatch,
+	// such as 01:30:45
+	DurationStyleClock = "clock"
+)
+
+// WithDurationStyle sets how the duration function, and pretty for
+// time.Duration values, show durations: DurationStyleShort (the default),
+// DurationStyleLong, DurationStyleMillis or DurationStyleClock. Rounding set
+// by WithDurationRounding only applies to the short style.
+func WithDurationStyle(style string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.durationStyle = style
+	}
+}
+
+// isDurationStyle reports whether style is one of the duration styles
+func isDurationStyle(style string) bool {
+	switch style {
+	case DurationStyleShort, DurationStyleLong, DurationStyleMillis, DurationStyleClock:
+		return true
+	}
+	return false
+}
+
+// formatDurationStyle formats a duration in one of the duration styles, with
+// short durations rounded if round is set
+func formatDurationStyle(d time.Duration, style string, round bool) string {
+	switch style {
+	case DurationStyleLong:
+		return formatLongDuration(d)
+	case DurationStyleMillis:
+		return fmt.Sprintf("%.2fms", float64(d.Nanoseconds())/float64(time.Millisecond))
+	case DurationStyleClock:
+		return formatClockDuration(d)
+	}
+	if round {
+		return formatRoundedDuration(d)
+	}
+	return formatDuration(d)
+}
+
+// longDurationUnits are the units long durations are spelled out in, largest
+// first. Seconds and smaller units are shown with a fraction instead.
+var longDurationUnits = []struct {
+	size time.Duration
+	name string
+}{
+	{24 * time.Hour, "day"},
+	{time.Hour, "hour"},
+	{time.Minute, "minute"},
+}
+
+// formatLongDuration formats a duration with each unit spelled out
+// For example: 2 days 4 hours, 1 minute 30.5 seconds, 250 milliseconds
+func formatLongDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+
+	switch {
+	case d == 0:
+		return "0 seconds"
+	case d < time.Microsecond:
+		return sign + pluralUnit(strconv.FormatInt(d.Nanoseconds(), 10), "nanosecond")
+	case d < time.Millisecond:
+		return sign + pluralUnit(trimFloat(float64(d)/float64(time.Microsecond), 2), "microsecond")
+	case d < time.Second:
+		return sign + pluralUnit(trimFloat(float64(d)/float64(time.Millisecond), 2), "millisecond")
+	}
+
+	var parts []string
+	for _, unit := range longDurationUnits {
+		if n := d / unit.size; n > 0 {
+			parts = append(parts, pluralUnit(strconv.FormatInt(int64(n), 10), unit.name))
+			d -= n * unit.size
+		}
+	}
+	if seconds := trimFloat(d.Seconds(), 3); seconds != "0" {
+		parts = append(parts, pluralUnit(seconds, "second"))
+	}
+	return sign + strings.Join(parts, " ")
+}
+
+// pluralUnit joins a number and the name of its unit, which is plural unless
+// the number is exactly one
+func pluralUnit(n, unit string) string {
+	if n == "1" {
+		return n + " " + unit
+	}
+	return n + " " + unit + "s"
+}
+
+// trimFloat formats f rounded to at most the given number of decimal places,
+// without trailing zeros
+func trimFloat(f float64, places int) string {
+	scale := math.Pow(10, float64(places))
+	return strconv.FormatFloat(math.Round(f*scale)/scale, 'f', -1, 64)
+}
+
+// formatClockDuration formats a duration as hours, minutes and seconds, with
+// fractions of a second dropped and hours growing past 24 as needed
+// For example: 00:00:05, 01:30:45, 36:00:00
+func formatClockDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, seconds/3600, seconds/60%60, seconds%60)
+}
+
+// durationFmtFunc is a template function that formats a value as a duration
+// in the given style, regardless of the formatter's duration style. Unknown
+// styles use the formatter's style, and values that aren't durations are
+// formatted as by pretty.
+// Example: {elapsed_ms | durationFmt "clock"}
+func (f *TemplateFormatter) durationFmtFunc(style string, value interface{}) string {
+	duration, err := parseDuration(value)
+	if err != nil {
+		return f.prettyFunc(value)
+	}
+	if !isDurationStyle(style) {
+		return f.durationString(duration)
+	}
+	return formatDurationStyle(duration, style, f.roundDurations)
+}
@@ -0,0 +1,134 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDurationStyle(t *testing.T) {
+	durations := []time.Duration{
+		0,
+		500 * time.Nanosecond,
+		12500 * time.Nanosecond,
+		250 * time.Millisecond,
+		time.Second,
+		1500 * time.Millisecond,
+		90*time.Second + 500*time.Millisecond,
+		time.Hour + 30*time.Minute + 45*time.Second + 123*time.Millisecond,
+		52*time.Hour + 10*time.Minute,
+		-5 * time.Second,
+	}
+
+	tests := []struct {
+		style    string
+		expected []string
+	}{
+		{
+			style: DurationStyleShort,
+			expected: []string{
+				"0ns", "500ns", "12.50µs", "250.00ms", "1.00s", "1.50s",
+				"1m30.5s", "1h30m45.123s", "52h10m0s", "-5000000000ns",
+			},
+		},
+		{
+			style: DurationStyleLong,
+			expected: []string{
+				"0 seconds", "500 nanoseconds", "12.5 microseconds", "250 milliseconds",
+				"1 second", "1.5 seconds", "1 minute 30.5 seconds",
+				"1 hour 30 minutes 45.123 seconds", "2 days 4 hours 10 minutes", "-5 seconds",
+			},
+		},
+		{
+			style: DurationStyleMillis,
+			expected: []string{
+				"0.00ms", "0.00ms", "0.01ms", "250.00ms", "1000.00ms", "1500.00ms",
+				"90500.00ms", "5445123.00ms", "187800000.00ms", "-5000.00ms",
+			},
+		},
+		{
+			style: DurationStyleClock,
+			expected: []string{
+				"00:00:00", "00:00:00", "00:00:00", "00:00:00", "00:00:01", "00:00:01",
+				"00:01:30", "01:30:45", "52:10:00", "-00:00:05",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			for i, d := range durations {
+				if result := formatDurationStyle(d, tt.style, false); result != tt.expected[i] {
+					t.Errorf("formatDurationStyle(%v, %q) = %q, want %q", d, tt.style, result, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDurationStyle(t *testing.T) {
+	data := map[string]interface{}{"took": float64(5445123), "name": "worker"}
+
+	tests := []struct {
+		name     string
+		template string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:     "short by default",
+			template: "{took | duration}",
+			expected: "1h30m45.123s",
+		},
+		{
+			name:     "default style",
+			template: "{took | duration}",
+			options:  []FormatterOption{WithDurationStyle(DurationStyleClock)},
+			expected: "01:30:45",
+		},
+		{
+			name:     "rounding only applies to short",
+			template: "{took | duration}",
+			options:  []FormatterOption{WithDurationStyle(DurationStyleLong), WithDurationRounding(true)},
+			expected: "1 hour 30 minutes 45.123 seconds",
+		},
+		{
+			name:     "durationFmt overrides the style",
+			template: `{took | durationFmt "ms"}`,
+			options:  []FormatterOption{WithDurationStyle(DurationStyleClock)},
+			expected: "5445123.00ms",
+		},
+		{
+			name:     "durationFmt short is rounded",
+			template: `{took | durationFmt "short"}`,
+			options:  []FormatterOption{WithDurationStyle(DurationStyleClock), WithDurationRounding(true)},
+			expected: "1h31m",
+		},
+		{
+			name:     "unknown durationFmt style",
+			template: `{took | durationFmt "fancy"}`,
+			options:  []FormatterOption{WithDurationStyle(DurationStyleLong)},
+			expected: "1 hour 30 minutes 45.123 seconds",
+		},
+		{
+			name:     "durationFmt on a value that isn't a duration",
+			template: `{name | durationFmt "clock"}`,
+			expected: "worker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, append(tt.options, WithNoColors(true))...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+			if result, _ := formatter.Format(data); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	if _, err := NewTemplateFormatter("{took | duration}", WithDurationStyle("hms")); err == nil {
+		t.Error("Expected an error for an unknown duration style")
+	}
+}
@@ -0,0 +1,247 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TemplateError describes a template parse failure in terms of the original
+// template, as written by the user, rather than the preprocessed Go template
+type TemplateError struct {
+	// Template is the original, unprocessed template
+	Template string
+	// Line is the 1-based line of the error, or 0 if unknown
+	Line int
+	// Column is the 1-based column of the error, or 0 if unknown
+	Column int
+	// Message is the underlying error message without the template prefix
+	Message string
+	// Err is the original error returned by text/template
+	Err error
+}
+
+// Error renders the error message followed by the offending template line
+// and a caret pointing at the error location, when known
+func (e *TemplateError) Error() string {
+	var builder strings.Builder
+
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		builder.WriteString(fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message))
+	case e.Line > 0:
+		builder.WriteString(fmt.Sprintf("line %d: %s", e.Line, e.Message))
+	default:
+		builder.WriteString(e.Message)
+	}
+
+	lines := strings.Split(e.Template, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		return builder.String()
+	}
+
+	line := lines[e.Line-1]
+	builder.WriteString("\n  ")
+	builder.WriteString(line)
+
+	if e.Column > 0 {
+		// Copy tabs from the source line so the caret lines up
+		builder.WriteString("\n  ")
+		for i, r := range []rune(line) {
+			if i >= e.Column-1 {
+				break
+			}
+			if r == '\t' {
+				builder.WriteRune('\t')
+			} else {
+				builder.WriteRune(' ')
+			}
+		}
+		builder.WriteString("^")
+	}
+
+	return builder.String()
+}
+
+// Unwrap returns the underlying text/template error
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Patterns used to extract details from text/template parse errors
+var (
+	parseErrorRe      = regexp.MustCompile(`^template: [^:]*:(\d+): (.*)$`)
+	undefinedFuncRe   = regexp.MustCompile(`^function "([^"]+)" not defined$`)
+	unexpectedActRe   = regexp.MustCompile(`^unexpected \{\{(\w+)\}\}`)
+	quotedTokenRe     = regexp.MustCompile(`"([^"]+)"`)
+	unclosedActionMsg = []string{"unclosed action", "unexpected EOF", "in operand", "in command"}
+)
+
+// newTemplateError wraps a parse error, mapping its position back to the
+// original template. The preprocessor never adds or removes newlines, so line
+// numbers carry over directly; columns are located on a best-effort basis by
+// searching the original line for the offending token.
+//
+// When the error is an undefined function, the message is augmented with the
+// closest known function name, or the list of available functions.
+func newTemplateError(original string, err error, knownFuncs []string) error {
+	templateErr := &TemplateError{
+		Template: original,
+		Message:  err.Error(),
+		Err:      err,
+	}
+
+	match := parseErrorRe.FindStringSubmatch(err.Error())
+	if match == nil {
+		return templateErr
+	}
+
+	templateErr.Message = match[2]
+	line, convErr := strconv.Atoi(match[1])
+	if convErr != nil {
+		return templateErr
+	}
+	templateErr.Line = line
+
+	lines := strings.Split(original, "\n")
+	if line < 1 || line > len(lines) {
+		return templateErr
+	}
+
+	if offset := findErrorOffset(lines[line-1], templateErr.Message); offset >= 0 {
+		templateErr.Column = utf8.RuneCountInString(lines[line-1][:offset]) + 1
+	}
+
+	if m := undefinedFuncRe.FindStringSubmatch(templateErr.Message); m != nil {
+		if suggestion := suggestFunction(m[1], knownFuncs); suggestion != "" {
+			templateErr.Message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		} else if len(knownFuncs) > 0 {
+			templateErr.Message += "; available functions: " + strings.Join(knownFuncs, ", ")
+		}
+	}
+
+	return templateErr
+}
+
+// builtinFuncs are the functions predefined by text/template
+var builtinFuncs = []string{
+	"and", "call", "html", "index", "js", "len", "not", "or",
+	"print", "println", "slice", "urlquery",
+}
+
+// funcNames returns the sorted names of the custom and built-in template
+// functions, with custom functions shadowing built-ins of the same name
+func funcNames(funcs map[string]interface{}) []string {
+	seen := make(map[string]bool, len(funcs)+len(builtinFuncs))
+	names := make([]string, 0, len(funcs)+len(builtinFuncs))
+	for name := range funcs {
+		seen[name] = true
+		names = append(names, name)
+	}
+	for _, name := range builtinFuncs {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggestFunction returns the known function closest to name by edit
+// distance, or an empty string if none is close enough to be a likely typo
+func suggestFunction(name string, known []string) string {
+	// Allow roughly one edit per three characters, and at least two so a
+	// transposition (e.g. "tabel") is still recognized
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	best := ""
+	bestDistance := maxDistance + 1
+	for _, candidate := range known {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// findErrorOffset returns the byte offset within line most likely to be the
+// cause of the error message, or -1 if no location could be determined
+func findErrorOffset(line, message string) int {
+	if m := undefinedFuncRe.FindStringSubmatch(message); m != nil {
+		return findIdentifier(line, m[1])
+	}
+
+	if m := unexpectedActRe.FindStringSubmatch(message); m != nil {
+		re := regexp.MustCompile(`\{\{-?\s*` + regexp.QuoteMeta(m[1]) + `\s*-?\}\}`)
+		if loc := re.FindStringIndex(line); loc != nil {
+			return loc[0]
+		}
+	}
+
+	for _, msg := range unclosedActionMsg {
+		if strings.Contains(message, msg) {
+			if offset := findUnclosedAction(line); offset >= 0 {
+				return offset
+			}
+			break
+		}
+	}
+
+	if m := quotedTokenRe.FindStringSubmatch(message); m != nil {
+		return strings.LastIndex(line, m[1])
+	}
+
+	return -1
+}
+
+// findIdentifier returns the offset of the first occurrence of name in line
+// that isn't part of a longer identifier, or -1 if there is none
+func findIdentifier(line, name string) int {
+	re := regexp.MustCompile(`(^|[^\w.])(` + regexp.QuoteMeta(name) + `)($|[^\w])`)
+	if loc := re.FindStringSubmatchIndex(line); loc != nil {
+		return loc[4]
+	}
+	return -1
+}
+
+// findUnclosedAction returns the offset of the last "{{" in line that has no
+// matching "}}" after it, or -1 if every action is closed
+func findUnclosedAction(line string) int {
+	start := strings.LastIndex(line, "{{")
+	if start < 0 || strings.Contains(line[start:], "}}") {
+		return -1
+	}
+	return start
+}
@@ -0,0 +1,140 @@
+package formatter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTemplateParseErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         string
+		expectedLine   int
+		expectedColumn int
+		expectedError  string
+	}{
+		{
+			name:           "unclosed action",
+			format:         "{{.level}} {{.message",
+			expectedLine:   1,
+			expectedColumn: 12,
+			expectedError:  "line 1, column 12: unclosed action\n  {{.level}} {{.message\n             ^",
+		},
+		{
+			name:           "unknown function in simple syntax",
+			format:         `{level} {message | colr "red"}`,
+			expectedLine:   1,
+			expectedColumn: 20,
+			expectedError:  "line 1, column 20: function \"colr\" not defined (did you mean \"color\"?)\n  {level} {message | colr \"red\"}\n                     ^",
+		},
+		{
+			name:           "unknown function on second line",
+			format:         "{{.level}}\n\t{{.message | tabel}}",
+			expectedLine:   2,
+			expectedColumn: 15,
+			expectedError:  "line 2, column 15: function \"tabel\" not defined (did you mean \"table\"?)\n  \t{{.message | tabel}}\n  \t             ^",
+		},
+		{
+			name:           "unexpected end",
+			format:         "{{.level}} {{ end }}",
+			expectedLine:   1,
+			expectedColumn: 12,
+			expectedError:  "line 1, column 12: unexpected {{end}}\n  {{.level}} {{ end }}\n             ^",
+		},
+		{
+			name:           "location unknown",
+			format:         "{{if .level}}x",
+			expectedLine:   1,
+			expectedColumn: 0,
+			expectedError:  "line 1: unexpected EOF\n  {{if .level}}x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTemplateFormatter(tt.format)
+			if err == nil {
+				t.Fatalf("Expected parse error for %q", tt.format)
+			}
+
+			var templateErr *TemplateError
+			if !errors.As(err, &templateErr) {
+				t.Fatalf("Expected *TemplateError, got %T", err)
+			}
+
+			if templateErr.Line != tt.expectedLine {
+				t.Errorf("Line = %d, want %d", templateErr.Line, tt.expectedLine)
+			}
+			if templateErr.Column != tt.expectedColumn {
+				t.Errorf("Column = %d, want %d", templateErr.Column, tt.expectedColumn)
+			}
+			if templateErr.Template != tt.format {
+				t.Errorf("Template = %q, want %q", templateErr.Template, tt.format)
+			}
+			if err.Error() != tt.expectedError {
+				t.Errorf("Expected error:\n%s\n\nGot:\n%s", tt.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestUnknownFunctionSuggestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		contains string
+	}{
+		{
+			name:     "single typo",
+			format:   `{{.level | colr "red"}}`,
+			contains: `(did you mean "color"?)`,
+		},
+		{
+			name:     "case mismatch",
+			format:   `{{.message | colorbylevel .level}}`,
+			contains: `(did you mean "colorByLevel"?)`,
+		},
+		{
+			name:     "built-in function typo",
+			format:   `{{prnt .level}}`,
+			contains: `(did you mean "print"?)`,
+		},
+		{
+			name:     "no close match lists functions",
+			format:   `{{.level | frobnicate}}`,
+			contains: "; available functions: after, and, args, badgeByLevel, before, between,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTemplateFormatter(tt.format)
+			if err == nil {
+				t.Fatalf("Expected parse error for %q", tt.format)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error to contain %q, got:\n%s", tt.contains, err.Error())
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"color", "color", 0},
+		{"colr", "color", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.expected {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
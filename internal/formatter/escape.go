@@ -0,0 +1,25 @@
+package formatter
+
+import "strings"
+
+// newlineEscaper replaces the control characters escaped by
+// WithEscapeNewlines with their backslash escapes
+var newlineEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// WithEscapeNewlines makes ProcessStream replace newlines, carriage returns
+// and tabs in string values with the escapes \n, \r and \t before each record
+// is formatted, so a multi-line value such as a stack trace stays on one line
+// for line-oriented tools. Strings in nested maps and arrays are escaped too,
+// as are fields computed with WithDerivedFields. Newlines in the template
+// itself are kept; use WithSingleLine to join those.
+func WithEscapeNewlines(escape bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.escapeNewlines = escape
+	}
+}
+
+// escapeFields escapes the newlines, carriage returns and tabs in the string
+// values of data, and of any maps or arrays it contains
+func escapeFields(data map[string]interface{}) {
+	mapStrings(data, newlineEscaper.Replace)
+}
@@ -0,0 +1,77 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscapeFields(t *testing.T) {
+	data := map[string]interface{}{
+		"message": "line one\nline two",
+		"windows": "a\r\nb",
+		"tabbed":  "key\tvalue",
+		"path":    `C:\logs`,
+		"count":   float64(3),
+		"error": map[string]interface{}{
+			"stack":  "main.main()\n\tmain.go:12",
+			"frames": []interface{}{"a\nb", float64(1), map[string]interface{}{"func": "x\ty"}},
+		},
+	}
+	expected := map[string]interface{}{
+		"message": `line one\nline two`,
+		"windows": `a\r\nb`,
+		"tabbed":  `key\tvalue`,
+		"path":    `C:\logs`,
+		"count":   float64(3),
+		"error": map[string]interface{}{
+			"stack":  `main.main()\n\tmain.go:12`,
+			"frames": []interface{}{`a\nb`, float64(1), map[string]interface{}{"func": `x\ty`}},
+		},
+	}
+
+	escapeFields(data)
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+}
+
+func TestProcessStreamWithEscapeNewlines(t *testing.T) {
+	input := `{"level":"error","message":"failed\nretrying","ctx":{"stack":"a\n\tb"}}` + "\n"
+
+	tests := []struct {
+		name     string
+		escape   bool
+		expected string
+	}{
+		{
+			name:     "disabled",
+			expected: "error\nfailed\nretrying {stack=a\n\tb}\n",
+		},
+		{
+			name:     "enabled",
+			escape:   true,
+			expected: "error\nfailed\\nretrying {stack=a\\n\\tb}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The newline in the template is kept either way
+			formatter, err := NewTemplateFormatter("{level}\n{message} {ctx | pretty}",
+				WithNoColors(true), WithEscapeNewlines(tt.escape))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
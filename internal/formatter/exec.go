@@ -0,0 +1,108 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultFieldCommandTimeout is how long a field command may run before it
+// is killed and the raw value is used instead
+const DefaultFieldCommandTimeout = 2 * time.Second
+
+// fieldCommandWaitDelay is how long to wait for a killed command's output
+// pipes to close, in case it started processes that outlive it
+const fieldCommandWaitDelay = 100 * time.Millisecond
+
+// FieldCommand pipes the value of a top-level field through an external
+// command, replacing it with the command's output
+type FieldCommand struct {
+	// Field is the name of the field to transform
+	Field string
+	// Command is run by the shell, with the value on stdin
+	Command string
+}
+
+// WithFieldCommands sets commands that transform field values before each
+// record is formatted, such as pretty-printing SQL. The value is passed on
+// stdin, never on the command line, and is replaced by the command's output
+// with trailing newlines removed. If the command fails or times out, the raw
+// value is kept.
+//
+// Commands run with the privileges of the current process, so they should
+// only ever come from a trusted source.
+func WithFieldCommands(commands ...FieldCommand) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.fieldCommands = append(tf.fieldCommands, commands...)
+	}
+}
+
+// WithFieldCommandTimeout sets how long each field command may run, which
+// defaults to DefaultFieldCommandTimeout
+func WithFieldCommandTimeout(timeout time.Duration) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.fieldCommandTimeout = timeout
+	}
+}
+
+// applyFieldCommands replaces the values of fields that have a command with
+// the command's output
+func (f *TemplateFormatter) applyFieldCommands(ctx context.Context, data map[string]interface{}) {
+	for _, command := range f.fieldCommands {
+		value, ok := data[command.Field]
+		if !ok || value == nil {
+			continue
+		}
+
+		if output, err := f.runFieldCommand(ctx, command.Command, fieldCommandInput(value)); err == nil {
+			data[command.Field] = output
+		}
+	}
+}
+
+// runFieldCommand runs a shell command with input on stdin, returning its
+// output without trailing newlines
+func (f *TemplateFormatter) runFieldCommand(ctx context.Context, command, input string) (string, error) {
+	timeout := f.fieldCommandTimeout
+	if timeout <= 0 {
+		timeout = DefaultFieldCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.WaitDelay = fieldCommandWaitDelay
+	cmd.Stdin = strings.NewReader(input)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("field command %q failed: %w", command, err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// fieldCommandInput converts a field value to the text passed to a command,
+// encoding objects and arrays as JSON
+func fieldCommandInput(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		if encoded, err := json.Marshal(v); err == nil {
+			return string(encoded)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
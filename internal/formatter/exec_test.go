@@ -0,0 +1,77 @@
+package formatter
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("field command tests use a POSIX shell")
+	}
+
+	tests := []struct {
+		name     string
+		commands []FieldCommand
+		timeout  time.Duration
+		input    string
+		expected string
+	}{
+		{
+			name:     "replaces value with output",
+			commands: []FieldCommand{{Field: "query", Command: "tr a-z A-Z"}},
+			input:    `{"level":"info","query":"select 1"}`,
+			expected: "info SELECT 1\n",
+		},
+		{
+			name:     "objects are passed as JSON",
+			commands: []FieldCommand{{Field: "query", Command: "cat"}},
+			input:    `{"level":"info","query":{"a":1}}`,
+			expected: "info {\"a\":1}\n",
+		},
+		{
+			name:     "failing command keeps raw value",
+			commands: []FieldCommand{{Field: "query", Command: "echo oops; exit 1"}},
+			input:    `{"level":"info","query":"select 1"}`,
+			expected: "info select 1\n",
+		},
+		{
+			name:     "timeout keeps raw value",
+			commands: []FieldCommand{{Field: "query", Command: "sleep 5"}},
+			timeout:  50 * time.Millisecond,
+			input:    `{"level":"info","query":"select 1"}`,
+			expected: "info select 1\n",
+		},
+		{
+			name:     "missing field is ignored",
+			commands: []FieldCommand{{Field: "other", Command: "echo replaced"}},
+			input:    `{"level":"info","query":"select 1"}`,
+			expected: "info select 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewTemplateFormatterWithOptions("{{.level}} {{.query}}", DefaultPreProcessTemplateOptions(),
+				WithNoColors(true), WithFieldCommands(tt.commands...), WithFieldCommandTimeout(tt.timeout))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var out bytes.Buffer
+			start := time.Now()
+			if err := f.ProcessStream(strings.NewReader(tt.input), &out, f, nil, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out.String())
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Field command ran for %s, expected it to be killed", elapsed)
+			}
+		})
+	}
+}
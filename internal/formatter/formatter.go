@@ -2,14 +2,23 @@ package formatter
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
 )
@@ -31,12 +40,71 @@ type TemplateFormatter struct {
 	template         *template.Template
 	preferredDateFmt string
 	noColors         bool
+	colorDepth       string
+	styleAliases     map[string][]string
+	styleCodes       map[string]string
+	customFuncs      template.FuncMap
+	kvSeparator      *string
+	inputParser      InputParser
+	mapSort          string
+	compactNonJSON   bool
+	levelWriter      io.Writer
+	levelWriterMin   string
+	comments         string
+	commentPrefix    string
+	normalizeKeys    string
+	liftFields       []string
+	liftOverwrite    bool
+	levelField       string
+	timeLayout       string
+	supportsColor    func(w io.Writer) bool
+	strictEquality   bool
+
+	fieldCommands       []FieldCommand
+	derivedFields       []DerivedField
+	derivedTemplates    []derivedTemplate
+	fieldCommandTimeout time.Duration
+	fieldCounter        *FieldCounter
+	maxFieldLength      int
+	escapeNewlines      bool
+	recordDelimiter     string
+	highlightRe         *regexp.Regexp
+	highlightStyles     []string
+	highlightCode       string
+	linePrefix          string
+	lineSuffix          string
+	prefixTemplate      *template.Template
+	suffixTemplate      *template.Template
+	prettyMaxDepth      int
+	singleLineSep       *string
+	smartFields         []smartField
+	indent              *int
+	tableOmit           func(key string, val interface{}) bool
+	roundDurations      bool
+	durationStyle       string
+	replaySpeed         float64
+	replayMaxGap        time.Duration
+	head                int
+	tail                int
+	sampleEvery         int
+	sampleRate          float64
+	sampleSeed          uint64
+	columns             []string
+	columnsBatch        int
+	uniqueBy            []string
+	uniqueCap           int
+	groupBy             string
+	skipCheckRecords    int
+	skipCheckReport     func(records int, fields []string)
+	clock               func() time.Time
 }
 
 // FormatterOption is a functional option for configuring the formatter
 type FormatterOption func(*TemplateFormatter)
 
 // WithPreferredDateFormat sets the preferred date format for the date function
+// The format may be a Go reference time layout ("2006-01-02 15:04:05") or, if
+// it contains a %, a strftime-style format ("%Y-%m-%d %H:%M:%S")
 func WithPreferredDateFormat(format string) FormatterOption {
 	return func(tf *TemplateFormatter) {
 		tf.preferredDateFmt = format
@@ -50,6 +118,209 @@ func WithNoColors(noColors bool) FormatterOption {
 	}
 }
 
+// WithColorDepth limits hex and 256-color palette colors to those a terminal
+// can show, replacing each with the nearest color it supports. The depth is
+// ColorDepth16, ColorDepth256, ColorDepthTrueColor, or ColorDepthAuto to
+// detect it from the TERM and COLORTERM environment variables. By default
+// colors are written as given.
+func WithColorDepth(depth string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.colorDepth = depth
+	}
+}
+
+// WithKVSeparator sets the separator written between keys and values by the
+// pretty, table and tree functions. By default pretty renders maps as
+// key=value, while tables separate keys from values with padding only.
+func WithKVSeparator(separator string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.kvSeparator = &separator
+	}
+}
+
+// Orders in which pretty, table and tree render the fields of a map
+const (
+	// MapSortKey orders fields alphabetically by key
+	MapSortKey = "key"
+	// MapSortValue orders fields by value, smallest first
+	MapSortValue = "value"
+	// MapSortValueDesc orders fields by value, largest first
+	MapSortValueDesc = "value_desc"
+)
+
+// WithMapSort sets the order in which pretty, table and tree render the fields
+// of a map: MapSortKey (the default), MapSortValue or MapSortValueDesc. Values
+// are compared numerically when both are numbers, and as strings otherwise.
+func WithMapSort(order string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.mapSort = order
+	}
+}
+
+// DefaultIndent is the number of spaces table, tree and list rows are
+// indented by, unless changed with WithIndent
+const DefaultIndent = 2
+
+// WithIndent sets the number of spaces table, tree and list rows are indented
+// by, and the default for the indent function. Nested sub-tables are indented
+// by two more spaces per level.
+func WithIndent(spaces int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		spaces = max(spaces, 0)
+		tf.indent = &spaces
+	}
+}
+
+// indentWidth returns the number of spaces table rows are indented by
+func (f *TemplateFormatter) indentWidth() int {
+	if f.indent == nil {
+		return DefaultIndent
+	}
+	return *f.indent
+}
+
+// WithTableOmitFunc sets the predicate table and tree use to decide which
+// fields are empty. It is called with the key and value of each field,
+// including the fields of nested sub-tables, and fields it returns true for
+// are left out, or shown as the placeholder if one is given. By default nil
+// values and empty strings are empty.
+func WithTableOmitFunc(omit func(key string, val interface{}) bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.tableOmit = omit
+	}
+}
+
+// isEmptyTableValue reports whether table and tree treat a field as empty
+func (f *TemplateFormatter) isEmptyTableValue(key string, val interface{}) bool {
+	if f.tableOmit != nil {
+		return f.tableOmit(key, val)
+	}
+	if val == nil {
+		return true
+	}
+	str, ok := val.(string)
+	return ok && str == ""
+}
+
+// WithPrettyMaxDepth limits how deeply pretty renders nested maps and arrays.
+// Structures nested more than depth levels deep are shown as {…} or […], so
+// deeply nested payloads stay readable. Zero, the default, means no limit.
+func WithPrettyMaxDepth(depth int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.prettyMaxDepth = depth
+	}
+}
+
+// WithDurationRounding makes the duration function, and pretty for
+// time.Duration values, round durations of ten seconds or more to their two
+// most significant units, such as 1h30m or 45.1s, instead of showing them in
+// full like 1h30m45.123s. Shorter durations are shown as before, as are
+// durations shown in a style other than DurationStyleShort.
+func WithDurationRounding(round bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.roundDurations = round
+	}
+}
+
+// WithCompactNonJSON controls whether ProcessStream omits the blank lines it
+// normally writes before and after each block of non-JSON lines
+func WithCompactNonJSON(compact bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.compactNonJSON = compact
+	}
+}
+
+// WithSingleLine makes ProcessStream write each record on a single line, so
+// output from multi-line templates, such as ones using table or wrap, can be
+// searched with grep. The lines of each formatted record are joined with
+// separator, dropping blank lines. Colors are kept.
+func WithSingleLine(separator string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.singleLineSep = &separator
+	}
+}
+
+// WithStrictEquality makes eq, ne, in and notIn type-sensitive. By default
+// numbers and numeric strings are compared by value, so eq "007" 7 is true;
+// with strict equality a string never equals a number, and strings are
+// compared exactly.
+func WithStrictEquality(strict bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.strictEquality = strict
+	}
+}
+
+// WithKeyNormalization rewrites the separators in the keys of each record
+// read by ProcessStream, before skip patterns and the template are applied.
+// KeyNormalizeDot converts underscores to dots, and KeyNormalizeUnderscore
+// converts dots to underscores, so one template works across services with
+// different key conventions. An empty mode leaves keys unchanged.
+func WithKeyNormalization(mode string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.normalizeKeys = mode
+	}
+}
+
+// WithLevelNormalization sets a canonical, lowercase level field on each
+// record read by ProcessStream, derived from the value of field. GCP
+// severities such as WARNING and numeric syslog severities such as 4 are
+// mapped to the names colorByLevel understands, e.g. warn and warning. The
+// source field is left unchanged, and an empty field disables normalization.
+func WithLevelNormalization(field string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.levelField = field
+	}
+}
+
+// WithTimeNormalization rewrites the time of each record read by
+// ProcessStream in place, in UTC and formatted with layout, a Go layout or a
+// strftime format. TimeNormalizeRFC3339 writes RFC 3339 with as much
+// precision as the time has. The time is read from the first of the
+// timestamp, time, ts and @timestamp fields that holds one, in any format
+// the date function understands, and other records are left unchanged. An
+// empty layout disables normalization.
+func WithTimeNormalization(layout string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		if layout == TimeNormalizeRFC3339 {
+			layout = time.RFC3339Nano
+		}
+		tf.timeLayout = layout
+	}
+}
+
+// WithInputParser sets the parser used by ProcessStream to decode each line
+// of input. The default parses JSON objects.
+func WithInputParser(parser InputParser) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		if parser != nil {
+			tf.inputParser = parser
+		}
+	}
+}
+
+// WithClock sets the function used to get the current time, such as when
+// inferring the year of BSD syslog timestamps. It defaults to time.Now, and is
+// mostly useful for making time-dependent output reproducible in tests.
+func WithClock(clock func() time.Time) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.clock = clock
+	}
+}
+
+// WithFuncs registers additional template functions. Custom functions take
+// precedence over built-in functions of the same name, and later calls
+// override functions registered by earlier ones.
+func WithFuncs(funcs template.FuncMap) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		if tf.customFuncs == nil {
+			tf.customFuncs = make(template.FuncMap, len(funcs))
+		}
+		for name, fn := range funcs {
+			tf.customFuncs[name] = fn
+		}
+	}
+}
+
 // No longer needed as the filter function can be used directly in templates
 
 // (WithTableKeyPadding removed - padding is now a parameter to the table function)
@@ -57,11 +328,12 @@ func WithNoColors(noColors bool) FormatterOption {
 // NewTemplateFormatterWithOptions creates a new TemplateFormatter with the given format string and preprocessing options
 func NewTemplateFormatterWithOptions(format string, preprocessOptions PreProcessTemplateOptions, opts ...FormatterOption) (*TemplateFormatter, error) {
 	// Process template with shortcuts via the preprocessor
-	format = PreProcessTemplate(format, preprocessOptions)
+	processed := PreProcessTemplate(format, preprocessOptions)
 
 	// Create the formatter with default values
 	formatter := &TemplateFormatter{
 		preferredDateFmt: "2006-01-02 15:04:05",
+		inputParser:      JSONParser{},
 	}
 
 	// Apply options
@@ -69,84 +341,294 @@ func NewTemplateFormatterWithOptions(format string, preprocessOptions PreProcess
 		opt(formatter)
 	}
 
+	// Let the syslog parser infer years from the formatter's clock
+	if parser, ok := formatter.inputParser.(SyslogParser); ok && parser.now == nil {
+		parser.now = formatter.now
+		formatter.inputParser = parser
+	}
+
+	switch formatter.normalizeKeys {
+	case "", KeyNormalizeDot, KeyNormalizeUnderscore:
+	default:
+		return nil, fmt.Errorf("unknown key normalization %q (expected %s or %s)",
+			formatter.normalizeKeys, KeyNormalizeDot, KeyNormalizeUnderscore)
+	}
+
+	switch formatter.colorDepth {
+	case "", ColorDepth16, ColorDepth256, ColorDepthTrueColor:
+	case ColorDepthAuto:
+		formatter.colorDepth = DetectColorDepth(os.Getenv("TERM"), os.Getenv("COLORTERM"))
+	default:
+		return nil, fmt.Errorf("unknown color depth %q (expected %s, %s, %s or %s)",
+			formatter.colorDepth, ColorDepth16, ColorDepth256, ColorDepthTrueColor, ColorDepthAuto)
+	}
+
+	styleCodes, err := resolveStyleAliases(formatter.styleAliases, formatter.colorDepth)
+	if err != nil {
+		return nil, err
+	}
+	formatter.styleCodes = styleCodes
+	if err := formatter.resolveHighlight(); err != nil {
+		return nil, err
+	}
+
+	if err := formatter.checkLevelWriter(); err != nil {
+		return nil, err
+	}
+
+	switch formatter.comments {
+	case "", CommentsPass, CommentsDrop:
+	default:
+		return nil, fmt.Errorf("unknown comment handling %q (expected %s or %s)",
+			formatter.comments, CommentsPass, CommentsDrop)
+	}
+
+	if formatter.durationStyle != "" && !isDurationStyle(formatter.durationStyle) {
+		return nil, fmt.Errorf("unknown duration style %q (expected %s, %s, %s or %s)", formatter.durationStyle,
+			DurationStyleShort, DurationStyleLong, DurationStyleMillis, DurationStyleClock)
+	}
+
+	switch formatter.mapSort {
+	case "", MapSortKey, MapSortValue, MapSortValueDesc:
+	default:
+		return nil, fmt.Errorf("unknown map sort order %q (expected %s, %s or %s)",
+			formatter.mapSort, MapSortKey, MapSortValue, MapSortValueDesc)
+	}
+
+	smartFields, err := prepareSmartFields(formatter.smartFields)
+	if err != nil {
+		return nil, err
+	}
+	formatter.smartFields = smartFields
+
 	// Create wrapper for table function to ensure backward compatibility
 	tableWrapper := func(args ...interface{}) string {
 		switch len(args) {
 		case 0:
 			return ""
 		case 1:
-			return formatter.tableFunc(nil, args[0])
+			return formatter.tableFunc(nil, nil, args[0])
+		case 2:
+			// First argument is the padding, last is the data
+			return formatter.tableFunc(args[0], nil, args[1])
 		default:
 			// Last argument is the data
 			data := args[len(args)-1]
-			// First argument is the padding
-			padding := args[0]
-			return formatter.tableFunc(padding, data)
+			// First argument is the padding, second is the empty placeholder
+			return formatter.tableFunc(args[0], args[1], data)
 		}
 	}
 
 	// Create template with custom functions
-	tmpl := template.New("formatter").Funcs(template.FuncMap{
+	funcs := template.FuncMap{
 		// Value formatting
-		"date":     formatter.dateFunc,
-		"pad":      formatter.padFunc,
-		"pretty":   formatter.prettyFunc,
-		"table":    tableWrapper,
-		"duration": formatter.durationFunc,
-		"wrap":     formatter.wrapFunc,
-		"trunc":    formatter.truncFunc,
-		"mult":     formatter.multFunc,
-		"printf":   formatter.printfFunc,
+		"date":        formatter.dateFunc,
+		"pad":         formatter.padFunc,
+		"padLeft":     formatter.padLeftFunc,
+		"fill":        formatter.fillFunc,
+		"pretty":      formatter.prettyFunc,
+		"table":       tableWrapper,
+		"tree":        formatter.treeFunc,
+		"list":        formatter.listFunc,
+		"duration":    formatter.durationFunc,
+		"durationFmt": formatter.durationFmtFunc,
+		"wrap":        formatter.wrapFunc,
+		"indent":      formatter.indentFunc,
+		"trunc":       formatter.truncFunc,
+		"mult":        formatter.multFunc,
+		"percent":     formatter.percentFunc,
+		"delta":       formatter.deltaFunc,
+		"printf":      formatter.printfFunc,
+		"parseJSON":   formatter.parseJSONFunc,
+		"sparkline":   formatter.sparklineFunc,
+		"lookup":      formatter.lookupFunc,
+		"shortHash":   formatter.shortHashFunc,
+
+		// String trimming
+		"trimPrefix": formatter.trimPrefixFunc,
+		"trimSuffix": formatter.trimSuffixFunc,
+		"trimSpace":  formatter.trimSpaceFunc,
+
+		// Escaping for other tools
+		"mdEscape":   formatter.mdEscapeFunc,
+		"shellQuote": formatter.shellQuoteFunc,
 
 		// Comparison functions
-		"eq": formatter.eqFunc,
-		"ne": formatter.neFunc,
-		"gt": formatter.gtFunc,
-		"lt": formatter.ltFunc,
+		"eq":      formatter.eqFunc,
+		"ne":      formatter.neFunc,
+		"gt":      formatter.gtFunc,
+		"lt":      formatter.ltFunc,
+		"gte":     formatter.gteFunc,
+		"lte":     formatter.lteFunc,
+		"in":      formatter.inFunc,
+		"notIn":   formatter.notInFunc,
+		"between": formatter.betweenFunc,
+
+		// Time comparison functions
+		"before": formatter.beforeFunc,
+		"after":  formatter.afterFunc,
 
 		// Field existence checking
-		"isset": formatter.issetFunc,
+		"isset":  formatter.issetFunc,
+		"truthy": formatter.truthyFunc,
+		"empty":  formatter.emptyFunc,
+
+		// Process environment
+		"env": formatter.envFunc,
 
 		// Color functions
 		"color":        formatter.colorFunc,
 		"colorByLevel": formatter.colorByLevelFunc,
+		"level":        formatter.levelFunc,
+		"badgeByLevel": formatter.badgeByLevelFunc,
+		"colorByHash":  formatter.colorByHashFunc,
 		"bold":         formatter.boldFunc,
 		"italic":       formatter.italicFunc,
 		"underline":    formatter.underlineFunc,
 		"dim":          formatter.dimFunc,
 
 		// Field filtering and categorization
-		"hasPrefix": formatter.hasPrefixFunc,
-		"filter":    formatter.filterFunc,
-	})
+		"hasPrefix":  formatter.hasPrefixFunc,
+		"filter":     formatter.filterFunc,
+		"merge":      formatter.mergeFunc,
+		"renameKeys": formatter.renameKeysFunc,
+		"args":       formatter.argsFunc,
+		"sprintf":    formatter.sprintfFunc,
+	}
 
-	parsed, err := tmpl.Parse(format)
+	// Custom functions override built-ins
+	for name, fn := range formatter.customFuncs {
+		funcs[name] = fn
+	}
+
+	tmpl, err := newTemplate(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := tmpl.Parse(processed)
+	if err != nil {
+		return nil, newTemplateError(format, err, funcNames(funcs))
+	}
+
+	derived, err := formatter.parseDerivedFields(funcs, preprocessOptions)
 	if err != nil {
 		return nil, err
 	}
+	if formatter.prefixTemplate, err = parseLineAffix("prefix", formatter.linePrefix, funcs, preprocessOptions); err != nil {
+		return nil, err
+	}
+	if formatter.suffixTemplate, err = parseLineAffix("suffix", formatter.lineSuffix, funcs, preprocessOptions); err != nil {
+		return nil, err
+	}
 
 	formatter.template = parsed
+	formatter.derivedTemplates = derived
 	return formatter, nil
 }
 
+// now returns the current time from the formatter's clock
+func (f *TemplateFormatter) now() time.Time {
+	if f.clock != nil {
+		return f.clock()
+	}
+	return time.Now()
+}
+
+// newTemplate creates an empty template with the given functions, returning
+// an error rather than panicking if a custom function has an invalid signature
+func newTemplate(funcs template.FuncMap) (tmpl *template.Template, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid template function: %v", r)
+		}
+	}()
+	return template.New("formatter").Funcs(funcs), nil
+}
+
 // NewTemplateFormatter creates a new TemplateFormatter with the given format string
 func NewTemplateFormatter(format string, opts ...FormatterOption) (*TemplateFormatter, error) {
 	return NewTemplateFormatterWithOptions(format, DefaultPreProcessTemplateOptions(), opts...)
 }
 
 // padFunc is a template function that pads a string to a specified length
+// Positive lengths left-align the text by padding on the right, while negative
+// lengths right-align it by padding on the left
 func (f *TemplateFormatter) padFunc(length int, value interface{}) string {
+	if length < 0 {
+		return f.padLeftFunc(-length, value)
+	}
+	return padString(value, length, false)
+}
+
+// padLeftFunc is a template function that right-aligns a string by padding it
+// on the left to a specified length, which lines up numeric columns
+// Usage: {{.count | padLeft 8}}
+func (f *TemplateFormatter) padLeftFunc(length int, value interface{}) string {
+	if length < 0 {
+		length = -length
+	}
+	return padString(value, length, true)
+}
+
+// fillFunc is a template function that joins two values with a run of fill
+// characters, so the result is the given visible width, such as for dotted
+// leaders between keys and values. The fill defaults to "." and may be several
+// characters, which are repeated and cut to fit. ANSI escape codes don't count
+// towards the width. If the values don't leave room for any fill, they are
+// separated by a single space.
+// Usage: {{fill 30 .key .value "."}}
+func (f *TemplateFormatter) fillFunc(width int, left, right interface{}, fill ...string) string {
+	leftStr, rightStr := fillString(left), fillString(right)
+	pattern := "."
+	if len(fill) > 0 && fill[0] != "" {
+		pattern = fill[0]
+	}
+
+	gap := width - visibleWidth(leftStr) - visibleWidth(rightStr)
+	if gap <= 0 {
+		return leftStr + " " + rightStr
+	}
+
+	var b strings.Builder
+	b.WriteString(leftStr)
+	runes := []rune(pattern)
+	for i := range gap {
+		b.WriteRune(runes[i%len(runes)])
+	}
+	b.WriteString(rightStr)
+	return b.String()
+}
+
+// fillString returns the text of a value for fill, with nil as empty text
+func fillString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// padString pads a value with spaces to the given visible width. ANSI escape
+// codes don't count towards the width, so colored values align correctly.
+// Unlike the pad template function, a negative width doesn't change the
+// alignment and adds no padding, so computed widths can be passed directly.
+func padString(value interface{}, length int, left bool) string {
+	length = max(length, 0)
 	if value == nil {
 		return strings.Repeat(" ", length)
 	}
 
 	str := fmt.Sprintf("%v", value)
-	if len(str) >= length {
+	width := visibleWidth(str)
+	if width >= length {
 		return str
 	}
 
-	// Add whitespace padding to the right of the string
-	return str + strings.Repeat(" ", length-len(str))
+	padding := strings.Repeat(" ", length-width)
+	if left {
+		return padding + str
+	}
+	return str + padding
 }
 
 // dateFunc is a template function that parses various date formats and outputs a standard format
@@ -155,60 +637,131 @@ func (f *TemplateFormatter) dateFunc(value interface{}) string {
 		return ""
 	}
 
+	if t, ok := parseTimestamp(value); ok {
+		return formatTime(t, f.preferredDateFmt)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Common date formats understood by parseTimestamp
+var dateFormats = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"Mon Jan 2 15:04:05 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"Jan 2 15:04:05",
+	"Jan 2 15:04:05 2006",
+	"02/Jan/2006:15:04:05 -0700", // Common log format
+}
+
+// parseTimestamp attempts to interpret a value as a point in time
+// Strings are matched against common date formats, numbers are treated as
+// Unix timestamps whose unit (s, ms, µs or ns) is inferred from their magnitude
+func parseTimestamp(value interface{}) (time.Time, bool) {
 	switch v := value.(type) {
+	case time.Time:
+		return v, true
 	case string:
-		// Try parsing common formats
-		formats := []string{
-			time.RFC3339,
-			time.RFC3339Nano,
-			"2006-01-02T15:04:05.999999999",
-			"2006-01-02T15:04:05",
-			"2006-01-02 15:04:05",
-			"2006-01-02",
-			"Mon Jan 2 15:04:05 2006",
-			"Mon Jan 2 15:04:05 MST 2006",
-			"Jan 2 15:04:05",
-			"Jan 2 15:04:05 2006",
-			"02/Jan/2006:15:04:05 -0700", // Common log format
-		}
-
-		for _, format := range formats {
+		for _, format := range dateFormats {
 			if t, err := time.Parse(format, v); err == nil {
-				return t.Format(f.preferredDateFmt)
+				return t, true
 			}
 		}
-		return v
 	case json.Number:
 		// Try parsing as Unix timestamp
 		if i, err := v.Int64(); err == nil {
-			return time.Unix(i, 0).Format(f.preferredDateFmt)
+			return unixTime(i), true
 		}
-		// Try parsing as Unix timestamp with fractional seconds
+		// Try parsing as Unix timestamp with fractional part
 		if floatVal, err := v.Float64(); err == nil {
-			sec := int64(floatVal)
-			nsec := int64((floatVal - float64(sec)) * 1e9)
-			return time.Unix(sec, nsec).Format(f.preferredDateFmt)
+			return unixTimeFloat(floatVal), true
 		}
-		return v.String()
+	case int:
+		return unixTime(int64(v)), true
 	case int64:
-		return time.Unix(v, 0).Format(f.preferredDateFmt)
+		return unixTime(v), true
 	case float64:
+		return unixTimeFloat(v), true
+	}
+
+	return time.Time{}, false
+}
+
+// Thresholds used to infer the unit of a numeric Unix timestamp. Values up to
+// 1e11 are seconds (until the year 5138), then milliseconds, microseconds and
+// finally nanoseconds.
+const (
+	maxUnixSeconds = 1e11
+	maxUnixMillis  = 1e14
+	maxUnixMicros  = 1e17
+)
+
+// unixUnit infers the unit of a Unix timestamp from its magnitude
+func unixUnit(magnitude float64) time.Duration {
+	switch {
+	case magnitude < maxUnixSeconds:
+		return time.Second
+	case magnitude < maxUnixMillis:
+		return time.Millisecond
+	case magnitude < maxUnixMicros:
+		return time.Microsecond
+	default:
+		return time.Nanosecond
+	}
+}
+
+// unixTime converts an integer Unix timestamp to a time, preserving full
+// precision for millisecond, microsecond and nanosecond values
+func unixTime(v int64) time.Time {
+	unit := unixUnit(math.Abs(float64(v)))
+	perSecond := int64(time.Second / unit)
+	return time.Unix(v/perSecond, (v%perSecond)*int64(unit))
+}
+
+// unixTimeFloat converts a fractional Unix timestamp to a time, inferring the
+// unit from its magnitude like unixTime
+func unixTimeFloat(v float64) time.Time {
+	unit := unixUnit(math.Abs(v))
+	if unit == time.Second {
 		sec := int64(v)
 		nsec := int64((v - float64(sec)) * 1e9)
-		return time.Unix(sec, nsec).Format(f.preferredDateFmt)
-	default:
-		return fmt.Sprintf("%v", v)
+		return time.Unix(sec, nsec)
 	}
+
+	// Convert the whole part exactly and only apply the fraction as an offset,
+	// so float error doesn't leak into the integer units
+	whole := math.Trunc(v)
+	offset := time.Duration(math.Round((v - whole) * float64(unit)))
+	return unixTime(int64(whole)).Add(offset)
 }
 
-// colorFunc applies a specific color to a value
+// colorFunc applies a specific color to a value: a named color, a hex color
+// like "#ff8800", a 256-color palette number like "208" or a style alias
 func (f *TemplateFormatter) colorFunc(colorName string, value interface{}) string {
 	if f.noColors || value == nil {
 		return fmt.Sprintf("%v", value)
 	}
 
 	content := fmt.Sprintf("%v", value)
-	return ApplyColorToString(content, colorName)
+	if code, ok := f.styleCodes[colorName]; ok {
+		if code == "" {
+			return content
+		}
+		return fmt.Sprintf("\033[%sm%s%s", code, content, ansiReset)
+	}
+	return applyColor(content, colorName, f.colorDepth)
 }
 
 // colorByLevelFunc applies color to a value based on the level
@@ -234,6 +787,98 @@ func (f *TemplateFormatter) colorByLevelFunc(level, value interface{}) string {
 	return content
 }
 
+// levelFunc is a template function that renders a level as a fixed-width,
+// uppercased column colored by the level. Longer levels are cut to the
+// width, and a negative width right-aligns the level, like pad.
+// Usage: [{{.level | level 5}}]
+func (f *TemplateFormatter) levelFunc(width int, value interface{}) string {
+	label := ""
+	if value != nil {
+		label = strings.ToUpper(fmt.Sprintf("%v", value))
+	}
+
+	left := width < 0
+	if left {
+		width = -width
+	}
+	if runes := []rune(label); width > 0 && len(runes) > width {
+		label = string(runes[:width])
+	}
+
+	return f.colorByLevelFunc(value, padString(label, width, left))
+}
+
+// hashColors are the colors colorByHash picks from, leaving out colors that
+// are hard to read on a dark or light background
+var hashColors = []string{
+	"red", "green", "yellow", "blue", "magenta", "cyan",
+	"brightred", "brightgreen", "brightyellow", "brightblue", "brightmagenta", "brightcyan",
+}
+
+// valueHash returns the SHA-256 hash of a value's string form
+func valueHash(value interface{}) [sha256.Size]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("%v", value)))
+}
+
+// colorByHashFunc is a template function that colors a value with a color
+// picked by hashing it, so the same value, such as a trace ID, always gets
+// the same color, across records and runs. Given a key before the value, the
+// key is hashed instead, so a message can be colored by its trace ID.
+// Usage: {{.trace_id | shortHash | colorByHash}} or {{.message | colorByHash .trace_id}}
+func (f *TemplateFormatter) colorByHashFunc(args ...interface{}) string {
+	if len(args) == 0 || len(args) > 2 {
+		return ""
+	}
+	value, key := args[len(args)-1], args[0]
+	if f.noColors || value == nil || key == nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	hash := valueHash(key)
+	return f.colorFunc(hashColors[int(hash[0])%len(hashColors)], value)
+}
+
+// badgeByLevelFunc is a template function that renders a level as a badge:
+// the uppercased level with a space either side, on a background colored by
+// the level, such as white on red for errors. Levels are matched like
+// level normalization, so WARNING and syslog severities are colored too.
+// An optional width pads or cuts the level like the level function. Without
+// colors the badge is written in brackets instead.
+// Usage: {{.level | badgeByLevel}} or {{.level | badgeByLevel 5}}
+func (f *TemplateFormatter) badgeByLevelFunc(args ...interface{}) string {
+	if len(args) == 0 || len(args) > 2 {
+		return ""
+	}
+	value := args[len(args)-1]
+
+	label := ""
+	if value != nil {
+		label = strings.ToUpper(fmt.Sprintf("%v", value))
+	}
+	if len(args) == 2 {
+		width, ok := args[0].(int)
+		if !ok {
+			width, _ = strconv.Atoi(fmt.Sprintf("%v", args[0]))
+		}
+		left := width < 0
+		if left {
+			width = -width
+		}
+		if runes := []rune(label); width > 0 && len(runes) > width {
+			label = string(runes[:width])
+		}
+		label = padString(label, width, left)
+	}
+
+	if f.noColors {
+		return "[" + label + "]"
+	}
+	badge := levelBadges[ColorByLevelName(canonicalLevel(value))]
+	bg, _ := colorCode(badge[0], f.colorDepth)
+	fg, _ := colorCode(badge[1], f.colorDepth)
+	return fmt.Sprintf("\033[%s;%sm %s %s", bg, fg, label, ansiReset)
+}
+
 // boldFunc makes text bold
 func (f *TemplateFormatter) boldFunc(value interface{}) string {
 	if f.noColors || value == nil {
@@ -275,7 +920,31 @@ func (f *TemplateFormatter) dimFunc(value interface{}) string {
 }
 
 // prettyFunc is a template function that pretty-prints any value, with special handling for maps and arrays
-func (f *TemplateFormatter) prettyFunc(value interface{}) string {
+// An optional maximum depth can be given before the value, overriding WithPrettyMaxDepth
+// Usage: {{.context | pretty}} or {{.context | pretty 2}}
+func (f *TemplateFormatter) prettyFunc(args ...interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	maxDepth := f.prettyMaxDepth
+	if len(args) > 1 {
+		if d, ok := toFloat64(args[0]); ok && d >= 0 {
+			maxDepth = int(d)
+		}
+	}
+	return f.prettyValue(args[len(args)-1], 1, maxDepth)
+}
+
+// Placeholders for maps and arrays nested deeper than the maximum depth
+const (
+	elidedMap   = "{…}"
+	elidedArray = "[…]"
+)
+
+// prettyValue pretty-prints a value found depth levels deep, eliding
+// non-empty maps and arrays deeper than maxDepth unless it is zero
+func (f *TemplateFormatter) prettyValue(value interface{}, depth, maxDepth int) string {
 	if value == nil {
 		return "<nil>"
 	}
@@ -292,13 +961,13 @@ func (f *TemplateFormatter) prettyFunc(value interface{}) string {
 	case json.Number:
 		return v.String()
 	case time.Duration:
-		return formatDuration(v)
+		return f.durationString(v)
 	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
 		return fmt.Sprintf("%v", v)
 	case []interface{}:
-		return f.prettyArray(v)
+		return f.prettyArray(v, depth, maxDepth)
 	case map[string]interface{}:
-		return f.prettyMap(v)
+		return f.prettyMap(v, depth, maxDepth)
 	}
 
 	// For other complex types, use reflection to determine the kind
@@ -310,7 +979,7 @@ func (f *TemplateFormatter) prettyFunc(value interface{}) string {
 		for i := 0; i < length; i++ {
 			arr[i] = val.Index(i).Interface()
 		}
-		return f.prettyArray(arr)
+		return f.prettyArray(arr, depth, maxDepth)
 	} else if val.Kind() == reflect.Map {
 		// Convert to map[string]interface{} and use prettyMap if possible
 		result := make(map[string]interface{})
@@ -324,7 +993,7 @@ func (f *TemplateFormatter) prettyFunc(value interface{}) string {
 			}
 			result[keyStr] = val.MapIndex(key).Interface()
 		}
-		return f.prettyMap(result)
+		return f.prettyMap(result, depth, maxDepth)
 	}
 
 	// Fallback to standard formatting for other types
@@ -332,16 +1001,19 @@ func (f *TemplateFormatter) prettyFunc(value interface{}) string {
 }
 
 // prettyArray formats an array as a comma-separated list with dim formatting for commas
-func (f *TemplateFormatter) prettyArray(arr []interface{}) string {
+func (f *TemplateFormatter) prettyArray(arr []interface{}, depth, maxDepth int) string {
 	if len(arr) == 0 {
 		return "[]"
 	}
+	if maxDepth > 0 && depth > maxDepth {
+		return elidedArray
+	}
 
 	var builder strings.Builder
 	builder.WriteString("[")
 
 	for i, item := range arr {
-		builder.WriteString(f.prettyFunc(item))
+		builder.WriteString(f.prettyValue(item, depth+1, maxDepth))
 		if i < len(arr)-1 {
 			if f.noColors {
 				builder.WriteString(", ")
@@ -356,16 +1028,19 @@ func (f *TemplateFormatter) prettyArray(arr []interface{}) string {
 }
 
 // prettyMap formats a map with key=value format where the key part is dim
-func (f *TemplateFormatter) prettyMap(m map[string]interface{}) string {
+func (f *TemplateFormatter) prettyMap(m map[string]interface{}, depth, maxDepth int) string {
 	if len(m) == 0 {
 		return "{}"
 	}
+	if maxDepth > 0 && depth > maxDepth {
+		return elidedMap
+	}
 
 	var builder strings.Builder
 	builder.WriteString("{")
 
-	i := 0
-	for key, val := range m {
+	for i, key := range f.sortedKeys(m) {
+		val := m[key]
 		if i > 0 {
 			if f.noColors {
 				builder.WriteString(", ")
@@ -375,15 +1050,18 @@ func (f *TemplateFormatter) prettyMap(m map[string]interface{}) string {
 		}
 
 		// Key part with dim formatting if colors are enabled
+		separator := "="
+		if f.kvSeparator != nil {
+			separator = *f.kvSeparator
+		}
 		if f.noColors {
-			builder.WriteString(fmt.Sprintf("%s=", key))
+			builder.WriteString(key + separator)
 		} else {
-			builder.WriteString(fmt.Sprintf("\033[2m%s=\033[0m", key))
+			builder.WriteString(fmt.Sprintf("\033[2m%s%s\033[0m", key, separator))
 		}
 
 		// Value part with normal formatting
-		builder.WriteString(f.prettyFunc(val))
-		i++
+		builder.WriteString(f.prettyField(key, val, depth+1, maxDepth))
 	}
 
 	builder.WriteString("}")
@@ -391,10 +1069,13 @@ func (f *TemplateFormatter) prettyMap(m map[string]interface{}) string {
 }
 
 // tableFunc formats a map as a table with each field on a new line
-// Format is "key: value" with keys right-padded and dimmed
+// Keys are right-padded and dimmed, followed by the value, with an optional
+// separator between them set using WithKVSeparator
 // Empty or nil values are omitted (use with filter function for field exclusion)
+// unless a placeholder is given, in which case it is rendered in their place
 // An optional padding length can be specified for the keys, defaults to 19 if not provided
-func (f *TemplateFormatter) tableFunc(padding, value interface{}) string {
+// Usage: {{table .}}, {{table 25 .}} or {{table 25 "-" .}}
+func (f *TemplateFormatter) tableFunc(padding, placeholder, value interface{}) string {
 	if value == nil {
 		return ""
 	}
@@ -437,73 +1118,249 @@ func (f *TemplateFormatter) tableFunc(padding, value interface{}) string {
 		return ""
 	}
 
-	// Get a sorted list of keys for consistent output
-	var keys []string
-	for key := range dataMap {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-
-	// Build the table
 	var builder strings.Builder
-	for i, key := range keys {
-		val := dataMap[key]
+	f.writeTableRows(&builder, dataMap, tableLayout{keyPadding: keyPadding, placeholder: placeholder}, 0, nil)
+	return builder.String()
+}
 
-		// Skip empty values (nil or empty string)
-		isEmpty := val == nil
-		if !isEmpty {
-			if str, ok := val.(string); ok && str == "" {
-				isEmpty = true
-			}
-		}
-		if isEmpty {
-			continue
-		}
+// Default and maximum nesting depth for the tree function
+const (
+	defaultTreeDepth = 5
+	maxTreeDepth     = 32
+)
 
-		// Add newline between fields
-		if i > 0 {
-			builder.WriteString("\n")
-		}
+// treeFunc formats a map like table, but renders map-valued fields as
+// indented sub-tables instead of one-liners, up to a maximum depth beyond
+// which nested values are pretty-printed inline
+// Usage: {{tree .}} or {{tree 3 .}}
+func (f *TemplateFormatter) treeFunc(args ...interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
 
-		// Format the key with padding and dim effect
-		paddedKey := f.padFunc(keyPadding, key)
-		if f.noColors {
-			builder.WriteString(fmt.Sprintf("  %s", paddedKey))
-		} else {
-			builder.WriteString(fmt.Sprintf("  \033[2m%s\033[0m", paddedKey))
+	maxDepth := defaultTreeDepth
+	if len(args) > 1 {
+		if d, ok := toFloat64(args[0]); ok {
+			maxDepth = int(d)
 		}
+	}
+	if maxDepth < 0 {
+		maxDepth = 0
+	} else if maxDepth > maxTreeDepth {
+		maxDepth = maxTreeDepth
+	}
 
-		// Format the value using pretty
-		builder.WriteString(f.prettyFunc(val))
+	dataMap, ok := args[len(args)-1].(map[string]interface{})
+	if !ok {
+		return f.prettyFunc(args[len(args)-1])
 	}
 
+	var builder strings.Builder
+	layout := tableLayout{keyPadding: 19, maxDepth: maxDepth}
+	f.writeTableRows(&builder, dataMap, layout, 0, map[uintptr]bool{})
 	return builder.String()
 }
 
-// hasPrefixFunc checks if a string has a specific prefix
-func (f *TemplateFormatter) hasPrefixFunc(s, prefix string) bool {
+// listFunc formats an array of objects as a numbered list, with each object
+// rendered as an indented table below its number. Arrays that contain
+// anything other than objects are pretty-printed inline instead.
+// An optional padding length can be given for the keys, like table
+// Usage: {{list .errors}} or {{list 12 .errors}}
+func (f *TemplateFormatter) listFunc(args ...interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	keyPadding := 19
+	if len(args) > 1 {
+		if p, ok := toFloat64(args[0]); ok && p > 0 {
+			keyPadding = int(p)
+		}
+	}
+
+	value := args[len(args)-1]
+	arr, ok := value.([]interface{})
+	if !ok || len(arr) == 0 {
+		return f.prettyFunc(value)
+	}
+
+	items := make([]map[string]interface{}, len(arr))
+	for i, item := range arr {
+		if items[i], ok = item.(map[string]interface{}); !ok {
+			return f.prettyFunc(value)
+		}
+	}
+
+	var builder strings.Builder
+	layout := tableLayout{keyPadding: keyPadding}
+	for i, item := range items {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		number := fmt.Sprintf("%d.", i+1)
+		if !f.noColors {
+			number = fmt.Sprintf("\033[2m%s\033[0m", number)
+		}
+		builder.WriteString(number)
+
+		if len(item) == 0 {
+			builder.WriteString(" {}")
+			continue
+		}
+		builder.WriteString("\n")
+
+		var rows strings.Builder
+		f.writeTableRows(&rows, item, layout, 0, nil)
+		builder.WriteString(rows.String())
+	}
+	return builder.String()
+}
+
+// tableLayout controls how writeTableRows renders a table
+type tableLayout struct {
+	// Width keys are padded to at the top level
+	keyPadding int
+	// Value rendered in place of empty values, or nil to omit them
+	placeholder interface{}
+	// Number of levels of nested maps to render as sub-tables
+	maxDepth int
+}
+
+// writeTableRows writes one "key value" row per field of dataMap, sorted by
+// key. Nested maps are rendered as indented sub-tables while depth is below
+// the layout's maxDepth. Each level is indented by two more spaces and its
+// key padding reduced to match, so values stay aligned in a single column.
+// visited holds the maps on the current path and guards against cycles.
+func (f *TemplateFormatter) writeTableRows(builder *strings.Builder, dataMap map[string]interface{}, layout tableLayout, depth int, visited map[uintptr]bool) {
+	if visited != nil {
+		ptr := reflect.ValueOf(dataMap).Pointer()
+		visited[ptr] = true
+		defer delete(visited, ptr)
+	}
+
+	// Get a sorted list of keys for consistent output
+	keys := f.sortedKeys(dataMap)
+
+	indent := strings.Repeat(" ", f.indentWidth()) + strings.Repeat("  ", depth)
+	keyPadding := max(layout.keyPadding-2*depth, 0)
+
+	for _, key := range keys {
+		val := dataMap[key]
+
+		// Skip empty values, or show the placeholder
+		if f.isEmptyTableValue(key, val) {
+			if layout.placeholder == nil {
+				continue
+			}
+			val = layout.placeholder
+		}
+
+		// Add newline between fields
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+
+		// Render non-empty nested maps as sub-tables, guarding against cycles
+		nested, isNested := val.(map[string]interface{})
+		isNested = isNested && len(nested) > 0 && depth < layout.maxDepth
+		if isNested && visited[reflect.ValueOf(nested).Pointer()] {
+			val = "<cycle>"
+			isNested = false
+		}
+
+		// Format the key with padding and dim effect
+		paddedKey := key
+		if !isNested {
+			if f.kvSeparator != nil {
+				paddedKey += *f.kvSeparator
+			}
+			paddedKey = padString(paddedKey, keyPadding, false)
+		}
+		if f.noColors {
+			builder.WriteString(fmt.Sprintf("%s%s", indent, paddedKey))
+		} else {
+			builder.WriteString(fmt.Sprintf("%s\033[2m%s\033[0m", indent, paddedKey))
+		}
+
+		if isNested {
+			f.writeTableRows(builder, nested, layout, depth+1, visited)
+			continue
+		}
+
+		// Format the value using pretty
+		builder.WriteString(f.prettyField(key, val, 1, f.prettyMaxDepth))
+	}
+}
+
+// sortedKeys returns the keys of m in the configured map sort order, using
+// the key to break ties between equal values
+func (f *TemplateFormatter) sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	if f.mapSort != MapSortValue && f.mapSort != MapSortValueDesc {
+		sort.Strings(keys)
+		return keys
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		cmp := compareValues(m[keys[i]], m[keys[j]])
+		if f.mapSort == MapSortValueDesc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// compareValues orders two values numerically if both are numbers, and by
+// their string representation otherwise, returning -1, 0 or 1
+func compareValues(a, b interface{}) int {
+	numA, okA := toFloat64(a)
+	numB, okB := toFloat64(b)
+	switch {
+	case okA && okB:
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	case okA:
+		// Numbers sort before other values
+		return -1
+	case okB:
+		return 1
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// hasPrefixFunc checks if a string has a specific prefix
+func (f *TemplateFormatter) hasPrefixFunc(s, prefix string) bool {
 	return strings.HasPrefix(s, prefix)
 }
 
 // filterFunc returns a filtered map of fields based on patterns
-// It can handle exact field names or prefix patterns with wildcards
+// Patterns are exact field names or globs, matched against the dotted field
+// names with path.Match, so * matches any run of characters including dots
+// A pattern ending in * also matches every field with the preceding prefix,
+// even if the rest of the pattern isn't a valid glob
 // Example: filter . "timestamp" "level" - excludes timestamp and level fields
 // Example: filter . "grpc.*" - excludes all fields starting with "grpc."
+// Example: filter . "*.password" "grpc.*.duration" - excludes matching fields
 func (f *TemplateFormatter) filterFunc(data map[string]interface{}, excludePatterns ...string) map[string]interface{} {
 	result := make(map[string]interface{})
 
 	for key, value := range data {
 		exclude := false
 		for _, pattern := range excludePatterns {
-			// Check if pattern ends with wildcard
-			if strings.HasSuffix(pattern, "*") {
-				prefix := pattern[:len(pattern)-1]
-				if strings.HasPrefix(key, prefix) {
-					exclude = true
-					break
-				}
-			} else if key == pattern {
-				// Exact field match
+			if matchFieldPattern(pattern, key) {
 				exclude = true
 				break
 			}
@@ -517,6 +1374,88 @@ func (f *TemplateFormatter) filterFunc(data map[string]interface{}, excludePatte
 	return result
 }
 
+// mergeFunc combines maps into a new map, with later maps taking precedence
+// for keys they share. The merge is shallow, so nested maps aren't combined,
+// and nil maps are ignored.
+// Example: {{table (merge (filter . "password") .request)}}
+func (f *TemplateFormatter) mergeFunc(maps ...map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, m := range maps {
+		for key, value := range m {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// renameKeysFunc returns a copy of a map with keys renamed, such as to give a
+// table friendlier labels. The pairs alternate between old and new keys, and
+// keys that aren't listed are left as they are. A renamed key replaces an
+// existing key with the new name, and a trailing old key without a new name
+// is ignored.
+// Example: {{table (renameKeys . "grpc.method" "Method" "http.status" "Status")}}
+func (f *TemplateFormatter) renameKeysFunc(data map[string]interface{}, pairs ...string) map[string]interface{} {
+	renames := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		renames[pairs[i]] = pairs[i+1]
+	}
+
+	// Copy the unchanged keys first, so renamed keys take their place
+	result := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if _, ok := renames[key]; !ok {
+			result[key] = value
+		}
+	}
+	for key, value := range data {
+		if newKey, ok := renames[key]; ok {
+			result[newKey] = value
+		}
+	}
+	return result
+}
+
+// argsFunc returns the values of a record's fields ordered by field name, so
+// they can be used by position. Names are sorted byte-wise, so uppercase names
+// come before lowercase ones, and nested maps and arrays are a single value.
+// Example: {{index (args .) 0}} - the value of the first field by name
+func (f *TemplateFormatter) argsFunc(data map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = data[key]
+	}
+	return values
+}
+
+// sprintfFunc formats a whole record with fmt.Sprintf, passing its values as
+// the arguments in the order given by args
+// Example: {{sprintf "%s %s" (filter . "timestamp")}}
+func (f *TemplateFormatter) sprintfFunc(format string, data map[string]interface{}) string {
+	return fmt.Sprintf(format, f.argsFunc(data)...)
+}
+
+// matchFieldPattern reports whether a field name matches a filter pattern
+func matchFieldPattern(pattern, key string) bool {
+	if key == pattern {
+		return true
+	}
+
+	// Prefix patterns such as "grpc.*"
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(key, prefix) {
+		return true
+	}
+
+	// Invalid globs only match exactly
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
+
 // formatDuration formats a time.Duration into a human-readable string
 // For example: 1h30m45s, 250ms, 1.5s
 func formatDuration(d time.Duration) string {
@@ -547,6 +1486,51 @@ func formatDuration(d time.Duration) string {
 	return d.String()
 }
 
+// durationUnits are the units rounded durations are shown in, largest first
+var durationUnits = []struct {
+	size time.Duration
+	name string
+}{
+	{24 * time.Hour, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+}
+
+// formatRoundedDuration formats a duration like formatDuration, but rounds
+// durations of ten seconds or more to their two most significant units
+// For example: 1h30m, 2d4h, 45.1s
+func formatRoundedDuration(d time.Duration) string {
+	if d < 10*time.Second {
+		return formatDuration(d)
+	}
+	if tenths := d.Round(100 * time.Millisecond); tenths < time.Minute {
+		return fmt.Sprintf("%.1fs", tenths.Seconds())
+	}
+
+	// Round to the smaller of the two units before picking the larger one, so
+	// 59m59.6s carries over to 1h rather than showing as 60m
+	for i, unit := range durationUnits[:len(durationUnits)-1] {
+		minor := durationUnits[i+1]
+		rounded := d.Round(minor.size)
+		if rounded < unit.size {
+			continue
+		}
+		major, rest := rounded/unit.size, (rounded%unit.size)/minor.size
+		if rest == 0 {
+			return fmt.Sprintf("%d%s", major, unit.name)
+		}
+		return fmt.Sprintf("%d%s%d%s", major, unit.name, rest, minor.name)
+	}
+	return formatDuration(d)
+}
+
+// durationString formats a duration in the formatter's duration style,
+// rounding it if duration rounding is enabled
+func (f *TemplateFormatter) durationString(d time.Duration) string {
+	return formatDurationStyle(d, f.durationStyle, f.roundDurations)
+}
+
 // parseDuration attempts to parse a value as a duration
 // It can handle:
 // - time.Duration values directly
@@ -592,7 +1576,7 @@ func (f *TemplateFormatter) durationFunc(value interface{}) string {
 		// If we can't parse as duration, just use pretty formatting
 		return f.prettyFunc(value)
 	}
-	return formatDuration(duration)
+	return f.durationString(duration)
 }
 
 // truncFunc is a template function that truncates text to a specified length
@@ -637,6 +1621,37 @@ func (f *TemplateFormatter) truncFunc(maxLen, value interface{}) string {
 	return text[:maxLength-3] + "..."
 }
 
+// indentFunc is a template function that indents every line of a value by
+// the given number of spaces, or by the indent set with WithIndent if no
+// number is given. Blank lines are left empty.
+// Usage: {{.stack | wrap 76 2 | indent}} or {{table . | indent 4}}
+func (f *TemplateFormatter) indentFunc(args ...interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	spaces := f.indentWidth()
+	if len(args) > 1 {
+		if n, ok := toFloat64(args[0]); ok && n >= 0 {
+			spaces = int(n)
+		}
+	}
+
+	value := args[len(args)-1]
+	if value == nil {
+		value = noValueStr
+	}
+	prefix := strings.Repeat(" ", spaces)
+
+	lines := strings.Split(fmt.Sprintf("%v", value), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // wrapFunc is a template function that wraps text to a specified width
 // It takes a width parameter (required) and an optional indent parameter
 // for wrapped lines. Usage: {{.description | wrap 80 2}}
@@ -736,9 +1751,18 @@ func (f *TemplateFormatter) wrapFunc(width, indent, value interface{}) string {
 }
 
 // multFunc is a template function that multiplies a number by the given value
+// Whole results are shown as integers and others with two decimal places,
+// unless a precision is given after the multiplier, in which case results
+// always have that many decimal places, or as many as needed if it is -1. A
+// precision that isn't a whole number is ignored.
 // If either the argument or the value is not numeric, it returns "NaN"
-// Usage: {{.value | mult 10}}
-func (f *TemplateFormatter) multFunc(arg, value interface{}) string {
+// Usage: {{.value | mult 10}} or {{.price | mult 1.2 4}}
+func (f *TemplateFormatter) multFunc(arg interface{}, args ...interface{}) string {
+	if len(args) == 0 || len(args) > 2 {
+		return nanStr
+	}
+	value := args[len(args)-1]
+
 	// Handle nil cases
 	if arg == nil || value == nil {
 		return nanStr
@@ -783,6 +1807,13 @@ func (f *TemplateFormatter) multFunc(arg, value interface{}) string {
 
 	result := argFloat * valFloat
 
+	// Use the precision if given, instead of collapsing whole numbers
+	if len(args) == 2 {
+		if places, err := strconv.Atoi(fmt.Sprintf("%v", args[0])); err == nil {
+			return strconv.FormatFloat(result, 'f', max(places, -1), 64)
+		}
+	}
+
 	// Format the result based on whether it's an integer or has decimal places
 	if result == float64(int(result)) {
 		return fmt.Sprintf("%d", int(result))
@@ -790,6 +1821,248 @@ func (f *TemplateFormatter) multFunc(arg, value interface{}) string {
 	return fmt.Sprintf("%.2f", result)
 }
 
+// percentFunc is a template function that formats a ratio as a percentage,
+// rounded to the given number of decimal places
+// If the value is not numeric, it returns "NaN"
+// Usage: {{.ratio | percent 1}}
+func (f *TemplateFormatter) percentFunc(decimals, value interface{}) string {
+	num, ok := toFloat64(value)
+	if !ok {
+		return nanStr
+	}
+
+	// Parse decimals parameter
+	places := 0
+	if decimals != nil {
+		if d, ok := decimals.(int); ok {
+			places = d
+		} else if d, err := strconv.Atoi(fmt.Sprintf("%v", decimals)); err == nil {
+			places = d
+		}
+	}
+	if places < 0 {
+		places = 0
+	}
+
+	return strconv.FormatFloat(num*100, 'f', places, 64) + "%"
+}
+
+// shortHashEncoding writes hashes as lowercase base32, which is short and
+// avoids characters that need quoting
+var shortHashEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+
+// defaultShortHashLength is the length of shortHash's result unless given
+const defaultShortHashLength = 6
+
+// shortHashFunc is a template function that returns a short, stable hash of
+// a value, such as a long trace ID, to tell values apart at a glance. The
+// hash is the first characters of the base32 SHA-256 hash of the value's
+// string form, 6 unless a length is given, so it is the same across runs.
+// It returns an empty string for nil.
+// Usage: {{.trace_id | shortHash}} or {{.trace_id | shortHash 10}}
+func (f *TemplateFormatter) shortHashFunc(args ...interface{}) string {
+	if len(args) == 0 || len(args) > 2 {
+		return ""
+	}
+	value := args[len(args)-1]
+	if value == nil {
+		return ""
+	}
+
+	length := defaultShortHashLength
+	if len(args) == 2 {
+		if n, ok := args[0].(int); ok {
+			length = n
+		} else if n, err := strconv.Atoi(fmt.Sprintf("%v", args[0])); err == nil {
+			length = n
+		}
+	}
+
+	hash := valueHash(value)
+	encoded := shortHashEncoding.EncodeToString(hash[:])
+	return encoded[:min(max(length, 1), len(encoded))]
+}
+
+// deltaFunc is a template function that shows the signed difference from one
+// number to another, b minus a, colored green if it is positive and red if it
+// is negative. Whole differences are shown as integers and others with two
+// decimal places. Pass false after the numbers to leave out the color.
+// If either value is not numeric, it returns "NaN"
+// Usage: {{delta .expected .actual}} or {{delta .before .after false}}
+func (f *TemplateFormatter) deltaFunc(a, b interface{}, colored ...bool) string {
+	aNum, aOK := toFloat64(a)
+	bNum, bOK := toFloat64(b)
+	if !aOK || !bOK {
+		return nanStr
+	}
+
+	diff := bNum - aNum
+	var text string
+	if diff == float64(int(diff)) {
+		text = fmt.Sprintf("%+d", int(diff))
+	} else {
+		text = fmt.Sprintf("%+.2f", diff)
+	}
+
+	switch {
+	case diff == 0:
+		return "0"
+	case len(colored) > 0 && !colored[0]:
+		return text
+	case diff > 0:
+		return f.colorFunc("green", text)
+	default:
+		return f.colorFunc("red", text)
+	}
+}
+
+// sparkBars are the characters used by sparkline, from lowest to highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparklineFunc is a template function that renders an array of numbers as a
+// sparkline, scaling each bar between the smallest and largest values
+// Non-numeric elements are rendered as spaces, and values that aren't arrays
+// render as an empty string
+// Usage: {{.buckets | sparkline}}
+func (f *TemplateFormatter) sparklineFunc(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return ""
+	}
+
+	// Convert the elements, tracking the range of the numeric ones
+	nums := make([]float64, val.Len())
+	isNum := make([]bool, val.Len())
+	minVal, maxVal := math.Inf(1), math.Inf(-1)
+	for i := range nums {
+		num, ok := toFloat64(val.Index(i).Interface())
+		if !ok || math.IsNaN(num) || math.IsInf(num, 0) {
+			continue
+		}
+		nums[i], isNum[i] = num, true
+		minVal = math.Min(minVal, num)
+		maxVal = math.Max(maxVal, num)
+	}
+
+	var builder strings.Builder
+	for i, num := range nums {
+		switch {
+		case !isNum[i]:
+			builder.WriteRune(' ')
+		case maxVal == minVal:
+			// All values are equal, so there's no relative magnitude to show
+			builder.WriteRune(sparkBars[0])
+		default:
+			level := int((num - minVal) / (maxVal - minVal) * float64(len(sparkBars)-1))
+			builder.WriteRune(sparkBars[level])
+		}
+	}
+
+	return builder.String()
+}
+
+// trimPrefixFunc is a template function that removes a leading prefix from a
+// value, if present
+// If the value is nil, it returns an empty string
+// Usage: {{.logger | trimPrefix "com.example."}}
+func (f *TemplateFormatter) trimPrefixFunc(prefix string, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%v", value), prefix)
+}
+
+// trimSuffixFunc is a template function that removes a trailing suffix from a
+// value, if present
+// If the value is nil, it returns an empty string
+// Usage: {{.host | trimSuffix ".internal"}}
+func (f *TemplateFormatter) trimSuffixFunc(suffix string, value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%v", value), suffix)
+}
+
+// trimSpaceFunc is a template function that removes leading and trailing
+// whitespace from a value
+// If the value is nil, it returns an empty string
+// Usage: {{.message | trimSpace}}
+func (f *TemplateFormatter) trimSpaceFunc(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", value))
+}
+
+// parseJSONFunc is a template function that decodes a string containing a
+// JSON object or array, as found in double-encoded logs, so it can be rendered
+// by functions such as pretty and table
+// Values that aren't strings, or don't hold a valid object or array, are
+// returned unchanged
+// Usage: {{.payload | parseJSON | pretty}}
+func (f *TemplateFormatter) parseJSONFunc(value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	trimmed := strings.TrimSpace(str)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
+
+// markdownEscaper backslash-escapes characters that have meaning in markdown,
+// and replaces newlines so a value can't break out of a table row
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"`", "\\`",
+	"*", "\\*",
+	"_", "\\_",
+	"[", "\\[",
+	"]", "\\]",
+	"|", "\\|",
+	"<", "\\<",
+	">", "\\>",
+	"#", "\\#",
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+// mdEscapeFunc is a template function that escapes a value for safe use in
+// markdown, including inside table cells
+// If the value is nil, it returns an empty string
+// Usage: {{.message | mdEscape}}
+func (f *TemplateFormatter) mdEscapeFunc(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return markdownEscaper.Replace(fmt.Sprintf("%v", value))
+}
+
+// shellQuoteFunc is a template function that quotes a value as a single
+// POSIX shell word, wrapping it in single quotes
+// If the value is nil, it returns an empty string
+// Usage: {{.path | shellQuote}}
+func (f *TemplateFormatter) shellQuoteFunc(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	// Single quotes can't be escaped inside single quotes, so close the
+	// quoted string, add an escaped quote, and reopen it
+	return "'" + strings.ReplaceAll(fmt.Sprintf("%v", value), "'", `'\''`) + "'"
+}
+
 // printfFunc is a template function that applies formatting to a value using fmt.Sprintf
 // Usage: {{.value | printf "%.2f"}}
 func (f *TemplateFormatter) printfFunc(format, value interface{}) string {
@@ -806,6 +2079,8 @@ func (f *TemplateFormatter) printfFunc(format, value interface{}) string {
 }
 
 // eqFunc is a template function that checks if two values are equal
+// Numbers and numeric strings are compared by value unless strict equality
+// is enabled
 // Usage: {{eq .value "expected"}}
 func (f *TemplateFormatter) eqFunc(a, b interface{}) bool {
 	if a == nil && b == nil {
@@ -815,6 +2090,10 @@ func (f *TemplateFormatter) eqFunc(a, b interface{}) bool {
 		return false
 	}
 
+	if f.strictEquality {
+		return strictEqual(a, b)
+	}
+
 	// For number comparison, convert to float64 if possible
 	aNum, aIsFloat := toFloat64(a)
 	bNum, bIsFloat := toFloat64(b)
@@ -833,6 +2112,42 @@ func (f *TemplateFormatter) neFunc(a, b interface{}) bool {
 	return !f.eqFunc(a, b)
 }
 
+// inFunc is a template function that checks if a value equals any member of
+// a set, using the same rules as eq
+// Usage: {{if in .status 500 502 503 504}}server error{{end}}
+func (f *TemplateFormatter) inFunc(value interface{}, set ...interface{}) bool {
+	for _, member := range set {
+		if f.eqFunc(value, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// notInFunc is a template function that checks if a value equals no member of
+// a set
+// Usage: {{if notIn .level "debug" "trace"}}{{.message}}{{end}}
+func (f *TemplateFormatter) notInFunc(value interface{}, set ...interface{}) bool {
+	return !f.inFunc(value, set...)
+}
+
+// lookupFunc is a template function that maps a value to a label. The pairs
+// alternate between keys and values, with an optional default as the last
+// element. Keys are matched using the same rules as eq. If no key matches
+// and there is no default, the original value is returned.
+// Usage: {{lookup .status 200 "OK" 404 "Not Found" "???"}}
+func (f *TemplateFormatter) lookupFunc(value interface{}, pairs ...interface{}) interface{} {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if f.eqFunc(value, pairs[i]) {
+			return pairs[i+1]
+		}
+	}
+	if len(pairs)%2 == 1 {
+		return pairs[len(pairs)-1]
+	}
+	return value
+}
+
 // gtFunc is a template function that checks if a value is greater than another
 // Usage: {{gt .value 10}}
 func (f *TemplateFormatter) gtFunc(a, b interface{}) bool {
@@ -848,6 +2163,20 @@ func (f *TemplateFormatter) gtFunc(a, b interface{}) bool {
 	return fmt.Sprintf("%v", a) > fmt.Sprintf("%v", b)
 }
 
+// betweenFunc is a template function that checks if a number is within a
+// range, including its bounds. It is false if any of the values isn't
+// numeric.
+// Usage: {{if between .latency 100 500}}
+func (f *TemplateFormatter) betweenFunc(value, low, high interface{}) bool {
+	v, ok := toFloat64(value)
+	if !ok {
+		return false
+	}
+	lo, loOK := toFloat64(low)
+	hi, hiOK := toFloat64(high)
+	return loOK && hiOK && lo <= v && v <= hi
+}
+
 // ltFunc is a template function that checks if a value is less than another
 // Usage: {{lt .value 10}}
 func (f *TemplateFormatter) ltFunc(a, b interface{}) bool {
@@ -863,6 +2192,104 @@ func (f *TemplateFormatter) ltFunc(a, b interface{}) bool {
 	return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
 }
 
+// gteFunc is a template function that checks if a value is greater than or
+// equal to another
+// Usage: {{if gte .status 400}}
+func (f *TemplateFormatter) gteFunc(a, b interface{}) bool {
+	// For number comparison, convert to float64 if possible
+	aNum, aIsFloat := toFloat64(a)
+	bNum, bIsFloat := toFloat64(b)
+
+	if aIsFloat && bIsFloat {
+		return aNum >= bNum
+	}
+
+	// For string comparison
+	return fmt.Sprintf("%v", a) >= fmt.Sprintf("%v", b)
+}
+
+// lteFunc is a template function that checks if a value is less than or
+// equal to another
+// Usage: {{if lte .duration 100}}
+func (f *TemplateFormatter) lteFunc(a, b interface{}) bool {
+	// For number comparison, convert to float64 if possible
+	aNum, aIsFloat := toFloat64(a)
+	bNum, bIsFloat := toFloat64(b)
+
+	if aIsFloat && bIsFloat {
+		return aNum <= bNum
+	}
+
+	// For string comparison
+	return fmt.Sprintf("%v", a) <= fmt.Sprintf("%v", b)
+}
+
+// beforeFunc is a template function that checks if a time is before another
+// Both values are parsed as timestamps, falling back to string comparison if
+// either can't be parsed
+// Usage: {{if before .timestamp .deadline}}on time{{end}}
+func (f *TemplateFormatter) beforeFunc(a, b interface{}) bool {
+	return compareTimes(a, b) < 0
+}
+
+// afterFunc is a template function that checks if a time is after another
+// Both values are parsed as timestamps, falling back to string comparison if
+// either can't be parsed
+// Usage: {{if after .timestamp .deadline}}late{{end}}
+func (f *TemplateFormatter) afterFunc(a, b interface{}) bool {
+	return compareTimes(a, b) > 0
+}
+
+// compareTimes orders two timestamps, returning -1, 0 or 1, or compares their
+// string representations if either isn't a recognized timestamp
+func compareTimes(a, b interface{}) int {
+	aTime, aOK := parseTimestamp(a)
+	bTime, bOK := parseTimestamp(b)
+	if aOK && bOK {
+		return aTime.Compare(bTime)
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// strictEqual compares two values without converting between types. Numbers
+// of any type are equal if they have the same value, strings are equal only
+// to identical strings, and other values are compared deeply.
+func strictEqual(a, b interface{}) bool {
+	aNum, aIsNum := numberValue(a)
+	bNum, bIsNum := numberValue(b)
+	if aIsNum || bIsNum {
+		return aIsNum && bIsNum && aNum == bNum
+	}
+
+	aStr, aIsStr := a.(string)
+	bStr, bIsStr := b.(string)
+	if aIsStr || bIsStr {
+		return aIsStr && bIsStr && aStr == bStr
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// numberValue converts a value of a numeric type to float64, without parsing
+// strings
+func numberValue(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
 // Helper function to convert a value to float64 if possible
 func toFloat64(v interface{}) (float64, bool) {
 	if v == nil {
@@ -890,6 +2317,13 @@ func toFloat64(v interface{}) (float64, bool) {
 	return 0, false
 }
 
+// envFunc is a template function that returns the value of an environment
+// variable of the logista process, or an empty string if it isn't set
+// Usage: {{env "HOSTNAME"}}
+func (f *TemplateFormatter) envFunc(name string) string {
+	return os.Getenv(name)
+}
+
 // issetFunc is a template function that checks if a field exists on a struct or in a map
 // Usage: {{if isset "Email" .}}Email exists{{end}}
 func (f *TemplateFormatter) issetFunc(name string, data interface{}) bool {
@@ -915,6 +2349,37 @@ func (f *TemplateFormatter) issetFunc(name string, data interface{}) bool {
 	return v.FieldByName(name).IsValid()
 }
 
+// truthyFunc is a template function that reports whether a value has meaningful
+// content. nil, false, the number zero, the empty string and empty arrays or maps
+// are falsey; everything else, including the strings "0" and "false", is truthy.
+// Unlike isset it looks at the value rather than whether the field is present.
+// Usage: {{if truthy .error}}Error: {{.error}}{{end}}
+func (f *TemplateFormatter) truthyFunc(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if n, ok := numberValue(value); ok {
+		return n != 0
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil()
+	}
+	return true
+}
+
+// emptyFunc is a template function that is the inverse of truthy
+// Usage: {{if empty .tags}}untagged{{end}}
+func (f *TemplateFormatter) emptyFunc(value interface{}) bool {
+	return !f.truthyFunc(value)
+}
+
 // Format formats the data according to the template
 func (f *TemplateFormatter) Format(data map[string]interface{}) (string, error) {
 	var buf strings.Builder
@@ -925,26 +2390,211 @@ func (f *TemplateFormatter) Format(data map[string]interface{}) (string, error)
 	return buf.String(), nil
 }
 
-// ProcessStream processes JSON logs from a reader and writes formatted output to a writer
+// ProcessStream processes logs from a reader and writes formatted output to a writer
+// Each line is decoded by the formatter's InputParser, which defaults to JSON
 // skipPatterns is a slice of patterns to match for skipping log records
 // handleNonJSON controls how to handle non-JSON data in the stream
+// If formatter is a ReloadableFormatter its skip patterns are also applied
+// If w is a BufferedWriter it is notified after each record so it can flush,
+// and any writer with a Flush method is flushed once the stream ends
+// If the output is closed by the reader, an error wrapping ErrOutputClosed is returned
 func (f *TemplateFormatter) ProcessStream(r io.Reader, w io.Writer, formatter Formatter, skipPatterns []SkipPattern, handleNonJSON bool) error {
-	// Buffer for reading lines
-	scanner := bufio.NewScanner(r)
+	return f.ProcessStreamContext(context.Background(), r, w, formatter, skipPatterns, handleNonJSON)
+}
+
+// ProcessStreamContext is like ProcessStream, but stops when ctx is cancelled
+// and returns ctx.Err(). Cancellation also interrupts a read that is blocked
+// waiting for input, though the read itself only returns once r produces data
+// or is closed.
+func (f *TemplateFormatter) ProcessStreamContext(ctx context.Context, r io.Reader, w io.Writer, formatter Formatter, skipPatterns []SkipPattern, handleNonJSON bool) error {
+	// Lines are read in a goroutine, which must stop if the stream ends
+	// before the input does, such as once the head limit is reached
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	nextLine := scanLines(ctx, r, f.splitFunc(), f.columnsIdle())
+	next := func() (string, string, error) {
+		line, err := nextLine()
+		return line, "", err
+	}
+	return f.processLines(ctx, next, w, formatter, skipPatterns, handleNonJSON)
+}
+
+// lineReader returns the next line of input and the name of the input it was
+// read from, if there are several, or io.EOF at the end of the input
+type lineReader func() (line, source string, err error)
+
+// processLines implements ProcessStreamContext for lines read from next
+func (f *TemplateFormatter) processLines(ctx context.Context, next lineReader, w io.Writer, formatter Formatter, skipPatterns []SkipPattern, handleNonJSON bool) error {
+	var err error
+	if f.tail > 0 {
+		// Hold back the output, writing the last records once the stream ends
+		tail := newTailWriter(w, f.tail)
+		err = f.processStream(ctx, next, tail, formatter, skipPatterns, handleNonJSON)
+		if tailErr := tail.WriteTail(); err == nil {
+			err = tailErr
+		}
+	} else {
+		err = f.processStream(ctx, next, w, formatter, skipPatterns, handleNonJSON)
+	}
 
+	// Flush whatever was written, even if the stream ended with an error
+	if flushErr := flushWriter(w); err == nil {
+		err = flushErr
+	}
+	if f.levelWriter != nil {
+		if flushErr := flushWriter(f.levelWriter); err == nil {
+			err = flushErr
+		}
+	}
+
+	// A closed output (e.g. piping into head) is a normal way for a stream to end
+	if isBrokenPipe(err) {
+		return fmt.Errorf("%w: %w", ErrOutputClosed, err)
+	}
+	return err
+}
+
+// ErrOutputClosed is returned by ProcessStream when the output is closed by
+// the reader, such as when piping into head or quitting less
+var ErrOutputClosed = errors.New("output closed")
+
+// isBrokenPipe reports whether err was caused by writing to a closed pipe
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// processStream implements processLines, without the final flush
+func (f *TemplateFormatter) processStream(ctx context.Context, nextLine lineReader, w io.Writer, formatter Formatter, skipPatterns []SkipPattern, handleNonJSON bool) error {
 	inNonJSON := false
+	firstLine := true
+	pacer := f.newReplayPacer()
+	unique := f.newUniqueFilter()
+	sampler := f.newSampler()
+	columns := f.newColumnBatch()
+	colors := f.newColorFilter()
+	groups := f.newGroupTracker()
+	skipCheck := f.newSkipFieldCheck(skipPatterns, formatter)
+	written := 0
+
+	// out is where the last record was written, and lastData the record,
+	// which non-JSON lines follow
+	out := w
+	lastData := map[string]interface{}{}
+
+	// writeRecord writes the formatted output of a record
+	writeRecord := func(data map[string]interface{}, formatted string) error {
+		out, lastData = f.recordWriter(w, data), data
+		formatted = groups.indentLines(data, f.highlight(formatted))
+		if header, ok := groups.start(f, out, data); ok {
+			formatted = header + "\n" + formatted
+		}
+		formatted, err := f.affixLines(data, formatted)
+		if err != nil {
+			return err
+		}
+		formatted = colors.apply(out, formatted)
+		if err := pacer.wait(ctx, out, data); err != nil {
+			return err
+		}
+		if err := startRecord(out, data); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, formatted+"\n"); err != nil {
+			return err
+		}
+		return flushRecord(out)
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	// writeLine writes a non-JSON line or a comment after the last record,
+	// or on its own if it was read from a different input
+	writeLine := func(line, source string) error {
+		if source != "" && lastData[MergeSourceField] != source {
+			out, lastData = w, map[string]interface{}{MergeSourceField: source}
+		}
+		line, err := f.affixLines(lastData, groups.indentLines(lastData, f.highlight(line)))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, colors.apply(out, line)+"\n"); err != nil {
+			return err
+		}
+		return flushRecord(out)
+	}
+
+	// writeColumns writes the records waiting to be aligned into columns
+	writeColumns := func() error {
+		records, rows := columns.take()
+		for i, data := range records {
+			if err := writeRecord(data, rows[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// finish ends the stream with err, once any records waiting to be
+	// aligned are written
+	finish := func(err error) error {
+		skipCheck.finish()
+		if columnsErr := writeColumns(); err == nil {
+			err = columnsErr
+		}
+		return err
+	}
+
+	for {
+		// Stop between records once cancelled
+		if err := ctx.Err(); err != nil {
+			return finish(err)
+		}
+
+		line, source, err := nextLine()
+		if errors.Is(err, errInputIdle) {
+			// Write the records waiting to be aligned instead of holding
+			// them back until more input arrives
+			if err := writeColumns(); err != nil {
+				return finish(err)
+			}
+			continue
+		} else if errors.Is(err, io.EOF) {
+			return finish(nil)
+		} else if err != nil {
+			return finish(err)
+		}
+		if firstLine {
+			// Files written by some Windows tools start with a byte order mark
+			line = strings.TrimPrefix(line, utf8BOM)
+			firstLine = false
+		}
 		if line == "" {
 			continue
 		}
 
-		// Try to parse as JSON
-		var data map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &data); err != nil {
+		// Pass comments through as they are, or leave them out
+		if f.isComment(line) {
+			if f.comments == CommentsDrop {
+				continue
+			}
+			if err := writeColumns(); err != nil {
+				return finish(err)
+			}
+			if err := writeLine(line, source); err != nil {
+				return finish(err)
+			}
+			continue
+		}
+
+		// Try to parse the line as a record, ignoring any indentation. Lines
+		// that aren't records are passed through as they are.
+		data, err := f.inputParser.Parse([]byte(strings.TrimLeft(line, " \t")))
+		if err != nil {
 			// Handle non-JSON data
 			if handleNonJSON {
+				// Keep records aligned into columns before the line
+				if err := writeColumns(); err != nil {
+					return finish(err)
+				}
+
 				// Use a fixed format for non-JSON data with red prefix (if colors are enabled)
 				var prefix string
 				if f.noColors {
@@ -957,13 +2607,15 @@ func (f *TemplateFormatter) ProcessStream(r io.Reader, w io.Writer, formatter Fo
 				// Add an extra linebreak before blocks of non-JSON data.
 				if !inNonJSON {
 					inNonJSON = true
-					if _, err := io.WriteString(w, "\n"); err != nil {
-						return err
+					if !f.compactNonJSON {
+						if _, err := io.WriteString(out, "\n"); err != nil {
+							return finish(err)
+						}
 					}
 				}
 
-				if _, err := io.WriteString(w, formatted+"\n"); err != nil {
-					return err
+				if err := writeLine(formatted, source); err != nil {
+					return finish(err)
 				}
 
 				// Continue processing
@@ -971,47 +2623,331 @@ func (f *TemplateFormatter) ProcessStream(r io.Reader, w io.Writer, formatter Fo
 			}
 
 			// If not handling non-JSON data, return the error
-			return errors.Join(err, fmt.Errorf("invalid JSON: %s", line))
+			return finish(err)
+		}
+
+		data = f.normalizeRecord(data)
+		if source != "" {
+			data[MergeSourceField] = source
 		}
 
 		// Skip record if it matches any pattern
+		skipCheck.observe(data)
 		if shouldSkip(data, skipPatterns) {
 			continue
 		}
+		if skipper, ok := formatter.(recordSkipper); ok && skipper.ShouldSkip(data) {
+			continue
+		}
+		if !unique.isNew(data) {
+			continue
+		}
+		if !sampler.keep() {
+			continue
+		}
+		f.fieldCounter.Add(data)
+
+		if err := f.prepareRecord(ctx, data); err != nil {
+			return finish(err)
+		}
 
 		// Finalize a non-JSON block if we were in one.
 		if inNonJSON {
 			inNonJSON = false
-			if _, err := io.WriteString(w, "\n"); err != nil {
-				return err
+			if !f.compactNonJSON {
+				if _, err := io.WriteString(out, "\n"); err != nil {
+					return finish(err)
+				}
 			}
 		}
 
-		formatted, err := formatter.Format(data)
-		if err != nil {
-			return err
+		if columns != nil {
+			// Collect the record to be aligned with the rest of its batch
+			columns.add(data)
+			if columns.full() {
+				if err := writeColumns(); err != nil {
+					return finish(err)
+				}
+			}
+		} else {
+			formatted, err := formatter.Format(data)
+			if err != nil {
+				return finish(err)
+			}
+			if err := writeRecord(data, f.joinLines(formatted)); err != nil {
+				return finish(err)
+			}
 		}
 
-		if _, err := io.WriteString(w, formatted+"\n"); err != nil {
-			return err
+		// Stop without reading further once enough records are written
+		written++
+		if f.head > 0 && written >= f.head {
+			return finish(nil)
 		}
 	}
+}
+
+// normalizeRecord applies the normalizations that come before a record is
+// matched against skip patterns, returning the normalized record
+func (f *TemplateFormatter) normalizeRecord(data map[string]interface{}) map[string]interface{} {
+	data = normalizeKeys(data, f.normalizeKeys)
+	liftFields(data, f.liftFields, f.liftOverwrite)
+	normalizeLevel(data, f.levelField)
+	normalizeTime(data, f.timeLayout)
+	return data
+}
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
+// prepareRecord truncates, runs commands on, derives and escapes the fields
+// of a record that is going to be written
+func (f *TemplateFormatter) prepareRecord(ctx context.Context, data map[string]interface{}) error {
+	truncateFields(data, f.maxFieldLength)
+	f.applyFieldCommands(ctx, data)
+	if err := f.applyDerivedFields(data); err != nil {
 		return err
 	}
-
+	if f.escapeNewlines {
+		escapeFields(data)
+	}
 	return nil
 }
 
+// joinLines joins the lines of a formatted record with the separator set by
+// WithSingleLine, skipping blank lines. Without one, formatted is returned
+// unchanged.
+func (f *TemplateFormatter) joinLines(formatted string) string {
+	if f.singleLineSep == nil || !strings.ContainsAny(formatted, "\r\n") {
+		return formatted
+	}
+
+	lines := strings.FieldsFunc(formatted, func(r rune) bool { return r == '\n' || r == '\r' })
+	nonBlank := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonBlank = append(nonBlank, line)
+		}
+	}
+	return strings.Join(nonBlank, *f.singleLineSep)
+}
+
+// utf8BOM is the byte order mark some tools write at the start of UTF-8 files
+const utf8BOM = "\uFEFF"
+
+// skipBOM returns a reader that reads from r, skipping a byte order mark at
+// the start of the input
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && string(prefix) == utf8BOM {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// maxLineSize is the longest line, or record, that can be read from a stream.
+// It is far more than bufio.Scanner allows by default, so that a record with
+// a huge field, such as an embedded stack trace, still reaches the
+// formatter and can be truncated there.
+const maxLineSize = 64 * 1024 * 1024
+
+// errInputIdle is returned by a line reader that waited longer than its idle
+// time for input. Reading again keeps waiting for the same line.
+var errInputIdle = errors.New("no input")
+
+// scanLines returns a function that reads the next line from r, returning
+// io.EOF at the end of the input. If split is not nil, the input is split
+// into records with it instead of into lines. Lines are read in a separate
+// goroutine so that a blocking read doesn't delay cancellation; callers must
+// cancel ctx once they stop reading, and the goroutine exits once its pending
+// read returns. Reads return errInputIdle after waiting idle for input, if
+// idle is positive.
+func scanLines(ctx context.Context, r io.Reader, split bufio.SplitFunc, idle time.Duration) func() (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	if split != nil {
+		scanner.Split(split)
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	results := make(chan result)
+
+	go func() {
+		defer close(results)
+		for scanner.Scan() {
+			select {
+			case results <- result{line: scanner.Text()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			select {
+			case results <- result{err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return func() (string, error) {
+		var timeout <-chan time.Time
+		if idle > 0 {
+			timer := time.NewTimer(idle)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timeout:
+			return "", errInputIdle
+		case res, ok := <-results:
+			if !ok {
+				return "", io.EOF
+			}
+			return res.line, res.err
+		}
+	}
+}
+
+// ProcessSingle reads exactly one record from r and writes it formatted to w.
+// JSON objects may span multiple lines; with another input parser the whole
+// input is parsed as one line. With a record delimiter the record ends at the
+// first delimiter. It returns an error if the input is empty or if anything
+// other than whitespace, or further delimiters, follows the record.
+func (f *TemplateFormatter) ProcessSingle(r io.Reader, w io.Writer, formatter Formatter) error {
+	input, err := io.ReadAll(skipBOM(r))
+	if err != nil {
+		return err
+	}
+
+	var trailing []byte
+	if f.recordDelimiter != "" {
+		if i := bytes.Index(input, []byte(f.recordDelimiter)); i >= 0 {
+			input, trailing = input[:i], input[i+len(f.recordDelimiter):]
+		}
+		trailing = bytes.ReplaceAll(trailing, []byte(f.recordDelimiter), nil)
+	}
+	if len(bytes.TrimSpace(trailing)) > 0 {
+		return errors.New("unexpected data after the first record")
+	}
+
+	data, err := f.parseSingle(input)
+	if err != nil {
+		return err
+	}
+
+	data = f.normalizeRecord(data)
+	if err := f.prepareRecord(context.Background(), data); err != nil {
+		return err
+	}
+
+	formatted, err := formatter.Format(data)
+	if err != nil {
+		return err
+	}
+	formatted, err = f.affixLines(data, f.highlight(f.joinLines(formatted)))
+	if err != nil {
+		return err
+	}
+	formatted = f.newColorFilter().apply(w, formatted)
+
+	if _, err := io.WriteString(w, formatted+"\n"); err != nil {
+		return err
+	}
+	return flushWriter(w)
+}
+
+// parseSingle parses the input of ProcessSingle as one record
+func (f *TemplateFormatter) parseSingle(input []byte) (map[string]interface{}, error) {
+	if _, ok := f.inputParser.(JSONParser); !ok {
+		if input = bytes.TrimSpace(input); len(input) == 0 {
+			return nil, errors.New("no input")
+		}
+		return f.inputParser.Parse(input)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	var data map[string]interface{}
+	if err := decoder.Decode(&data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("invalid JSON: no input")
+		}
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	// Make sure nothing but whitespace follows the object
+	var trailing json.RawMessage
+	if err := decoder.Decode(&trailing); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid JSON: unexpected data after the first object")
+	}
+	return data, nil
+}
+
 // SkipPattern represents a field and value to match for skipping log records
 type SkipPattern struct {
 	Field string
 	Value string
+	// Exact only matches values equal to Value. Otherwise values that
+	// contain Value match too.
+	Exact bool
 }
 
-// shouldSkip checks if a log record should be skipped based on the skip patterns
+// lookupField returns the value of a field, which may be a dotted path into
+// nested maps such as context.user.role. A top-level key containing dots is
+// used when the path doesn't lead to a value.
+func lookupField(data map[string]interface{}, field string) (interface{}, bool) {
+	if strings.Contains(field, ".") {
+		if value, ok := digPath(data, strings.Split(field, ".")); ok {
+			return value, true
+		}
+	}
+	value, ok := data[field]
+	return value, ok
+}
+
+// mapStrings replaces each string value in data, and in any maps or arrays it
+// contains, with the result of calling fn on it
+func mapStrings(data map[string]interface{}, fn func(string) string) {
+	for key, value := range data {
+		data[key] = mapStringValue(value, fn)
+	}
+}
+
+// mapStringValue returns fn(value) if value is a string, or value with the
+// strings within it replaced if it is a map or an array
+func mapStringValue(value interface{}, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		mapStrings(v, fn)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = mapStringValue(item, fn)
+		}
+	}
+	return value
+}
+
+// digPath walks nested maps following the keys in path
+func digPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = data
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// shouldSkip checks if a log record should be skipped based on the skip
+// patterns. Fields may be dotted paths into nested maps.
 func shouldSkip(data map[string]interface{}, skipPatterns []SkipPattern) bool {
 	if len(skipPatterns) == 0 {
 		return false
@@ -1019,7 +2955,7 @@ func shouldSkip(data map[string]interface{}, skipPatterns []SkipPattern) bool {
 
 	// Check each skip pattern against the data
 	for _, pattern := range skipPatterns {
-		if actualValue, ok := data[pattern.Field]; ok {
+		if actualValue, ok := lookupField(data, pattern.Field); ok {
 			// Convert the actual value to string for comparison
 			actualValueStr := fmt.Sprintf("%v", actualValue)
 
@@ -1027,8 +2963,11 @@ func shouldSkip(data map[string]interface{}, skipPatterns []SkipPattern) bool {
 			if actualValueStr == pattern.Value {
 				return true
 			}
+			if pattern.Exact {
+				continue
+			}
 
-			// Check if the pattern value is contained within the actual value
+			// Otherwise check if the pattern value is contained within the actual value
 			// This allows for partial matches like "auth.action=upload.download" matching "auth.action=upload.download.complete"
 			// or "msg=upload: Downloading" matching a message that contains this text
 			if strings.Contains(actualValueStr, pattern.Value) {
@@ -2,10 +2,19 @@ package formatter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"text/template"
 	"time"
 )
 
@@ -58,6 +67,36 @@ func TestTemplateFormatter(t *testing.T) {
 			data:     map[string]interface{}{"message": "test message"},
 			expected: "      test message",
 		},
+		{
+			name:     "pad function with negative length",
+			format:   "{{.count | pad -5}} {{.message}}",
+			data:     map[string]interface{}{"count": 42, "message": "test message"},
+			expected: "   42 test message",
+		},
+		{
+			name:     "padLeft function",
+			format:   "{{.count | padLeft 6}} {{.message}}",
+			data:     map[string]interface{}{"count": 3.5, "message": "test message"},
+			expected: "   3.5 test message",
+		},
+		{
+			name:     "padLeft function with longer text",
+			format:   "{{.count | padLeft 2}} {{.message}}",
+			data:     map[string]interface{}{"count": 12345, "message": "test message"},
+			expected: "12345 test message",
+		},
+		{
+			name:     "pad function ignores color codes",
+			format:   `{{.level | color "red" | pad 6}}|`,
+			data:     map[string]interface{}{"level": "info"},
+			expected: "\033[31minfo\033[0m  |",
+		},
+		{
+			name:     "pad function counts characters not bytes",
+			format:   "{{.level | padLeft 5}}|",
+			data:     map[string]interface{}{"level": "héé"},
+			expected: "  héé|",
+		},
 	}
 
 	for _, tt := range tests {
@@ -123,6 +162,34 @@ func TestDateFunction(t *testing.T) {
 			expected:   "10/03/2024",
 			dateFormat: "02/01/2006",
 		},
+		{
+			name:       "date function with millisecond epoch",
+			format:     "{{.timestamp | date}}",
+			data:       map[string]interface{}{"timestamp": json.Number(strconv.FormatInt(now.UnixMilli()+123, 10))},
+			expected:   "2024-03-10 15:04:05.123",
+			dateFormat: "2006-01-02 15:04:05.000",
+		},
+		{
+			name:       "date function with microsecond epoch",
+			format:     "{{.timestamp | date}}",
+			data:       map[string]interface{}{"timestamp": json.Number(strconv.FormatInt(now.UnixMicro()+123456, 10))},
+			expected:   "2024-03-10 15:04:05.123456",
+			dateFormat: "2006-01-02 15:04:05.000000",
+		},
+		{
+			name:       "date function with nanosecond epoch",
+			format:     "{{.timestamp | date}}",
+			data:       map[string]interface{}{"timestamp": json.Number(strconv.FormatInt(now.UnixNano()+123456789, 10))},
+			expected:   "2024-03-10 15:04:05.123456789",
+			dateFormat: "2006-01-02 15:04:05.000000000",
+		},
+		{
+			name:       "date function with float millisecond epoch",
+			format:     "{{.timestamp | date}}",
+			data:       map[string]interface{}{"timestamp": float64(now.UnixMilli() + 250)},
+			expected:   "2024-03-10 15:04:05.250",
+			dateFormat: "2006-01-02 15:04:05.000",
+		},
 		{
 			name:       "date function with common log format",
 			format:     "{{.timestamp | date}}",
@@ -250,6 +317,28 @@ func TestProcessStream(t *testing.T) {
 	}
 }
 
+func TestProcessStreamWithBOM(t *testing.T) {
+	input := "\uFEFF" + `{"level":"info","message":"test1"}` + "\n" +
+		`  {"level":"error","message":"test2"}` + "\n" +
+		"\t  at main.go:12\n"
+
+	formatter, err := NewTemplateFormatter("{{.level}} {{.message}}", WithNoColors(true), WithCompactNonJSON(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	// Indented records are parsed, while other lines keep their indentation
+	expected := "info test1\nerror test2\n>>> \t  at main.go:12\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
 func TestMultFunction(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -337,54 +426,131 @@ func TestMultFunction(t *testing.T) {
 	}
 }
 
-func TestPrintfFunction(t *testing.T) {
+func TestMultFunctionPrecision(t *testing.T) {
+	data := map[string]interface{}{
+		"price": json.Number("1.005"),
+		"count": float64(5),
+		"ratio": 0.123456789,
+	}
+
 	tests := []struct {
 		name     string
-		format   interface{}
+		template string
+		expected string
+	}{
+		{
+			name:     "default keeps two decimals",
+			template: "{{.ratio | mult 1}}",
+			expected: "0.12",
+		},
+		{
+			name:     "explicit precision",
+			template: "{{.ratio | mult 100 4}}",
+			expected: "12.3457",
+		},
+		{
+			name:     "whole numbers keep the precision",
+			template: "{{.count | mult 2 2}}",
+			expected: "10.00",
+		},
+		{
+			name:     "full precision",
+			template: "{{.price | mult 1 -1}}",
+			expected: "1.005",
+		},
+		{
+			name:     "full precision of a whole number",
+			template: "{{.count | mult 2 -1}}",
+			expected: "10",
+		},
+		{
+			name:     "zero decimals",
+			template: "{{.price | mult 100 0}}",
+			expected: "100",
+		},
+		{
+			name:     "precision as a string",
+			template: `{{.ratio | mult 1 "3"}}`,
+			expected: "0.123",
+		},
+		{
+			name:     "invalid precision uses the default",
+			template: `{{.ratio | mult 1 "three"}}`,
+			expected: "0.12",
+		},
+		{
+			name:     "missing precision uses the default",
+			template: `{{.count | mult 2 .digits}}`,
+			expected: "10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPercentFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		decimals interface{}
 		value    interface{}
 		expected string
 	}{
 		{
-			name:     "integer with decimal format",
-			format:   "%.2f",
-			value:    10,
-			expected: "%!f(int=10)",
+			name:     "ratio with one decimal",
+			decimals: 1,
+			value:    0.873,
+			expected: "87.3%",
 		},
 		{
-			name:     "float with precision",
-			format:   "%.1f",
-			value:    3.14159,
-			expected: "3.1",
+			name:     "ratio rounded to integer",
+			decimals: 0,
+			value:    0.8765,
+			expected: "88%",
 		},
 		{
-			name:     "string with padding",
-			format:   "%-10s",
-			value:    "test",
-			expected: "test      ",
+			name:     "json.Number ratio",
+			decimals: 2,
+			value:    json.Number("0.12345"),
+			expected: "12.35%",
 		},
 		{
-			name:     "multiple values in format string",
-			format:   "%s: %d",
-			value:    "count",
-			expected: "count: %!d(MISSING)",
+			name:     "string decimals",
+			decimals: "1",
+			value:    1,
+			expected: "100.0%",
 		},
 		{
-			name:     "nil format",
-			format:   nil,
-			value:    "test",
-			expected: "test",
+			name:     "negative decimals treated as zero",
+			decimals: -2,
+			value:    0.5,
+			expected: "50%",
 		},
 		{
-			name:     "nil value",
-			format:   "%.2f",
-			value:    nil,
-			expected: "<nil>",
+			name:     "non-numeric value",
+			decimals: 1,
+			value:    "abc",
+			expected: "NaN",
 		},
 		{
-			name:     "non-string format",
-			format:   123,
-			value:    "test",
-			expected: "123: test",
+			name:     "nil value",
+			decimals: 1,
+			value:    nil,
+			expected: "NaN",
 		},
 	}
 
@@ -392,152 +558,155 @@ func TestPrintfFunction(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatter.printfFunc(tt.format, tt.value)
+			result := formatter.percentFunc(tt.decimals, tt.value)
 			if result != tt.expected {
-				t.Errorf("printfFunc(%v, %v) = %v, want %v", tt.format, tt.value, result, tt.expected)
+				t.Errorf("percentFunc(%v, %v) = %v, want %v", tt.decimals, tt.value, result, tt.expected)
 			}
 		})
 	}
 }
 
-func TestTemplateWithNewFunctions(t *testing.T) {
+func TestDeltaFunction(t *testing.T) {
 	tests := []struct {
 		name     string
 		template string
 		data     map[string]interface{}
+		noColors bool
 		expected string
 	}{
 		{
-			name:     "mult function with integers",
-			template: "{{.value | mult 5}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "50",
+			name:     "increase",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"expected": 10, "actual": json.Number("15")},
+			expected: "\033[32m+5\033[0m",
 		},
 		{
-			name:     "mult function with non-numeric value",
-			template: "{{.value | mult 5}}",
-			data:     map[string]interface{}{"value": "abc"},
-			expected: "NaN",
+			name:     "decrease",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"expected": "2.5", "actual": 1.25},
+			expected: "\033[31m-1.25\033[0m",
 		},
 		{
-			name:     "printf function with float format",
-			template: "{{.value | printf \"%.2f\"}}",
-			data:     map[string]interface{}{"value": 3.14159},
-			expected: "3.14",
+			name:     "no change",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"expected": 3, "actual": 3.0},
+			expected: "0",
 		},
 		{
-			name:     "printf function with string format",
-			template: "{{.value | printf \"Value: %s\"}}",
-			data:     map[string]interface{}{"value": "test"},
-			expected: "Value: test",
+			name:     "without color",
+			template: "{{delta .expected .actual false}}",
+			data:     map[string]interface{}{"expected": 0.1, "actual": 0.3},
+			expected: "+0.20",
+		},
+		{
+			name:     "colors disabled",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"expected": 7, "actual": 2},
+			noColors: true,
+			expected: "-5",
+		},
+		{
+			name:     "non-numeric",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"expected": "n/a", "actual": 2},
+			expected: "NaN",
+		},
+		{
+			name:     "missing",
+			template: "{{delta .expected .actual}}",
+			data:     map[string]interface{}{"actual": 2},
+			expected: "NaN",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			formatter, err := NewTemplateFormatter(tt.template)
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(tt.noColors))
 			if err != nil {
 				t.Fatalf("Failed to create formatter: %v", err)
 			}
-
 			result, err := formatter.Format(tt.data)
 			if err != nil {
-				t.Fatalf("Format error: %v", err)
+				t.Fatalf("Format failed: %v", err)
 			}
-
 			if result != tt.expected {
-				t.Errorf("Format result = %q, want %q", result, tt.expected)
+				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}
 		})
 	}
 }
 
-func TestProcessStreamWithNonJSON(t *testing.T) {
+func TestHashFunctions(t *testing.T) {
 	tests := []struct {
-		name            string
-		input           string
-		handleNonJSON   bool
-		noColors        bool
-		expectedSuccess bool
-		expectedOutput  string
+		name     string
+		template string
+		data     map[string]interface{}
+		noColors bool
+		expected string
 	}{
 		{
-			name:            "valid JSON only",
-			input:           `{"level":"info","message":"test1"}` + "\n" + `{"level":"error","message":"test2"}`,
-			handleNonJSON:   false, // Default behavior
-			noColors:        false,
-			expectedSuccess: true,
-			expectedOutput:  "info test1\nerror test2\n",
+			name:     "short hash",
+			template: "{trace_id | shortHash}",
+			data:     map[string]interface{}{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+			expected: "765clr",
 		},
 		{
-			name:            "non-JSON with handling disabled",
-			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
-			handleNonJSON:   false, // Default behavior
-			noColors:        false,
-			expectedSuccess: false,
-			expectedOutput:  "",
+			name:     "short hash with length",
+			template: "{trace_id | shortHash 10}",
+			data:     map[string]interface{}{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+			expected: "765clrj5oj",
 		},
 		{
-			name:            "non-JSON with handling enabled",
-			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
-			handleNonJSON:   true,
-			noColors:        false,
-			expectedSuccess: true,
-			expectedOutput:  "info test1\n\n\033[31m>>>\033[0m This is not JSON\n\nerror test2\n",
+			name:     "numbers hash like their string form",
+			template: "{{.a | shortHash}} {{.b | shortHash}}",
+			data:     map[string]interface{}{"a": json.Number("42"), "b": 42},
+			expected: "ed3lpd ed3lpd",
 		},
 		{
-			name:            "multiple non-JSON lines with handling enabled",
-			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `Another non-JSON line` + "\n" + `{"level":"error","message":"test2"}`,
-			handleNonJSON:   true,
-			noColors:        false,
-			expectedSuccess: true,
-			expectedOutput:  "info test1\n\n\033[31m>>>\033[0m This is not JSON\n\033[31m>>>\033[0m Another non-JSON line\n\nerror test2\n",
+			name:     "missing value",
+			template: "[{{.trace_id | shortHash}}]",
+			data:     map[string]interface{}{},
+			expected: "[]",
 		},
 		{
-			name:            "non-JSON with handling enabled and no colors",
-			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
-			handleNonJSON:   true,
-			noColors:        true,
-			expectedSuccess: true,
-			expectedOutput:  "info test1\n\n>>> This is not JSON\n\nerror test2\n",
+			name:     "color by hash",
+			template: "{{.id | colorByHash}} {{.other | colorByHash}}",
+			data:     map[string]interface{}{"id": "req-1", "other": "req-2"},
+			expected: "\033[35mreq-1\033[0m \033[95mreq-2\033[0m",
+		},
+		{
+			name:     "color by the hash of a key",
+			template: "{{.message | colorByHash .id}}",
+			data:     map[string]interface{}{"id": "req-1", "message": "done"},
+			expected: "\033[35mdone\033[0m",
+		},
+		{
+			name:     "color by hash without colors",
+			template: "{{.id | shortHash | colorByHash}}",
+			data:     map[string]interface{}{"id": "req-1"},
+			noColors: true,
+			expected: "ihbbru",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			options := []FormatterOption{}
-			if tt.noColors {
-				options = append(options, WithNoColors(true))
-			}
-
-			formatter, err := NewTemplateFormatter("{{.level}} {{.message}}", options...)
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(tt.noColors))
 			if err != nil {
 				t.Fatalf("Failed to create formatter: %v", err)
 			}
-
-			r := strings.NewReader(tt.input)
-			var buf bytes.Buffer
-
-			err = formatter.ProcessStream(r, &buf, formatter, nil, tt.handleNonJSON)
-
-			// Check if the error result matches expectations
-			if tt.expectedSuccess && err != nil {
-				t.Fatalf("ProcessStream failed but expected success: %v", err)
-			} else if !tt.expectedSuccess && err == nil {
-				t.Fatalf("ProcessStream succeeded but expected failure")
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
 			}
-
-			// Only check output if we expected success
-			if tt.expectedSuccess {
-				if buf.String() != tt.expectedOutput {
-					t.Errorf("Expected:\n%s\n\nGot:\n%s", tt.expectedOutput, buf.String())
-				}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}
 		})
 	}
 }
 
-func TestComparisonFunctions(t *testing.T) {
+func TestFillFunction(t *testing.T) {
 	tests := []struct {
 		name     string
 		template string
@@ -545,203 +714,2734 @@ func TestComparisonFunctions(t *testing.T) {
 		expected string
 	}{
 		{
-			name:     "eq function with equal integers",
-			template: "{{if eq .value 10}}equal{{else}}not equal{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "equal",
-		},
-		{
-			name:     "eq function with unequal integers",
-			template: "{{if eq .value 20}}equal{{else}}not equal{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not equal",
+			name:     "dotted leader",
+			template: `{{fill 20 .key .value "."}}`,
+			data:     map[string]interface{}{"key": "status", "value": 200},
+			expected: "status...........200",
 		},
 		{
-			name:     "ne function with equal integers",
-			template: "{{if ne .value 10}}not equal{{else}}equal{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "equal",
+			name:     "dots by default",
+			template: `{{fill 12 .key .value}}`,
+			data:     map[string]interface{}{"key": "a", "value": "b"},
+			expected: "a..........b",
 		},
 		{
-			name:     "ne function with unequal integers",
-			template: "{{if ne .value 20}}not equal{{else}}equal{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not equal",
+			name:     "repeated pattern",
+			template: `{{fill 12 .key .value ". "}}`,
+			data:     map[string]interface{}{"key": "a", "value": "b"},
+			expected: "a. . . . . b",
 		},
 		{
-			name:     "gt function with greater value",
-			template: "{{if gt .value 5}}greater{{else}}not greater{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "greater",
+			name:     "ignores color codes",
+			template: `{{fill 10 (color "red" .key) .value "─"}}`,
+			data:     map[string]interface{}{"key": "ab", "value": "cd"},
+			expected: "\033[31mab\033[0m──────cd",
 		},
 		{
-			name:     "gt function with equal value",
-			template: "{{if gt .value 10}}greater{{else}}not greater{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not greater",
+			name:     "no room for the fill",
+			template: `{{fill 5 .key .value}}`,
+			data:     map[string]interface{}{"key": "method", "value": "GET"},
+			expected: "method GET",
 		},
 		{
-			name:     "gt function with lesser value",
-			template: "{{if gt .value 15}}greater{{else}}not greater{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not greater",
+			name:     "missing value",
+			template: `[{{fill 6 .key .value}}]`,
+			data:     map[string]interface{}{"key": "ok"},
+			expected: "[ok....]",
 		},
-		{
-			name:     "lt function with greater value",
-			template: "{{if lt .value 15}}less{{else}}not less{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "less",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSparklineFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "increasing values",
+			value:    []interface{}{0.0, 1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0},
+			expected: "▁▂▃▄▅▆▇█",
 		},
 		{
-			name:     "lt function with equal value",
-			template: "{{if lt .value 10}}less{{else}}not less{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not less",
+			name:     "unordered values",
+			value:    []interface{}{10.0, 80.0, 45.0, 10.0},
+			expected: "▁█▄▁",
 		},
 		{
-			name:     "lt function with lesser value",
-			template: "{{if lt .value 5}}less{{else}}not less{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not less",
+			name:     "mixed numeric types",
+			value:    []interface{}{1, json.Number("8"), "4.5"},
+			expected: "▁█▄",
 		},
 		{
-			name:     "eq function with strings",
-			template: "{{if eq .message \"test\"}}equal{{else}}not equal{{end}}",
-			data:     map[string]interface{}{"message": "test"},
-			expected: "equal",
+			name:     "non-numeric elements",
+			value:    []interface{}{1.0, "n/a", nil, 2.0},
+			expected: "▁  █",
 		},
 		{
-			name:     "eq function with nil values",
-			template: "{{if eq .value nil}}equal to nil{{else}}not nil{{end}}",
-			data:     map[string]interface{}{"value": nil},
-			expected: "equal to nil",
+			name:     "typed slice",
+			value:    []int{5, 10},
+			expected: "▁█",
 		},
 		{
-			name:     "eq function with mixed number formats",
-			template: "{{if eq .value \"10\"}}equal{{else}}not equal{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "equal",
+			name:     "equal values",
+			value:    []interface{}{3.0, 3.0, 3.0},
+			expected: "▁▁▁",
+		},
+		{
+			name:     "empty array",
+			value:    []interface{}{},
+			expected: "",
+		},
+		{
+			name:     "not an array",
+			value:    42,
+			expected: "",
+		},
+		{
+			name:     "nil",
+			value:    nil,
+			expected: "",
 		},
 	}
 
+	formatter := &TemplateFormatter{}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			formatter, err := NewTemplateFormatter(tt.template)
+			result := formatter.sparklineFunc(tt.value)
+			if result != tt.expected {
+				t.Errorf("sparklineFunc(%v) = %q, want %q", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrimFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "trimPrefix",
+			format:   `{{.logger | trimPrefix "com.example."}}`,
+			data:     map[string]interface{}{"logger": "com.example.api.Handler"},
+			expected: "api.Handler",
+		},
+		{
+			name:     "trimPrefix without match",
+			format:   `{{.logger | trimPrefix "org."}}`,
+			data:     map[string]interface{}{"logger": "com.example.api"},
+			expected: "com.example.api",
+		},
+		{
+			name:     "trimSuffix",
+			format:   `{host | trimSuffix ".internal"}`,
+			data:     map[string]interface{}{"host": "db1.internal"},
+			expected: "db1",
+		},
+		{
+			name:     "trimSuffix on number",
+			format:   `{{.size | trimSuffix "00"}}`,
+			data:     map[string]interface{}{"size": 1500},
+			expected: "15",
+		},
+		{
+			name:     "trimSpace",
+			format:   "[{message | trimSpace}]",
+			data:     map[string]interface{}{"message": "  padded \n"},
+			expected: "[padded]",
+		},
+		{
+			name:     "nil values",
+			format:   `[{{.missing | trimPrefix "a"}}{{.missing | trimSuffix "b"}}{{.missing | trimSpace}}]`,
+			data:     map[string]interface{}{},
+			expected: "[]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format)
 			if err != nil {
 				t.Fatalf("Failed to create formatter: %v", err)
 			}
 
 			result, err := formatter.Format(tt.data)
 			if err != nil {
-				t.Fatalf("Formatter.Format() error = %v", err)
+				t.Fatalf("Format failed: %v", err)
 			}
 
 			if result != tt.expected {
-				t.Errorf("Format result = %q, want %q", result, tt.expected)
+				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
 			}
 		})
 	}
 }
 
-func TestIssetFunction(t *testing.T) {
-	type TestStruct struct {
-		Name  string
-		Email string
-		Age   int
-	}
-
+func TestParseJSONFunction(t *testing.T) {
 	tests := []struct {
 		name     string
-		template string
+		format   string
 		data     map[string]interface{}
 		expected string
 	}{
 		{
-			name:     "isset function with existing field in map",
-			template: "{{if isset \"value\" .}}exists{{else}}not exists{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "exists",
+			name:     "double-encoded object",
+			format:   "{payload | parseJSON | pretty}",
+			data:     map[string]interface{}{"payload": `{"a":1}`},
+			expected: "{a=1}",
 		},
 		{
-			name:     "isset function with non-existing field in map",
-			template: "{{if isset \"missing\" .}}exists{{else}}not exists{{end}}",
-			data:     map[string]interface{}{"value": 10},
-			expected: "not exists",
+			name:     "double-encoded object as table",
+			format:   "{payload | parseJSON | table 3}",
+			data:     map[string]interface{}{"payload": ` {"a":1} `},
+			expected: "  a  1",
 		},
 		{
-			name:     "isset function with multiple fields",
-			template: "{{if isset \"name\" .}}Name exists{{else}}Name missing{{end}} and {{if isset \"age\" .}}Age exists{{else}}Age missing{{end}}",
-			data:     map[string]interface{}{"name": "Test", "email": "test@example.com"},
-			expected: "Name exists and Age missing",
+			name:     "double-encoded array",
+			format:   "{payload | parseJSON | pretty}",
+			data:     map[string]interface{}{"payload": `[1,"two"]`},
+			expected: "[1, two]",
 		},
 		{
-			name:     "isset function with nil value",
-			template: "{{if isset \"value\" .}}exists{{else}}not exists{{end}}",
-			data:     map[string]interface{}{"value": nil},
-			expected: "exists", // The field exists, even though its value is nil
+			name:     "nested field access",
+			format:   `{{(.payload | parseJSON).user}}`,
+			data:     map[string]interface{}{"payload": `{"user":"alice"}`},
+			expected: "alice",
 		},
 		{
-			name:     "isset function with nil data",
-			template: "{{if isset \"value\" nil}}exists{{else}}not exists{{end}}",
-			data:     map[string]interface{}{},
-			expected: "not exists",
+			name:     "invalid JSON is unchanged",
+			format:   "{payload | parseJSON}",
+			data:     map[string]interface{}{"payload": `{"a":`},
+			expected: `{"a":`,
 		},
 		{
-			name:     "isset function with nested field",
-			template: "{{if isset \"user\" .}}user exists{{else}}user missing{{end}} and {{if isset \"name\" .user}}name exists{{else}}name missing{{end}}",
-			data:     map[string]interface{}{"user": map[string]interface{}{"name": "John"}},
-			expected: "user exists and name exists",
+			name:     "plain string is unchanged",
+			format:   "{payload | parseJSON}",
+			data:     map[string]interface{}{"payload": "true"},
+			expected: "true",
 		},
 		{
-			name:     "isset function with empty string as field name",
-			template: "{{if isset \"\" .}}empty key exists{{else}}empty key missing{{end}}",
-			data:     map[string]interface{}{"": "empty key value"},
-			expected: "empty key exists",
+			name:     "non-string is unchanged",
+			format:   "{payload | parseJSON}",
+			data:     map[string]interface{}{"payload": 42},
+			expected: "42",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			formatter, err := NewTemplateFormatter(tt.template)
+			formatter, err := NewTemplateFormatter(tt.format, WithNoColors(true))
 			if err != nil {
 				t.Fatalf("Failed to create formatter: %v", err)
 			}
 
 			result, err := formatter.Format(tt.data)
 			if err != nil {
-				t.Fatalf("Formatter.Format() error = %v", err)
+				t.Fatalf("Format failed: %v", err)
 			}
 
 			if result != tt.expected {
-				t.Errorf("Format result = %q, want %q", result, tt.expected)
+				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
 			}
 		})
 	}
+}
 
-	// Test isset function directly with a struct (can't be tested via template easily)
-	t.Run("isset function with struct", func(t *testing.T) {
-		formatter := &TemplateFormatter{}
-		testStruct := TestStruct{Name: "Test", Email: "test@example.com", Age: 30}
+func TestEscapeFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "markdown plain text",
+			fn:       "mdEscape",
+			value:    "hello world",
+			expected: "hello world",
+		},
+		{
+			name:     "markdown table breaking characters",
+			fn:       "mdEscape",
+			value:    "a|b `code`",
+			expected: "a\\|b \\`code\\`",
+		},
+		{
+			name:     "markdown emphasis and links",
+			fn:       "mdEscape",
+			value:    "*bold* _it_ [link]",
+			expected: "\\*bold\\* \\_it\\_ \\[link\\]",
+		},
+		{
+			name:     "markdown backslash and newline",
+			fn:       "mdEscape",
+			value:    "C:\\tmp\nnext",
+			expected: "C:\\\\tmp<br>next",
+		},
+		{
+			name:     "markdown number",
+			fn:       "mdEscape",
+			value:    42,
+			expected: "42",
+		},
+		{
+			name:     "markdown nil",
+			fn:       "mdEscape",
+			value:    nil,
+			expected: "",
+		},
+		{
+			name:     "shell plain word",
+			fn:       "shellQuote",
+			value:    "file.txt",
+			expected: "'file.txt'",
+		},
+		{
+			name:     "shell spaces and metacharacters",
+			fn:       "shellQuote",
+			value:    "a b; rm -rf $HOME",
+			expected: "'a b; rm -rf $HOME'",
+		},
+		{
+			name:     "shell single quote",
+			fn:       "shellQuote",
+			value:    "it's",
+			expected: `'it'\''s'`,
+		},
+		{
+			name:     "shell empty string",
+			fn:       "shellQuote",
+			value:    "",
+			expected: "''",
+		},
+		{
+			name:     "shell nil",
+			fn:       "shellQuote",
+			value:    nil,
+			expected: "",
+		},
+	}
 
-		if !formatter.issetFunc("Name", testStruct) {
-			t.Error("Expected Name field to exist in struct")
-		}
+	formatter := &TemplateFormatter{}
 
-		if !formatter.issetFunc("Email", testStruct) {
-			t.Error("Expected Email field to exist in struct")
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result string
+			if tt.fn == "mdEscape" {
+				result = formatter.mdEscapeFunc(tt.value)
+			} else {
+				result = formatter.shellQuoteFunc(tt.value)
+			}
+			if result != tt.expected {
+				t.Errorf("%s(%v) = %q, want %q", tt.fn, tt.value, result, tt.expected)
+			}
+		})
+	}
+}
 
-		if !formatter.issetFunc("Age", testStruct) {
-			t.Error("Expected Age field to exist in struct")
-		}
+func TestPrintfFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   interface{}
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "integer with decimal format",
+			format:   "%.2f",
+			value:    10,
+			expected: "%!f(int=10)",
+		},
+		{
+			name:     "float with precision",
+			format:   "%.1f",
+			value:    3.14159,
+			expected: "3.1",
+		},
+		{
+			name:     "string with padding",
+			format:   "%-10s",
+			value:    "test",
+			expected: "test      ",
+		},
+		{
+			name:     "multiple values in format string",
+			format:   "%s: %d",
+			value:    "count",
+			expected: "count: %!d(MISSING)",
+		},
+		{
+			name:     "nil format",
+			format:   nil,
+			value:    "test",
+			expected: "test",
+		},
+		{
+			name:     "nil value",
+			format:   "%.2f",
+			value:    nil,
+			expected: "<nil>",
+		},
+		{
+			name:     "non-string format",
+			format:   123,
+			value:    "test",
+			expected: "123: test",
+		},
+	}
 
-		if formatter.issetFunc("Address", testStruct) {
-			t.Error("Expected Address field to not exist in struct")
-		}
+	formatter := &TemplateFormatter{}
 
-		// Test with pointer to struct
-		if !formatter.issetFunc("Name", &testStruct) {
-			t.Error("Expected Name field to exist in struct pointer")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatter.printfFunc(tt.format, tt.value)
+			if result != tt.expected {
+				t.Errorf("printfFunc(%v, %v) = %v, want %v", tt.format, tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTemplateWithNewFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "mult function with integers",
+			template: "{{.value | mult 5}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "50",
+		},
+		{
+			name:     "mult function with non-numeric value",
+			template: "{{.value | mult 5}}",
+			data:     map[string]interface{}{"value": "abc"},
+			expected: "NaN",
+		},
+		{
+			name:     "printf function with float format",
+			template: "{{.value | printf \"%.2f\"}}",
+			data:     map[string]interface{}{"value": 3.14159},
+			expected: "3.14",
+		},
+		{
+			name:     "printf function with string format",
+			template: "{{.value | printf \"Value: %s\"}}",
+			data:     map[string]interface{}{"value": "test"},
+			expected: "Value: test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format error: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithNonJSON(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		handleNonJSON   bool
+		noColors        bool
+		compact         bool
+		expectedSuccess bool
+		expectedOutput  string
+	}{
+		{
+			name:            "valid JSON only",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   false, // Default behavior
+			noColors:        false,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\nerror test2\n",
+		},
+		{
+			name:            "non-JSON with handling disabled",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   false, // Default behavior
+			noColors:        false,
+			expectedSuccess: false,
+			expectedOutput:  "",
+		},
+		{
+			name:            "non-JSON with handling enabled",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   true,
+			noColors:        false,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n\n\033[31m>>>\033[0m This is not JSON\n\nerror test2\n",
+		},
+		{
+			name:            "multiple non-JSON lines with handling enabled",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `Another non-JSON line` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   true,
+			noColors:        false,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n\n\033[31m>>>\033[0m This is not JSON\n\033[31m>>>\033[0m Another non-JSON line\n\nerror test2\n",
+		},
+		{
+			name:            "non-JSON with handling enabled and no colors",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   true,
+			noColors:        true,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n\n>>> This is not JSON\n\nerror test2\n",
+		},
+		{
+			name:            "compact non-JSON blocks",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `This is not JSON` + "\n" + `Another non-JSON line` + "\n" + `{"level":"error","message":"test2"}`,
+			handleNonJSON:   true,
+			noColors:        true,
+			compact:         true,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n>>> This is not JSON\n>>> Another non-JSON line\nerror test2\n",
+		},
+		{
+			name:            "compact non-JSON at start and end",
+			input:           `This is not JSON` + "\n" + `{"level":"info","message":"test1"}` + "\n" + `trailing text`,
+			handleNonJSON:   true,
+			noColors:        true,
+			compact:         true,
+			expectedSuccess: true,
+			expectedOutput:  ">>> This is not JSON\ninfo test1\n>>> trailing text\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := []FormatterOption{}
+			if tt.noColors {
+				options = append(options, WithNoColors(true))
+			}
+			if tt.compact {
+				options = append(options, WithCompactNonJSON(true))
+			}
+
+			formatter, err := NewTemplateFormatter("{{.level}} {{.message}}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			r := strings.NewReader(tt.input)
+			var buf bytes.Buffer
+
+			err = formatter.ProcessStream(r, &buf, formatter, nil, tt.handleNonJSON)
+
+			// Check if the error result matches expectations
+			if tt.expectedSuccess && err != nil {
+				t.Fatalf("ProcessStream failed but expected success: %v", err)
+			} else if !tt.expectedSuccess && err == nil {
+				t.Fatalf("ProcessStream succeeded but expected failure")
+			}
+
+			// Only check output if we expected success
+			if tt.expectedSuccess {
+				if buf.String() != tt.expectedOutput {
+					t.Errorf("Expected:\n%s\n\nGot:\n%s", tt.expectedOutput, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithSingleLine(t *testing.T) {
+	input := `{"level":"info","message":"ready","ctx":{"port":8080,"host":"localhost"}}` + "\n" +
+		`{"level":"error","message":"failed"}` + "\n"
+
+	tests := []struct {
+		name      string
+		format    string
+		separator string
+		noColors  bool
+		expected  string
+	}{
+		{
+			name:      "table output",
+			format:    "{level} {message}\n{{with .ctx}}{{table 6 .}}{{end}}",
+			separator: " | ",
+			noColors:  true,
+			expected:  "info ready |   host  localhost |   port  8080\nerror failed\n",
+		},
+		{
+			name:      "blank lines and trailing newlines are dropped",
+			format:    "{level}\n\n  \r\n{message}\n",
+			separator: "; ",
+			noColors:  true,
+			expected:  "info; ready\nerror; failed\n",
+		},
+		{
+			name:      "colors are kept",
+			format:    "{{.level | color \"red\"}}\n{{.message | bold}}",
+			separator: " | ",
+			expected:  "\033[31minfo\033[0m | \033[1mready\033[0m\n\033[31merror\033[0m | \033[1mfailed\033[0m\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format, WithNoColors(tt.noColors), WithSingleLine(tt.separator))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestComparisonFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "eq function with equal integers",
+			template: "{{if eq .value 10}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "equal",
+		},
+		{
+			name:     "eq function with unequal integers",
+			template: "{{if eq .value 20}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not equal",
+		},
+		{
+			name:     "ne function with equal integers",
+			template: "{{if ne .value 10}}not equal{{else}}equal{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "equal",
+		},
+		{
+			name:     "ne function with unequal integers",
+			template: "{{if ne .value 20}}not equal{{else}}equal{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not equal",
+		},
+		{
+			name:     "gt function with greater value",
+			template: "{{if gt .value 5}}greater{{else}}not greater{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "greater",
+		},
+		{
+			name:     "gt function with equal value",
+			template: "{{if gt .value 10}}greater{{else}}not greater{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not greater",
+		},
+		{
+			name:     "gt function with lesser value",
+			template: "{{if gt .value 15}}greater{{else}}not greater{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not greater",
+		},
+		{
+			name:     "lt function with greater value",
+			template: "{{if lt .value 15}}less{{else}}not less{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "less",
+		},
+		{
+			name:     "lt function with equal value",
+			template: "{{if lt .value 10}}less{{else}}not less{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not less",
+		},
+		{
+			name:     "lt function with lesser value",
+			template: "{{if lt .value 5}}less{{else}}not less{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not less",
+		},
+		{
+			name:     "gte function with equal value",
+			template: "{{if gte .value 10}}at least{{else}}less{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "at least",
+		},
+		{
+			name:     "gte function with lesser value",
+			template: "{{if gte .value 10}}at least{{else}}less{{end}}",
+			data:     map[string]interface{}{"value": 9.5},
+			expected: "less",
+		},
+		{
+			name:     "gte function with numeric string at the boundary",
+			template: "{{if gte .status 400}}failed{{else}}ok{{end}}",
+			data:     map[string]interface{}{"status": "400"},
+			expected: "failed",
+		},
+		{
+			name:     "lte function with equal value",
+			template: "{{if lte .value 10}}at most{{else}}more{{end}}",
+			data:     map[string]interface{}{"value": 10.0},
+			expected: "at most",
+		},
+		{
+			name:     "lte function with greater value",
+			template: "{{if lte .value 10}}at most{{else}}more{{end}}",
+			data:     map[string]interface{}{"value": 11},
+			expected: "more",
+		},
+		{
+			name:     "lte function with equal strings",
+			template: "{{if lte .version \"v1.2\"}}at most{{else}}more{{end}}",
+			data:     map[string]interface{}{"version": "v1.2"},
+			expected: "at most",
+		},
+		{
+			name:     "eq function with strings",
+			template: "{{if eq .message \"test\"}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"message": "test"},
+			expected: "equal",
+		},
+		{
+			name:     "eq function with nil values",
+			template: "{{if eq .value nil}}equal to nil{{else}}not nil{{end}}",
+			data:     map[string]interface{}{"value": nil},
+			expected: "equal to nil",
+		},
+		{
+			name:     "eq function with mixed number formats",
+			template: "{{if eq .value \"10\"}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "equal",
+		},
+		{
+			name:     "after function with RFC3339 strings",
+			template: "{{if after .timestamp .deadline}}late{{else}}on time{{end}}",
+			data:     map[string]interface{}{"timestamp": "2024-03-10T15:04:05Z", "deadline": "2024-03-10T15:00:00Z"},
+			expected: "late",
+		},
+		{
+			name:     "after function across time zones",
+			template: "{{if after .timestamp .deadline}}late{{else}}on time{{end}}",
+			data:     map[string]interface{}{"timestamp": "2024-03-10T16:04:05+02:00", "deadline": "2024-03-10T15:00:00Z"},
+			expected: "on time",
+		},
+		{
+			name:     "before function with epoch seconds and RFC3339",
+			template: "{{if before .timestamp .deadline}}on time{{else}}late{{end}}",
+			data:     map[string]interface{}{"timestamp": float64(1710082800), "deadline": "2024-03-10T15:04:05Z"},
+			expected: "on time",
+		},
+		{
+			name:     "before function with epoch milliseconds and RFC3339",
+			template: "{{if before .deadline .timestamp}}late{{else}}on time{{end}}",
+			data:     map[string]interface{}{"timestamp": float64(1710083045123), "deadline": "2024-03-10T15:04:05Z"},
+			expected: "late",
+		},
+		{
+			name:     "before function with equal times",
+			template: "{{if before .a .b}}before{{else}}not before{{end}}",
+			data:     map[string]interface{}{"a": "2024-03-10T15:04:05Z", "b": float64(1710083045)},
+			expected: "not before",
+		},
+		{
+			name:     "after function falls back to string comparison",
+			template: "{{if after .a .b}}after{{else}}not after{{end}}",
+			data:     map[string]interface{}{"a": "tomorrow", "b": "today"},
+			expected: "after",
+		},
+		{
+			name:     "between function with value inside range",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": 250},
+			expected: "between",
+		},
+		{
+			name:     "between function includes low bound",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": 100},
+			expected: "between",
+		},
+		{
+			name:     "between function includes high bound",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": 500.0},
+			expected: "between",
+		},
+		{
+			name:     "between function with value below range",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": 99.9},
+			expected: "not between",
+		},
+		{
+			name:     "between function with value above range",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": 501},
+			expected: "not between",
+		},
+		{
+			name:     "between function with numeric string",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": "300"},
+			expected: "between",
+		},
+		{
+			name:     "between function with non-numeric value",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{"value": "slow"},
+			expected: "not between",
+		},
+		{
+			name:     "between function with missing value",
+			template: "{{if between .value 100 500}}between{{else}}not between{{end}}",
+			data:     map[string]interface{}{},
+			expected: "not between",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMembershipFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "in with number in set",
+			template: "{{if in .status 500 502 503 504}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"status": float64(503)},
+			expected: "yes",
+		},
+		{
+			name:     "in with number not in set",
+			template: "{{if in .status 500 502 503 504}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"status": float64(200)},
+			expected: "no",
+		},
+		{
+			name:     "in with numeric string field",
+			template: "{{if in .status 500 502}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"status": "502"},
+			expected: "yes",
+		},
+		{
+			name:     "in with mixed set members",
+			template: "{{if in .code \"E1\" 42 \"E3\"}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"code": float64(42)},
+			expected: "yes",
+		},
+		{
+			name:     "in with string members",
+			template: "{{if in .level \"error\" \"fatal\"}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"level": "fatal"},
+			expected: "yes",
+		},
+		{
+			name:     "in with empty set",
+			template: "{{if in .level}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{"level": "info"},
+			expected: "no",
+		},
+		{
+			name:     "in with nil value",
+			template: "{{if in .missing nil \"x\"}}yes{{else}}no{{end}}",
+			data:     map[string]interface{}{},
+			expected: "yes",
+		},
+		{
+			name:     "notIn with value not in set",
+			template: "{{if notIn .level \"debug\" \"trace\"}}shown{{else}}hidden{{end}}",
+			data:     map[string]interface{}{"level": "info"},
+			expected: "shown",
+		},
+		{
+			name:     "notIn with numeric string in set",
+			template: "{{if notIn .status 200 \"204\"}}error{{else}}ok{{end}}",
+			data:     map[string]interface{}{"status": float64(204)},
+			expected: "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookupFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "matching key",
+			template: `{{lookup .status 200 "OK" 404 "Not Found" "???"}}`,
+			data:     map[string]interface{}{"status": float64(404)},
+			expected: "Not Found",
+		},
+		{
+			name:     "default",
+			template: `{{lookup .status 200 "OK" 404 "Not Found" "???"}}`,
+			data:     map[string]interface{}{"status": float64(500)},
+			expected: "???",
+		},
+		{
+			name:     "no match without default returns value",
+			template: `{{lookup .status 200 "OK" 404 "Not Found"}}`,
+			data:     map[string]interface{}{"status": float64(500)},
+			expected: "500",
+		},
+		{
+			name:     "numeric string key",
+			template: `{{lookup .status 200 "OK"}}`,
+			data:     map[string]interface{}{"status": "200"},
+			expected: "OK",
+		},
+		{
+			name:     "string keys",
+			template: `{{lookup .method "GET" "read" "POST" "create" "DELETE" "remove"}}`,
+			data:     map[string]interface{}{"method": "POST"},
+			expected: "create",
+		},
+		{
+			name:     "only a default",
+			template: `{{lookup .method "other"}}`,
+			data:     map[string]interface{}{"method": "PUT"},
+			expected: "other",
+		},
+		{
+			name:     "missing field matches nil key",
+			template: `{{lookup .method nil "none" "some"}}`,
+			data:     map[string]interface{}{},
+			expected: "none",
+		},
+		{
+			name:     "non-string values",
+			template: `{{lookup .level "error" 3 "warn" 2 0 | printf "%d"}}`,
+			data:     map[string]interface{}{"level": "warn"},
+			expected: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStrictEquality(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		loose    string
+		strict   string
+	}{
+		{
+			name:     "leading zeros",
+			template: "{{if eq .value 7}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": "007"},
+			loose:    "equal",
+			strict:   "not equal",
+		},
+		{
+			name:     "exponent notation",
+			template: "{{if eq .value 1000}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": "1e3"},
+			loose:    "equal",
+			strict:   "not equal",
+		},
+		{
+			name:     "numeric string and number",
+			template: "{{if eq .value \"10\"}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": float64(10)},
+			loose:    "equal",
+			strict:   "not equal",
+		},
+		{
+			name:     "version-like strings",
+			template: "{{if eq .version \"1.10\"}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"version": "1.1"},
+			loose:    "equal",
+			strict:   "not equal",
+		},
+		{
+			name:     "JSON float and template int",
+			template: "{{if eq .status 200}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"status": float64(200)},
+			loose:    "equal",
+			strict:   "equal",
+		},
+		{
+			name:     "identical strings",
+			template: "{{if eq .level \"error\"}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"level": "error"},
+			loose:    "equal",
+			strict:   "equal",
+		},
+		{
+			name:     "bool and string",
+			template: "{{if ne .ok \"true\"}}different{{else}}same{{end}}",
+			data:     map[string]interface{}{"ok": true},
+			loose:    "same",
+			strict:   "different",
+		},
+		{
+			name:     "nil values",
+			template: "{{if eq .value nil}}equal{{else}}not equal{{end}}",
+			data:     map[string]interface{}{"value": nil},
+			loose:    "equal",
+			strict:   "equal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				formatter, err := NewTemplateFormatterWithOptions(tt.template, DefaultPreProcessTemplateOptions(), WithStrictEquality(strict))
+				if err != nil {
+					t.Fatalf("Failed to create formatter: %v", err)
+				}
+
+				result, err := formatter.Format(tt.data)
+				if err != nil {
+					t.Fatalf("Formatter.Format() error = %v", err)
+				}
+
+				expected := tt.loose
+				if strict {
+					expected = tt.strict
+				}
+				if result != expected {
+					t.Errorf("Format result with strict=%t = %q, want %q", strict, result, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvFunction(t *testing.T) {
+	t.Setenv("LOGISTA_TEST_HOST", "web-1")
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "set variable",
+			template: `[{{env "LOGISTA_TEST_HOST"}}] {{.message}}`,
+			expected: "[web-1] ready",
+		},
+		{
+			name:     "unset variable",
+			template: `[{{env "LOGISTA_TEST_UNSET"}}] {{.message}}`,
+			expected: "[] ready",
+		},
+		{
+			name:     "piped to another function",
+			template: `{{env "LOGISTA_TEST_HOST" | pad 7}}|{{.message}}`,
+			expected: "web-1  |ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(map[string]interface{}{"message": "ready"})
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLevelFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		noColors bool
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "pads and uppercases",
+			template: "[{{.level | level 5}}]",
+			noColors: true,
+			data:     map[string]interface{}{"level": "info"},
+			expected: "[INFO ]",
+		},
+		{
+			name:     "cuts long levels",
+			template: "[{{.level | level 4}}]",
+			noColors: true,
+			data:     map[string]interface{}{"level": "warning"},
+			expected: "[WARN]",
+		},
+		{
+			name:     "negative width right-aligns",
+			template: "[{{.level | level -5}}]",
+			noColors: true,
+			data:     map[string]interface{}{"level": "info"},
+			expected: "[ INFO]",
+		},
+		{
+			name:     "missing level",
+			template: "[{{.level | level 5}}]",
+			noColors: true,
+			data:     map[string]interface{}{},
+			expected: "[     ]",
+		},
+		{
+			name:     "colored by level",
+			template: "[{{.level | level 5}}]",
+			data:     map[string]interface{}{"level": "error"},
+			expected: "[\033[31mERROR\033[0m]",
+		},
+		{
+			name:     "colored padding keeps width",
+			template: "[{{.level | level 5}}]",
+			data:     map[string]interface{}{"level": "warn"},
+			expected: "[\033[33mWARN \033[0m]",
+		},
+		{
+			name:     "simple syntax",
+			template: "[{level | level 5}] {message}",
+			noColors: true,
+			data:     map[string]interface{}{"level": "debug", "message": "ready"},
+			expected: "[DEBUG] ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatterWithOptions(tt.template, DefaultPreProcessTemplateOptions(), WithNoColors(tt.noColors))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBadgeByLevelFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		noColors bool
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "error",
+			template: "{{.level | badgeByLevel}}",
+			data:     map[string]interface{}{"level": "error"},
+			expected: "\033[41;97m ERROR \033[0m",
+		},
+		{
+			name:     "warning severity",
+			template: "{{.severity | badgeByLevel 5}}",
+			data:     map[string]interface{}{"severity": "WARNING"},
+			expected: "\033[43;30m WARNI \033[0m",
+		},
+		{
+			name:     "syslog severity",
+			template: "{{.severity | badgeByLevel -5}}",
+			data:     map[string]interface{}{"severity": 6},
+			expected: "\033[42;30m     6 \033[0m",
+		},
+		{
+			name:     "unknown level",
+			template: "{{.level | badgeByLevel}}",
+			data:     map[string]interface{}{"level": "chatty"},
+			expected: "\033[47;30m CHATTY \033[0m",
+		},
+		{
+			name:     "without colors",
+			template: "{level | badgeByLevel 5} {message}",
+			noColors: true,
+			data:     map[string]interface{}{"level": "info", "message": "ready"},
+			expected: "[INFO ] ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(tt.noColors), WithColorDepth(ColorDepthTrueColor))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIssetFunction(t *testing.T) {
+	type TestStruct struct {
+		Name  string
+		Email string
+		Age   int
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "isset function with existing field in map",
+			template: "{{if isset \"value\" .}}exists{{else}}not exists{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "exists",
+		},
+		{
+			name:     "isset function with non-existing field in map",
+			template: "{{if isset \"missing\" .}}exists{{else}}not exists{{end}}",
+			data:     map[string]interface{}{"value": 10},
+			expected: "not exists",
+		},
+		{
+			name:     "isset function with multiple fields",
+			template: "{{if isset \"name\" .}}Name exists{{else}}Name missing{{end}} and {{if isset \"age\" .}}Age exists{{else}}Age missing{{end}}",
+			data:     map[string]interface{}{"name": "Test", "email": "test@example.com"},
+			expected: "Name exists and Age missing",
+		},
+		{
+			name:     "isset function with nil value",
+			template: "{{if isset \"value\" .}}exists{{else}}not exists{{end}}",
+			data:     map[string]interface{}{"value": nil},
+			expected: "exists", // The field exists, even though its value is nil
+		},
+		{
+			name:     "isset function with nil data",
+			template: "{{if isset \"value\" nil}}exists{{else}}not exists{{end}}",
+			data:     map[string]interface{}{},
+			expected: "not exists",
+		},
+		{
+			name:     "isset function with nested field",
+			template: "{{if isset \"user\" .}}user exists{{else}}user missing{{end}} and {{if isset \"name\" .user}}name exists{{else}}name missing{{end}}",
+			data:     map[string]interface{}{"user": map[string]interface{}{"name": "John"}},
+			expected: "user exists and name exists",
+		},
+		{
+			name:     "isset function with empty string as field name",
+			template: "{{if isset \"\" .}}empty key exists{{else}}empty key missing{{end}}",
+			data:     map[string]interface{}{"": "empty key value"},
+			expected: "empty key exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	// Test isset function directly with a struct (can't be tested via template easily)
+	t.Run("isset function with struct", func(t *testing.T) {
+		formatter := &TemplateFormatter{}
+		testStruct := TestStruct{Name: "Test", Email: "test@example.com", Age: 30}
+
+		if !formatter.issetFunc("Name", testStruct) {
+			t.Error("Expected Name field to exist in struct")
+		}
+
+		if !formatter.issetFunc("Email", testStruct) {
+			t.Error("Expected Email field to exist in struct")
+		}
+
+		if !formatter.issetFunc("Age", testStruct) {
+			t.Error("Expected Age field to exist in struct")
+		}
+
+		if formatter.issetFunc("Address", testStruct) {
+			t.Error("Expected Address field to not exist in struct")
+		}
+
+		// Test with pointer to struct
+		if !formatter.issetFunc("Name", &testStruct) {
+			t.Error("Expected Name field to exist in struct pointer")
+		}
+	})
+}
+
+func TestTruthyAndEmptyFunctions(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{name: "nil", value: nil, want: false},
+		{name: "false", value: false, want: false},
+		{name: "true", value: true, want: true},
+		{name: "zero float", value: float64(0), want: false},
+		{name: "zero int", value: 0, want: false},
+		{name: "non-zero number", value: 0.5, want: true},
+		{name: "zero json.Number", value: json.Number("0"), want: false},
+		{name: "non-zero json.Number", value: json.Number("12"), want: true},
+		{name: "empty string", value: "", want: false},
+		{name: "string", value: "boom", want: true},
+		{name: "string zero", value: "0", want: true},
+		{name: "string false", value: "false", want: true},
+		{name: "empty array", value: []interface{}{}, want: false},
+		{name: "array", value: []interface{}{nil}, want: true},
+		{name: "empty map", value: map[string]interface{}{}, want: false},
+		{name: "map", value: map[string]interface{}{"code": 1}, want: true},
+	}
+
+	formatter := &TemplateFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatter.truthyFunc(tt.value); got != tt.want {
+				t.Errorf("truthy(%#v) = %v, want %v", tt.value, got, tt.want)
+			}
+			if got := formatter.emptyFunc(tt.value); got == tt.want {
+				t.Errorf("empty(%#v) = %v, want %v", tt.value, got, !tt.want)
+			}
+		})
+	}
+
+	t.Run("in templates", func(t *testing.T) {
+		f, err := NewTemplateFormatter(
+			"{{if truthy .error}}error: {{.error}}{{end}}|{{if empty .tags}}untagged{{end}}|{{if empty .missing}}missing{{end}}",
+			WithNoColors(true))
+		if err != nil {
+			t.Fatalf("Failed to create formatter: %v", err)
+		}
+
+		result, err := f.Format(map[string]interface{}{"error": "", "tags": []interface{}{}})
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if result != "|untagged|missing" {
+			t.Errorf("Format result = %q, want %q", result, "|untagged|missing")
+		}
+
+		result, err = f.Format(map[string]interface{}{"error": "timeout", "tags": []interface{}{"a"}})
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if result != "error: timeout||missing" {
+			t.Errorf("Format result = %q, want %q", result, "error: timeout||missing")
+		}
+	})
+}
+
+func TestFilterFunction(t *testing.T) {
+	data := map[string]interface{}{
+		"level":                "info",
+		"msg":                  "ok",
+		"grpc.service":         "users.UserService",
+		"grpc.unary.duration":  float64(12),
+		"grpc.stream.duration": float64(40),
+		"grpc.stream.count":    float64(3),
+		"grpc_code":            "OK",
+		"user.password":        "hunter2",
+		"db.password":          "secret",
+		"password":             "top-secret",
+		"weird[key":            "x",
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "exact names",
+			patterns: []string{"level", "msg"},
+			expected: []string{"db.password", "grpc.service", "grpc.stream.count", "grpc.stream.duration", "grpc.unary.duration", "grpc_code", "password", "user.password", "weird[key"},
+		},
+		{
+			name:     "prefix",
+			patterns: []string{"grpc.*"},
+			expected: []string{"db.password", "grpc_code", "level", "msg", "password", "user.password", "weird[key"},
+		},
+		{
+			name:     "prefix without separator",
+			patterns: []string{"grpc*", "weird*", "*.password", "password"},
+			expected: []string{"level", "msg"},
+		},
+		{
+			name:     "glob in the middle",
+			patterns: []string{"grpc.*.duration"},
+			expected: []string{"db.password", "grpc.service", "grpc.stream.count", "grpc_code", "level", "msg", "password", "user.password", "weird[key"},
+		},
+		{
+			name:     "glob suffix",
+			patterns: []string{"*.password"},
+			expected: []string{"grpc.service", "grpc.stream.count", "grpc.stream.duration", "grpc.unary.duration", "grpc_code", "level", "msg", "password", "weird[key"},
+		},
+		{
+			name:     "character classes",
+			patterns: []string{"[dl]*", "?sg"},
+			expected: []string{"grpc.service", "grpc.stream.count", "grpc.stream.duration", "grpc.unary.duration", "grpc_code", "password", "user.password", "weird[key"},
+		},
+		{
+			name:     "invalid glob matches exactly",
+			patterns: []string{"weird[key"},
+			expected: []string{"db.password", "grpc.service", "grpc.stream.count", "grpc.stream.duration", "grpc.unary.duration", "grpc_code", "level", "msg", "password", "user.password"},
+		},
+	}
+
+	formatter := &TemplateFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatter.filterFunc(data, tt.patterns...)
+
+			keys := make([]string, 0, len(result))
+			for key := range result {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("filter(%q) = %v, want %v", tt.patterns, keys, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeFunction(t *testing.T) {
+	formatter := &TemplateFormatter{}
+
+	tests := []struct {
+		name     string
+		maps     []map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "no maps",
+			expected: map[string]interface{}{},
+		},
+		{
+			name: "disjoint keys",
+			maps: []map[string]interface{}{
+				{"user_id": "u1"},
+				{"action": "login"},
+			},
+			expected: map[string]interface{}{"user_id": "u1", "action": "login"},
+		},
+		{
+			name: "later maps win",
+			maps: []map[string]interface{}{
+				{"status": "ok", "a": 1},
+				{"status": "failed"},
+				{"a": nil},
+			},
+			expected: map[string]interface{}{"status": "failed", "a": nil},
+		},
+		{
+			name: "nested maps are replaced",
+			maps: []map[string]interface{}{
+				{"ctx": map[string]interface{}{"a": 1}},
+				{"ctx": map[string]interface{}{"b": 2}},
+			},
+			expected: map[string]interface{}{"ctx": map[string]interface{}{"b": 2}},
+		},
+		{
+			name:     "nil maps",
+			maps:     []map[string]interface{}{nil, {"a": 1}, nil},
+			expected: map[string]interface{}{"a": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatter.mergeFunc(tt.maps...)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("merge = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	// Merging doesn't modify its inputs
+	first := map[string]interface{}{"a": 1}
+	formatter.mergeFunc(first, map[string]interface{}{"a": 2})
+	if first["a"] != 1 {
+		t.Errorf("Expected merge not to modify its arguments, got %v", first)
+	}
+
+	// Missing fields are passed as nil maps
+	tf, err := NewTemplateFormatter(`{{table 6 (merge .missing (filter . "msg" "request") .request)}}`, WithNoColors(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	result, err := tf.Format(map[string]interface{}{
+		"msg":     "ok",
+		"user":    "u1",
+		"request": map[string]interface{}{"path": "/"},
+	})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if expected := "  path  /\n  user  u1"; result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRenameKeysFunction(t *testing.T) {
+	data := map[string]interface{}{
+		"grpc.method": "GetUser",
+		"http.status": float64(200),
+		"msg":         "ok",
+	}
+
+	tests := []struct {
+		name     string
+		pairs    []string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "no pairs",
+			expected: data,
+		},
+		{
+			name:     "present keys",
+			pairs:    []string{"grpc.method", "Method", "http.status", "Status"},
+			expected: map[string]interface{}{"Method": "GetUser", "Status": float64(200), "msg": "ok"},
+		},
+		{
+			name:     "absent keys",
+			pairs:    []string{"user_id", "User", "grpc.method", "Method"},
+			expected: map[string]interface{}{"Method": "GetUser", "http.status": float64(200), "msg": "ok"},
+		},
+		{
+			name:     "replaces an existing key",
+			pairs:    []string{"grpc.method", "msg"},
+			expected: map[string]interface{}{"msg": "GetUser", "http.status": float64(200)},
+		},
+		{
+			name:     "swap",
+			pairs:    []string{"grpc.method", "msg", "msg", "grpc.method"},
+			expected: map[string]interface{}{"msg": "GetUser", "grpc.method": "ok", "http.status": float64(200)},
+		},
+		{
+			name:     "unpaired key ignored",
+			pairs:    []string{"msg", "Message", "grpc.method"},
+			expected: map[string]interface{}{"Message": "ok", "grpc.method": "GetUser", "http.status": float64(200)},
+		},
+	}
+
+	formatter := &TemplateFormatter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatter.renameKeysFunc(data, tt.pairs...)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("renameKeys(%q) = %v, want %v", tt.pairs, result, tt.expected)
+			}
+		})
+	}
+
+	if _, ok := data["Method"]; ok {
+		t.Error("Expected renameKeys not to modify its argument")
+	}
+
+	tf, err := NewTemplateFormatter(`{{table 8 (renameKeys . "grpc.method" "Method" "http.status" "Status")}}`, WithNoColors(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	result, err := tf.Format(data)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if expected := "  Method  GetUser\n  Status  200\n  msg     ok"; result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestArgsFunctions(t *testing.T) {
+	data := map[string]interface{}{
+		"msg":    "ok",
+		"Status": float64(200),
+		"level":  "info",
+		"user":   map[string]interface{}{"id": "u1"},
+	}
+
+	formatter := &TemplateFormatter{}
+	expected := []interface{}{float64(200), "info", "ok", map[string]interface{}{"id": "u1"}}
+	if result := formatter.argsFunc(data); !reflect.DeepEqual(result, expected) {
+		t.Errorf("args = %v, want %v", result, expected)
+	}
+	if result := formatter.argsFunc(map[string]interface{}{}); len(result) != 0 {
+		t.Errorf("Expected no args for an empty record, got %v", result)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "index",
+			template: `{{index (args .) 1}}`,
+			expected: "info",
+		},
+		{
+			name:     "sprintf",
+			template: `{{sprintf "%v %s %-4s|" (filter . "user")}}`,
+			expected: "200 info ok  |",
+		},
+		{
+			name:     "missing arguments",
+			template: `{{sprintf "%s" (filter . "user" "msg" "Status" "level")}}`,
+			expected: "%!s(MISSING)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(true))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatRoundedDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{500 * time.Nanosecond, "500ns"},
+		{250 * time.Millisecond, "250.00ms"},
+		{9*time.Second + 994*time.Millisecond, "9.99s"},
+		{10 * time.Second, "10.0s"},
+		{45*time.Second + 123*time.Millisecond, "45.1s"},
+		{59*time.Second + 940*time.Millisecond, "59.9s"},
+		{59*time.Second + 950*time.Millisecond, "1m"},
+		{time.Minute, "1m"},
+		{90*time.Second + 499*time.Millisecond, "1m30s"},
+		{90*time.Second + 500*time.Millisecond, "1m31s"},
+		{59*time.Minute + 59*time.Second + 600*time.Millisecond, "1h"},
+		{time.Hour + 30*time.Minute + 45*time.Second + 123*time.Millisecond, "1h31m"},
+		{time.Hour + 30*time.Minute + 29*time.Second, "1h30m"},
+		{23*time.Hour + 59*time.Minute + 30*time.Second, "1d"},
+		{24*time.Hour + 29*time.Minute, "1d"},
+		{52*time.Hour + 10*time.Minute, "2d4h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := formatRoundedDuration(tt.duration); result != tt.expected {
+				t.Errorf("formatRoundedDuration(%v) = %q, want %q", tt.duration, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDurationRounding(t *testing.T) {
+	data := map[string]interface{}{"took": float64(5445123)}
+
+	precise, err := NewTemplateFormatter("{took | duration}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	if result, _ := precise.Format(data); result != "1h30m45.123s" {
+		t.Errorf("Expected the precise duration by default, got %q", result)
+	}
+
+	rounded, err := NewTemplateFormatter("{took | duration}", WithDurationRounding(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	if result, _ := rounded.Format(data); result != "1h31m" {
+		t.Errorf("Expected a rounded duration, got %q", result)
+	}
+	if result := rounded.prettyFunc(90 * time.Second); result != "1m30s" {
+		t.Errorf("Expected pretty to round durations, got %q", result)
+	}
+}
+
+func TestTableFunction(t *testing.T) {
+	// Hides false booleans and unknown user IDs, but keeps empty strings
+	omitFalse := WithTableOmitFunc(func(key string, val interface{}) bool {
+		return val == false || (key == "user_id" && val == "unknown")
+	})
+
+	tests := []struct {
+		name     string
+		template string
+		options  []FormatterOption
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "default padding",
+			template: "{{table .}}",
+			data:     map[string]interface{}{"b": 2, "a": "one"},
+			expected: "  a                  one\n  b                  2",
+		},
+		{
+			name:     "custom padding",
+			template: "{{table 4 .}}",
+			data:     map[string]interface{}{"b": 2, "a": "one"},
+			expected: "  a   one\n  b   2",
+		},
+		{
+			name:     "negative padding does not right-align keys",
+			template: `{{table -3 "-" .}}`,
+			data:     map[string]interface{}{"kk1": 1},
+			expected: "  kk11",
+		},
+		{
+			name:     "keys as wide as the padding",
+			template: "{{table 4 .}}",
+			data:     map[string]interface{}{"abcd": 1, "abcdef": 2},
+			expected: "  abcd1\n  abcdef2",
+		},
+		{
+			name:     "empty fields omitted by default",
+			template: "{{table 4 .}}",
+			data:     map[string]interface{}{"a": "", "b": nil, "c": "three"},
+			expected: "  c   three",
+		},
+		{
+			name:     "empty fields rendered with placeholder",
+			template: `{{table 4 "-" .}}`,
+			data:     map[string]interface{}{"a": "", "b": nil, "c": "three"},
+			expected: "  a   -\n  b   -\n  c   three",
+		},
+		{
+			name:     "empty map",
+			template: `{{table 4 "-" .}}`,
+			data:     map[string]interface{}{},
+			expected: "",
+		},
+		{
+			name:     "custom omit predicate",
+			template: "{{table 8 .}}",
+			options:  []FormatterOption{omitFalse},
+			data:     map[string]interface{}{"a": "", "cached": false, "ok": true, "user_id": "unknown"},
+			expected: "  a       <empty>\n  ok      true",
+		},
+		{
+			name:     "custom omit predicate with placeholder",
+			template: `{{table 8 "-" .}}`,
+			options:  []FormatterOption{omitFalse},
+			data:     map[string]interface{}{"cached": false, "user_id": "u1"},
+			expected: "  cached  -\n  user_id u1",
+		},
+		{
+			name:     "custom omit predicate in tree",
+			template: "{{tree .}}",
+			options:  []FormatterOption{omitFalse},
+			data:     map[string]interface{}{"req": map[string]interface{}{"cached": false, "user_id": "unknown", "path": "/"}},
+			expected: "  req\n    path             /",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter(tt.template, options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestListFunction(t *testing.T) {
+	failures := []interface{}{
+		map[string]interface{}{"code": "E1", "message": "disk full"},
+		map[string]interface{}{"code": "E2", "message": "retrying", "attempt": float64(3)},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		noColors bool
+		expected string
+	}{
+		{
+			name:     "array of objects",
+			template: "{{list .errors}}",
+			data:     map[string]interface{}{"errors": failures},
+			noColors: true,
+			expected: "1.\n" +
+				"  code               E1\n" +
+				"  message            disk full\n" +
+				"2.\n" +
+				"  attempt            3\n" +
+				"  code               E2\n" +
+				"  message            retrying",
+		},
+		{
+			name:     "custom padding",
+			template: "{{list 9 .errors}}",
+			data:     map[string]interface{}{"errors": failures[:1]},
+			noColors: true,
+			expected: "1.\n  code     E1\n  message  disk full",
+		},
+		{
+			name:     "nested values are inline",
+			template: "{{list .items}}",
+			data: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"ctx": map[string]interface{}{"id": float64(1)}},
+			}},
+			noColors: true,
+			expected: "1.\n  ctx                {id=1}",
+		},
+		{
+			name:     "empty object",
+			template: "{{list .items}}",
+			data:     map[string]interface{}{"items": []interface{}{map[string]interface{}{}}},
+			noColors: true,
+			expected: "1. {}",
+		},
+		{
+			name:     "mixed array falls back to inline",
+			template: "{{list .items}}",
+			data:     map[string]interface{}{"items": []interface{}{map[string]interface{}{"a": 1}, "b"}},
+			noColors: true,
+			expected: "[{a=1}, b]",
+		},
+		{
+			name:     "non-array value",
+			template: "{{list .message}}",
+			data:     map[string]interface{}{"message": "hello"},
+			noColors: true,
+			expected: "hello",
+		},
+		{
+			name:     "empty array",
+			template: "{{list .items}}",
+			data:     map[string]interface{}{"items": []interface{}{}},
+			noColors: true,
+			expected: "[]",
+		},
+		{
+			name:     "with colors",
+			template: "{{list .errors}}",
+			data:     map[string]interface{}{"errors": failures[:1]},
+			expected: "\033[2m1.\033[0m\n" +
+				"  \033[2mcode               \033[0mE1\n" +
+				"  \033[2mmessage            \033[0mdisk full",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatterWithOptions(tt.template, DefaultPreProcessTemplateOptions(), WithNoColors(tt.noColors))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Formatter.Format() error = %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTreeFunction(t *testing.T) {
+	data := map[string]interface{}{
+		"level": "info",
+		"context": map[string]interface{}{
+			"version": "1.0.0",
+			"trace":   "",
+			"user": map[string]interface{}{
+				"id": json.Number("123"),
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "default depth",
+			template: "{{tree .}}",
+			expected: "  context\n" +
+				"    user\n" +
+				"      id             123\n" +
+				"    version          1.0.0\n" +
+				"  level              info",
+		},
+		{
+			name:     "depth limit renders deeper maps inline",
+			template: "{{tree 1 .}}",
+			expected: "  context\n" +
+				"    user             {id=123}\n" +
+				"    version          1.0.0\n" +
+				"  level              info",
+		},
+		{
+			name:     "zero depth matches table",
+			template: "{{tree 0 .context}}",
+			expected: "  user               {id=123}\n" +
+				"  version            1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, WithNoColors(true))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result:\n%s\n\nwant:\n%s", result, tt.expected)
+			}
+		})
+	}
+
+	t.Run("rows deeper than the key padding are not right-aligned", func(t *testing.T) {
+		deep := map[string]interface{}{"k": "v"}
+		for range 11 {
+			deep = map[string]interface{}{"n": deep}
+		}
+
+		formatter := &TemplateFormatter{noColors: true}
+		result := formatter.treeFunc(12, deep)
+		lines := strings.Split(result, "\n")
+		expected := strings.Repeat(" ", 2+2*11) + "kv"
+		if last := lines[len(lines)-1]; last != expected {
+			t.Errorf("treeFunc deepest row = %q, want %q", last, expected)
+		}
+	})
+
+	t.Run("cycles are not followed", func(t *testing.T) {
+		cyclic := map[string]interface{}{"name": "root"}
+		cyclic["self"] = cyclic
+
+		formatter := &TemplateFormatter{noColors: true}
+		expected := "  name               root\n  self               <cycle>"
+		if result := formatter.treeFunc(cyclic); result != expected {
+			t.Errorf("treeFunc result:\n%s\n\nwant:\n%s", result, expected)
+		}
+	})
+}
+
+func TestPadString(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		length   int
+		left     bool
+		expected string
+	}{
+		{name: "pads on the right", value: "ab", length: 4, expected: "ab  "},
+		{name: "pads on the left", value: "ab", length: 4, left: true, expected: "  ab"},
+		{name: "negative width adds no padding", value: "ab", length: -4, expected: "ab"},
+		{name: "negative width keeps left alignment", value: "ab", length: -4, left: true, expected: "ab"},
+		{name: "nil with negative width", value: nil, length: -4, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := padString(tt.value, tt.length, tt.left); result != tt.expected {
+				t.Errorf("padString(%v, %d, %v) = %q, want %q", tt.value, tt.length, tt.left, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	data := map[string]interface{}{
+		"message": "the quick brown fox jumps",
+		"context": map[string]interface{}{"id": float64(1)},
+		"errors":  []interface{}{map[string]interface{}{"code": "E1"}},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:     "table with indent",
+			template: "{{table 4 .context}}",
+			options:  []FormatterOption{WithIndent(4)},
+			expected: "    id  1",
+		},
+		{
+			name:     "table without indent",
+			template: "{{table 4 .context}}",
+			options:  []FormatterOption{WithIndent(0)},
+			expected: "id  1",
+		},
+		{
+			name:     "nested tree rows keep their relative indent",
+			template: "{{tree .}}",
+			options:  []FormatterOption{WithIndent(1)},
+			expected: " context\n" +
+				"   id               1\n" +
+				" errors             [{code=E1}]\n" +
+				" message            the quick brown fox jumps",
+		},
+		{
+			name:     "list with indent",
+			template: "{{list 7 .errors}}",
+			options:  []FormatterOption{WithIndent(3)},
+			expected: "1.\n   code   E1",
+		},
+		{
+			name:     "negative indent is treated as zero",
+			template: "{{table 4 .context}}",
+			options:  []FormatterOption{WithIndent(-2)},
+			expected: "id  1",
+		},
+		{
+			name:     "indent function defaults to two spaces",
+			template: "{{.message | wrap 10 0 | indent}}",
+			expected: "  the quick\n  brown fox\n  jumps",
+		},
+		{
+			name:     "indent function uses the configured indent",
+			template: "{{.message | wrap 10 0 | indent}}",
+			options:  []FormatterOption{WithIndent(4)},
+			expected: "    the quick\n    brown fox\n    jumps",
+		},
+		{
+			name:     "indent function composes with wrap's indent",
+			template: "{{.message | wrap 10 2 | indent 3}}",
+			expected: "   the quick\n     brown\n     fox\n     jumps",
+		},
+		{
+			name:     "indent function with missing value",
+			template: "{{.missing | indent 2}}",
+			expected: "  <no value>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter(tt.template, options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Format result = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// closedPipeWriter fails every write as if the reader had gone away
+type closedPipeWriter struct{}
+
+func (closedPipeWriter) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: "|1", Err: syscall.EPIPE}
+}
+
+func TestProcessStreamWithClosedOutput(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	for _, w := range []io.Writer{closedPipeWriter{}, NewBufferedWriter(closedPipeWriter{}, 0)} {
+		err = formatter.ProcessStream(strings.NewReader(`{"level":"info"}`), w, formatter, nil, false)
+		if !errors.Is(err, ErrOutputClosed) {
+			t.Errorf("Expected ErrOutputClosed for %T, got %v", w, err)
+		}
+	}
+}
+
+func TestProcessStreamContext(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	t.Run("completes without cancellation", func(t *testing.T) {
+		var buf bytes.Buffer
+		input := `{"level":"info"}` + "\n" + `{"level":"warn"}`
+		err := formatter.ProcessStreamContext(context.Background(), strings.NewReader(input), &buf, formatter, nil, false)
+		if err != nil {
+			t.Fatalf("ProcessStreamContext failed: %v", err)
+		}
+		if buf.String() != "info\nwarn\n" {
+			t.Errorf("Expected 'info\\nwarn\\n', got %q", buf.String())
+		}
+	})
+
+	t.Run("already cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var buf bytes.Buffer
+		err := formatter.ProcessStreamContext(ctx, strings.NewReader(`{"level":"info"}`), &buf, formatter, nil, false)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Expected no output, got %q", buf.String())
+		}
+	})
+
+	t.Run("cancelled while blocked on input", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var buf syncBuffer
+		done := make(chan error, 1)
+		go func() {
+			done <- formatter.ProcessStreamContext(ctx, pr, &buf, formatter, nil, false)
+		}()
+
+		// Write one record, then leave the read blocked
+		if _, err := io.WriteString(pw, `{"level":"info"}`+"\n"); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for buf.String() != "info\n" && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		cancel()
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("ProcessStreamContext didn't return after cancellation")
+		}
+
+		if buf.String() != "info\n" {
+			t.Errorf("Expected 'info\\n', got %q", buf.String())
+		}
+	})
+}
+
+func TestShouldSkip(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		patterns []SkipPattern
+		expected bool
+	}{
+		{
+			name:     "top-level field",
+			data:     map[string]interface{}{"logger": "Uploader.download"},
+			patterns: []SkipPattern{{Field: "logger", Value: "Uploader"}},
+			expected: true,
+		},
+		{
+			name:     "substring match",
+			data:     map[string]interface{}{"status": float64(2000)},
+			patterns: []SkipPattern{{Field: "status", Value: "200"}},
+			expected: true,
+		},
+		{
+			name:     "exact match",
+			data:     map[string]interface{}{"status": float64(200)},
+			patterns: []SkipPattern{{Field: "status", Value: "200", Exact: true}},
+			expected: true,
+		},
+		{
+			name:     "exact pattern doesn't match substrings",
+			data:     map[string]interface{}{"status": float64(2000)},
+			patterns: []SkipPattern{{Field: "status", Value: "200", Exact: true}},
+			expected: false,
+		},
+		{
+			name: "nested field",
+			data: map[string]interface{}{
+				"context": map[string]interface{}{"user": map[string]interface{}{"role": "admin"}},
+			},
+			patterns: []SkipPattern{{Field: "context.user.role", Value: "admin"}},
+			expected: true,
+		},
+		{
+			name: "nested field with another value",
+			data: map[string]interface{}{
+				"context": map[string]interface{}{"user": map[string]interface{}{"role": "viewer"}},
+			},
+			patterns: []SkipPattern{{Field: "context.user.role", Value: "admin"}},
+			expected: false,
+		},
+		{
+			name:     "flat key containing dots",
+			data:     map[string]interface{}{"context.user.role": "admin"},
+			patterns: []SkipPattern{{Field: "context.user.role", Value: "admin"}},
+			expected: true,
+		},
+		{
+			name: "flat key is used when the path stops at a scalar",
+			data: map[string]interface{}{
+				"http":        "GET",
+				"http.status": float64(500),
+			},
+			patterns: []SkipPattern{{Field: "http.status", Value: "500"}},
+			expected: true,
+		},
+		{
+			name: "nested value is preferred over a flat key",
+			data: map[string]interface{}{
+				"http":        map[string]interface{}{"status": float64(200)},
+				"http.status": float64(500),
+			},
+			patterns: []SkipPattern{{Field: "http.status", Value: "500"}},
+			expected: false,
+		},
+		{
+			name:     "missing path",
+			data:     map[string]interface{}{"context": map[string]interface{}{}},
+			patterns: []SkipPattern{{Field: "context.user.role", Value: "admin"}},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldSkip(tt.data, tt.patterns); got != tt.expected {
+				t.Errorf("shouldSkip() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessSingle(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		options         []FormatterOption
+		expectedSuccess bool
+		expectedOutput  string
+	}{
+		{
+			name:            "single object",
+			input:           `{"level":"info","message":"test1"}`,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n",
+		},
+		{
+			name:            "multi-line object with trailing whitespace",
+			input:           "{\n  \"level\": \"info\",\n  \"message\": \"test1\"\n}\n\n",
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n",
+		},
+		{
+			name:            "byte order mark",
+			input:           "\uFEFF" + `{"level":"info","message":"test1"}`,
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n",
+		},
+		{
+			name:            "trailing object",
+			input:           `{"level":"info","message":"test1"}` + "\n" + `{"level":"error","message":"test2"}`,
+			expectedSuccess: false,
+		},
+		{
+			name:            "trailing garbage",
+			input:           `{"level":"info","message":"test1"} oops`,
+			expectedSuccess: false,
+		},
+		{
+			name:            "partial object",
+			input:           `{"level":"info","mess`,
+			expectedSuccess: false,
+		},
+		{
+			name:            "empty input",
+			input:           "",
+			expectedSuccess: false,
+		},
+		{
+			name:            "input parser",
+			input:           "level=info message=test1\n",
+			options:         []FormatterOption{WithInputParser(LogfmtParser{})},
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n",
+		},
+		{
+			name:            "empty input with input parser",
+			input:           "\n",
+			options:         []FormatterOption{WithInputParser(LogfmtParser{})},
+			expectedSuccess: false,
+		},
+		{
+			name:            "record delimiter",
+			input:           `{"level":"info","message":"test1"}` + "\x00\n\x00",
+			options:         []FormatterOption{WithRecordDelimiter("\x00")},
+			expectedSuccess: true,
+			expectedOutput:  "info test1\n",
+		},
+		{
+			name:            "record after the delimiter",
+			input:           `{"level":"info","message":"test1"}` + "\x00" + `{"level":"error","message":"test2"}`,
+			options:         []FormatterOption{WithRecordDelimiter("\x00")},
+			expectedSuccess: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter("{{.level}} {{.message}}", tt.options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = formatter.ProcessSingle(strings.NewReader(tt.input), &buf, formatter)
+
+			if tt.expectedSuccess && err != nil {
+				t.Fatalf("ProcessSingle failed but expected success: %v", err)
+			} else if !tt.expectedSuccess && err == nil {
+				t.Fatalf("ProcessSingle succeeded but expected failure")
+			}
+
+			if tt.expectedSuccess && buf.String() != tt.expectedOutput {
+				t.Errorf("Expected '%s', got '%s'", tt.expectedOutput, buf.String())
+			}
+		})
+	}
+}
+
+func TestWithFuncs(t *testing.T) {
+	decodeID := func(value interface{}) string {
+		return strings.TrimPrefix(fmt.Sprintf("%v", value), "usr_")
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		opts     []FormatterOption
+		expected string
+	}{
+		{
+			name:     "custom function",
+			format:   "{id | decodeID}",
+			opts:     []FormatterOption{WithFuncs(template.FuncMap{"decodeID": decodeID})},
+			expected: "1234",
+		},
+		{
+			name:   "custom function overrides built-in",
+			format: "{level | pad 10}|",
+			opts: []FormatterOption{WithFuncs(template.FuncMap{
+				"pad": func(_ int, value interface{}) string { return "padded" },
+			})},
+			expected: "padded|",
+		},
+		{
+			name:   "later registrations win",
+			format: "{id | decodeID}",
+			opts: []FormatterOption{
+				WithFuncs(template.FuncMap{"decodeID": decodeID}),
+				WithFuncs(template.FuncMap{"decodeID": strings.ToUpper}),
+			},
+			expected: "USR_1234",
+		},
+	}
+
+	data := map[string]interface{}{"id": "usr_1234", "level": "info"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("invalid function", func(t *testing.T) {
+		_, err := NewTemplateFormatter("{id}", WithFuncs(template.FuncMap{"bad": "not a function"}))
+		if err == nil {
+			t.Fatal("Expected an error for an invalid custom function")
+		}
+	})
+}
+
+func TestKVSeparator(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		opts     []FormatterOption
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "pretty default separator",
+			format:   "{ctx | pretty}",
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"a": 1}},
+			expected: "{a=1}",
+		},
+		{
+			name:     "pretty custom separator",
+			format:   "{ctx | pretty}",
+			opts:     []FormatterOption{WithKVSeparator(": ")},
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"a": 1}},
+			expected: "{a: 1}",
+		},
+		{
+			name:     "table default separator",
+			format:   "{ctx | table 6}",
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"a": 1, "b": "x"}},
+			expected: "  a     1\n  b     x",
+		},
+		{
+			name:     "table custom separator",
+			format:   "{ctx | table 6}",
+			opts:     []FormatterOption{WithKVSeparator(" →")},
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"a": 1, "b": "x"}},
+			expected: "  a →   1\n  b →   x",
+		},
+		{
+			name:     "table custom separator with long key",
+			format:   "{ctx | table 3}",
+			opts:     []FormatterOption{WithKVSeparator(": ")},
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"long": 1}},
+			expected: "  long: 1",
+		},
+		{
+			name:     "tree custom separator",
+			format:   "{ctx | tree}",
+			opts:     []FormatterOption{WithKVSeparator(": ")},
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"user": map[string]interface{}{"id": 7}}},
+			expected: "  user\n    id:              7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]FormatterOption{WithNoColors(true)}, tt.opts...)
+			formatter, err := NewTemplateFormatter(tt.format, opts...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPrettyMaxDepth(t *testing.T) {
+	data := map[string]interface{}{
+		"ctx": map[string]interface{}{
+			"zeta":  "last",
+			"alpha": float64(1),
+			"request": map[string]interface{}{
+				"headers": map[string]interface{}{"accept": "*/*"},
+				"path":    "/users",
+				"tags":    []interface{}{"a", []interface{}{"b"}},
+			},
+			"ids":   []interface{}{float64(1), map[string]interface{}{"id": float64(2)}},
+			"empty": map[string]interface{}{},
+			"none":  []interface{}{},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		opts     []FormatterOption
+		expected string
+	}{
+		{
+			name:     "unlimited",
+			format:   "{ctx | pretty}",
+			expected: "{alpha=1, empty={}, ids=[1, {id=2}], none=[], request={headers={accept=*/*}, path=/users, tags=[a, [b]]}, zeta=last}",
+		},
+		{
+			name:     "depth argument",
+			format:   "{ctx | pretty 1}",
+			expected: "{alpha=1, empty={}, ids=[…], none=[], request={…}, zeta=last}",
+		},
+		{
+			name:     "depth option",
+			format:   "{ctx | pretty}",
+			opts:     []FormatterOption{WithPrettyMaxDepth(2)},
+			expected: "{alpha=1, empty={}, ids=[1, {…}], none=[], request={headers={…}, path=/users, tags=[…]}, zeta=last}",
+		},
+		{
+			name:     "argument overrides option",
+			format:   "{ctx | pretty 0}",
+			opts:     []FormatterOption{WithPrettyMaxDepth(1)},
+			expected: "{alpha=1, empty={}, ids=[1, {id=2}], none=[], request={headers={accept=*/*}, path=/users, tags=[a, [b]]}, zeta=last}",
+		},
+		{
+			name:     "scalars are unaffected",
+			format:   "{{pretty 1 .ctx.zeta}}",
+			expected: "last",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]FormatterOption{WithNoColors(true)}, tt.opts...)
+			formatter, err := NewTemplateFormatter(tt.format, opts...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			// Map iteration order is random, so output must be the same every time
+			for i := 0; i < 20; i++ {
+				result, err := formatter.Format(data)
+				if err != nil {
+					t.Fatalf("Format failed: %v", err)
+				}
+				if result != tt.expected {
+					t.Fatalf("Expected %q, got %q", tt.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestMapSort(t *testing.T) {
+	counts := map[string]interface{}{"b": 10, "a": 2, "c": 10, "d": 7.5}
+
+	tests := []struct {
+		name     string
+		format   string
+		order    string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "pretty by key",
+			format:   "{counts | pretty}",
+			order:    MapSortKey,
+			data:     map[string]interface{}{"counts": counts},
+			expected: "{a=2, b=10, c=10, d=7.5}",
+		},
+		{
+			name:     "pretty by value",
+			format:   "{counts | pretty}",
+			order:    MapSortValue,
+			data:     map[string]interface{}{"counts": counts},
+			expected: "{a=2, d=7.5, b=10, c=10}",
+		},
+		{
+			name:     "pretty by value descending",
+			format:   "{counts | pretty}",
+			order:    MapSortValueDesc,
+			data:     map[string]interface{}{"counts": counts},
+			expected: "{b=10, c=10, d=7.5, a=2}",
+		},
+		{
+			name:     "table by value descending",
+			format:   "{counts | table 3}",
+			order:    MapSortValueDesc,
+			data:     map[string]interface{}{"counts": counts},
+			expected: "  b  10\n  c  10\n  d  7.5\n  a  2",
+		},
+		{
+			name:     "strings sort after numbers",
+			format:   "{data | pretty}",
+			order:    MapSortValue,
+			data:     map[string]interface{}{"data": map[string]interface{}{"x": "beta", "y": "alpha", "z": 3}},
+			expected: "{z=3, y=alpha, x=beta}",
+		},
+		{
+			name:     "default sorts by key",
+			format:   "{counts | pretty}",
+			data:     map[string]interface{}{"counts": counts},
+			expected: "{a=2, b=10, c=10, d=7.5}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []FormatterOption{WithNoColors(true)}
+			if tt.order != "" {
+				opts = append(opts, WithMapSort(tt.order))
+			}
+			formatter, err := NewTemplateFormatter(tt.format, opts...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("unknown order", func(t *testing.T) {
+		if _, err := NewTemplateFormatter("{counts | pretty}", WithMapSort("size")); err == nil {
+			t.Error("Expected an error for an unknown sort order")
 		}
 	})
 }
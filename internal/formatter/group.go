@@ -0,0 +1,89 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WithGroupBy makes ProcessStream group consecutive records by the value of a
+// field, such as a tenant or request ID. A dim header like tenant=acme is
+// written whenever the value changes, and the records under it, along with
+// the non-JSON lines that follow them, are indented by WithIndent. Unlike
+// WithUniqueBy or a field counter, every record is kept. The field may be a
+// dotted path into nested maps, and records without it are written
+// unindented, ending the group before them.
+func WithGroupBy(field string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.groupBy = field
+	}
+}
+
+// groupTracker remembers the group last written to each writer, so headers
+// are only written when the group changes
+type groupTracker struct {
+	field  string
+	indent string
+	// current holds the value of the group last written to each writer, or
+	// no entry if the last record written there had no group
+	current map[io.Writer]string
+}
+
+// newGroupTracker returns a groupTracker for a stream, or nil if records
+// aren't grouped
+func (f *TemplateFormatter) newGroupTracker() *groupTracker {
+	if f.groupBy == "" {
+		return nil
+	}
+	return &groupTracker{
+		field:   f.groupBy,
+		indent:  strings.Repeat(" ", f.indentWidth()),
+		current: make(map[io.Writer]string),
+	}
+}
+
+// group returns the group of a record and whether it has one
+func (g *groupTracker) group(data map[string]interface{}) (string, bool) {
+	value, ok := lookupField(data, g.field)
+	if !ok || value == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+// start notes that a record is being written to w, returning the header to
+// write before it if it starts a new group there
+func (g *groupTracker) start(f *TemplateFormatter, w io.Writer, data map[string]interface{}) (string, bool) {
+	if g == nil {
+		return "", false
+	}
+
+	value, ok := g.group(data)
+	if !ok {
+		delete(g.current, w)
+		return "", false
+	}
+	if current, seen := g.current[w]; seen && current == value {
+		return "", false
+	}
+	g.current[w] = value
+	return f.dimFunc(g.field + "=" + value), true
+}
+
+// indentLines indents each non-empty line of text if data belongs to a group
+func (g *groupTracker) indentLines(data map[string]interface{}, text string) string {
+	if g == nil {
+		return text
+	}
+	if _, ok := g.group(data); !ok {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = g.indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
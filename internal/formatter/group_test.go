@@ -0,0 +1,87 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithGroupBy(t *testing.T) {
+	input := `{"n":1,"tenant":"acme"}` + "\n" +
+		`{"n":2,"tenant":"acme"}` + "\n" +
+		"panic: boom\n" +
+		`{"n":3,"tenant":"globex"}` + "\n" +
+		`{"n":4,"tenant":"acme"}` + "\n" +
+		`{"n":5}` + "\n" +
+		`{"n":6,"tenant":"acme"}` + "\n" +
+		`{"n":7,"tenant":"acme","level":"debug"}` + "\n" +
+		`{"n":8,"tenant":"acme"}` + "\n"
+
+	tests := []struct {
+		name     string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:    "headers on change",
+			options: []FormatterOption{WithGroupBy("tenant")},
+			expected: "tenant=acme\n  1\n  2\n  >>> panic: boom\n" +
+				"tenant=globex\n  3\n" +
+				"tenant=acme\n  4\n" +
+				"5\n" +
+				"tenant=acme\n  6\n  8\n",
+		},
+		{
+			name:    "indent and prefix",
+			options: []FormatterOption{WithGroupBy("tenant"), WithIndent(4), WithLineAffixes("| ", "")},
+			expected: "| tenant=acme\n|     1\n|     2\n|     >>> panic: boom\n" +
+				"| tenant=globex\n|     3\n" +
+				"| tenant=acme\n|     4\n" +
+				"| 5\n" +
+				"| tenant=acme\n|     6\n|     8\n",
+		},
+	}
+
+	skip := []SkipPattern{{Field: "level", Value: "debug"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true), WithCompactNonJSON(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestGroupByHeaders(t *testing.T) {
+	input := `{"n":1,"req":{"id":"a"},"level":"info"}` + "\n" +
+		`{"n":2,"req":{"id":"a"},"level":"error"}` + "\n" +
+		`{"n":3,"req":{"id":"a"},"level":"info"}` + "\n"
+
+	// Each writer gets its own headers, and headers are dim
+	var stdout, stderr bytes.Buffer
+	formatter, err := NewTemplateFormatter("{n}", WithGroupBy("req.id"), WithLevelWriter(&stderr, "error"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	if err := formatter.ProcessStream(strings.NewReader(input), &stdout, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	header := "\033[2mreq.id=a\033[0m\n"
+	if expected := header + "  1\n  3\n"; stdout.String() != expected {
+		t.Errorf("Expected stdout %q, got %q", expected, stdout.String())
+	}
+	if expected := header + "  2\n"; stderr.String() != expected {
+		t.Errorf("Expected stderr %q, got %q", expected, stderr.String())
+	}
+}
@@ -0,0 +1,124 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultHighlightStyles are the colors matches are highlighted with when
+// WithHighlight isn't given any
+var defaultHighlightStyles = []string{"black", "bg-yellow"}
+
+// WithHighlight makes ProcessStream and ProcessSingle highlight the text
+// matching re in each formatted record and non-JSON line, whatever the
+// template, using the given colors and styles as for the color function
+// (black on yellow if none are given). Only the text is matched, not the
+// color codes added by the template, and text after a match keeps its
+// colors. Nothing is highlighted when colors are disabled.
+func WithHighlight(re *regexp.Regexp, styles ...string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.highlightRe = re
+		tf.highlightStyles = styles
+	}
+}
+
+// resolveHighlight works out the ANSI SGR code of the highlight styles
+func (f *TemplateFormatter) resolveHighlight() error {
+	if f.highlightRe == nil {
+		return nil
+	}
+
+	styles := f.highlightStyles
+	if len(styles) == 0 {
+		styles = defaultHighlightStyles
+	}
+	codes := make([]string, 0, len(styles))
+	for _, style := range styles {
+		if code, ok := f.styleCodes[style]; ok {
+			codes = append(codes, code)
+		} else if code, ok := colorCode(style, f.colorDepth); ok {
+			codes = append(codes, code)
+		} else {
+			return fmt.Errorf("highlight: unknown color %q", style)
+		}
+	}
+	f.highlightCode = "\033[" + strings.Join(codes, ";") + "m"
+	return nil
+}
+
+// highlight marks the text in s matching the highlight pattern
+func (f *TemplateFormatter) highlight(s string) string {
+	if f.highlightRe == nil || f.noColors {
+		return s
+	}
+
+	// Match against the text without its color codes
+	escapes := ansiEscapeRe.FindAllStringIndex(s, -1)
+	var text strings.Builder
+	prev := 0
+	for _, e := range escapes {
+		text.WriteString(s[prev:e[0]])
+		prev = e[1]
+	}
+	text.WriteString(s[prev:])
+
+	var matches [][]int
+	for _, match := range f.highlightRe.FindAllStringIndex(text.String(), -1) {
+		if match[1] > match[0] {
+			matches = append(matches, match)
+		}
+	}
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	active := "" // Color codes in effect, restored after each match
+	pos, next, inMatch := 0, 0, false
+
+	// writeText writes text between color codes, starting and ending
+	// highlights at the matches within it
+	writeText := func(text string) {
+		for len(text) > 0 {
+			if !inMatch && next < len(matches) && pos == matches[next][0] {
+				b.WriteString(f.highlightCode)
+				inMatch = true
+			}
+
+			n := len(text)
+			if inMatch {
+				n = min(n, matches[next][1]-pos)
+			} else if next < len(matches) {
+				n = min(n, matches[next][0]-pos)
+			}
+			b.WriteString(text[:n])
+			text, pos = text[n:], pos+n
+
+			if inMatch && pos == matches[next][1] {
+				b.WriteString(ansiReset + active)
+				inMatch = false
+				next++
+			}
+		}
+	}
+
+	prev = 0
+	for _, e := range escapes {
+		writeText(s[prev:e[0]])
+		escape := s[e[0]:e[1]]
+		b.WriteString(escape)
+		if escape == ansiReset || escape == "\033[m" {
+			active = ""
+		} else {
+			active += escape
+		}
+		// Keep the highlight over colors that start within a match
+		if inMatch {
+			b.WriteString(f.highlightCode)
+		}
+		prev = e[1]
+	}
+	writeText(s[prev:])
+	return b.String()
+}
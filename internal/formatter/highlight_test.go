@@ -0,0 +1,111 @@
+package formatter
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	const on = "\033[30;43m"
+
+	tests := []struct {
+		name     string
+		pattern  string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain text",
+			pattern:  "(?i)timeout",
+			input:    "Timeout after 5s, timeout again",
+			expected: on + "Timeout" + ansiReset + " after 5s, " + on + "timeout" + ansiReset + " again",
+		},
+		{
+			name:     "no match",
+			pattern:  "panic",
+			input:    "\033[31merror\033[0m ok",
+			expected: "\033[31merror\033[0m ok",
+		},
+		{
+			name:     "colors are restored after a match",
+			pattern:  "db",
+			input:    "\033[2mdb.query\033[0m done",
+			expected: "\033[2m" + on + "db" + ansiReset + "\033[2m.query\033[0m done",
+		},
+		{
+			name:     "color codes aren't matched",
+			pattern:  "31",
+			input:    "\033[31merror\033[0m 31",
+			expected: "\033[31merror\033[0m " + on + "31" + ansiReset,
+		},
+		{
+			name:     "match spanning color codes",
+			pattern:  "or ok",
+			input:    "\033[31merror\033[0m ok",
+			expected: "\033[31merr" + on + "or\033[0m" + on + " ok" + ansiReset,
+		},
+		{
+			name:     "empty matches are ignored",
+			pattern:  "x*",
+			input:    "abc",
+			expected: "abc",
+		},
+		{
+			name:     "multi-byte characters",
+			pattern:  "ü",
+			input:    "Zürich",
+			expected: "Z" + on + "ü" + ansiReset + "rich",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter("{message}", WithHighlight(regexp.MustCompile(tt.pattern)))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+			if result := formatter.highlight(tt.input); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithHighlight(t *testing.T) {
+	input := `{"level":"error","message":"db timeout"}` + "\n" + "Timeout waiting for db\n"
+	re := regexp.MustCompile("(?i)timeout")
+
+	formatter, err := NewTemplateFormatter(`{level | color "red"} {message}`, WithCompactNonJSON(true),
+		WithHighlight(re, "bold", "underline"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	expected := "\033[31merror\033[0m db \033[1;4mtimeout\033[0m\n" +
+		"\033[31m>>>\033[0m \033[1;4mTimeout\033[0m waiting for db\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	// Nothing is highlighted without colors
+	plain, err := NewTemplateFormatter("{message}", WithNoColors(true), WithHighlight(re))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	buf.Reset()
+	if err := plain.ProcessStream(strings.NewReader(input), &buf, plain, nil, false); err == nil {
+		t.Fatal("Expected an error for the non-JSON line")
+	}
+	if expected := "db timeout\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	if _, err := NewTemplateFormatter("{message}", WithHighlight(re, "mauve")); err == nil {
+		t.Error("Expected an error for an unknown highlight color")
+	}
+}
@@ -0,0 +1,55 @@
+package formatter
+
+import "strings"
+
+// WithLiftedFields promotes the keys of the given object fields to the top
+// level of each record read by ProcessStream, and removes the objects, so
+// {"jsonPayload":{"message":"ready"}} is formatted as {"message":"ready"}.
+// Fields may be dotted paths to nested objects, such as payload.context.
+// Top-level fields take precedence over lifted keys of the same name, unless
+// WithLiftOverwrite is set, and fields are lifted in order. Fields that are
+// missing or aren't objects are left as they are. Lifting happens after key
+// normalization and before level normalization, so a lifted level or
+// severity can be normalized.
+func WithLiftedFields(fields ...string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.liftFields = fields
+	}
+}
+
+// WithLiftOverwrite makes keys lifted by WithLiftedFields replace top-level
+// fields of the same name, rather than being dropped. When several lifted
+// objects have the same key, the last one lifted wins.
+func WithLiftOverwrite(overwrite bool) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.liftOverwrite = overwrite
+	}
+}
+
+// liftFields moves the keys of each object field in fields to the top level
+// of data. Top-level fields that already exist are kept unless overwrite is
+// set.
+func liftFields(data map[string]interface{}, fields []string, overwrite bool) {
+	for _, field := range fields {
+		// The object is removed from the map it is nested in
+		parent, key := data, field
+		if path := strings.Split(field, "."); len(path) > 1 {
+			if value, ok := digPath(data, path[:len(path)-1]); ok {
+				if m, ok := value.(map[string]interface{}); ok {
+					parent, key = m, path[len(path)-1]
+				}
+			}
+		}
+
+		nested, ok := parent[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		delete(parent, key)
+		for key, value := range nested {
+			if _, exists := data[key]; overwrite || !exists {
+				data[key] = value
+			}
+		}
+	}
+}
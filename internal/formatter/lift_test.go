@@ -0,0 +1,117 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLiftFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		fields    []string
+		overwrite bool
+		data      map[string]interface{}
+		expected  map[string]interface{}
+	}{
+		{
+			name:   "promotes nested keys",
+			fields: []string{"jsonPayload"},
+			data: map[string]interface{}{
+				"severity":    "INFO",
+				"jsonPayload": map[string]interface{}{"message": "ready", "port": 8080},
+			},
+			expected: map[string]interface{}{"severity": "INFO", "message": "ready", "port": 8080},
+		},
+		{
+			name:   "top-level fields win",
+			fields: []string{"jsonPayload"},
+			data: map[string]interface{}{
+				"timestamp":   "2024-03-10T15:04:05Z",
+				"jsonPayload": map[string]interface{}{"timestamp": "later", "message": "ready"},
+			},
+			expected: map[string]interface{}{"timestamp": "2024-03-10T15:04:05Z", "message": "ready"},
+		},
+		{
+			name:      "forced",
+			fields:    []string{"context"},
+			overwrite: true,
+			data: map[string]interface{}{
+				"user":    "anonymous",
+				"context": map[string]interface{}{"user": "ada"},
+			},
+			expected: map[string]interface{}{"user": "ada"},
+		},
+		{
+			name:   "dotted path",
+			fields: []string{"payload.context"},
+			data: map[string]interface{}{
+				"payload": map[string]interface{}{"context": map[string]interface{}{"user": "ada"}, "id": 1},
+			},
+			expected: map[string]interface{}{"payload": map[string]interface{}{"id": 1}, "user": "ada"},
+		},
+		{
+			name:     "dotted key",
+			fields:   []string{"log.context"},
+			data:     map[string]interface{}{"log.context": map[string]interface{}{"user": "ada"}},
+			expected: map[string]interface{}{"user": "ada"},
+		},
+		{
+			name:   "earlier fields win",
+			fields: []string{"a", "b"},
+			data: map[string]interface{}{
+				"a": map[string]interface{}{"x": 1},
+				"b": map[string]interface{}{"x": 2, "y": 3},
+			},
+			expected: map[string]interface{}{"x": 1, "y": 3},
+		},
+		{
+			name:      "later fields win when forced",
+			fields:    []string{"a", "b"},
+			overwrite: true,
+			data: map[string]interface{}{
+				"a": map[string]interface{}{"x": 1},
+				"b": map[string]interface{}{"x": 2, "y": 3},
+			},
+			expected: map[string]interface{}{"x": 2, "y": 3},
+		},
+		{
+			name:     "not an object",
+			fields:   []string{"jsonPayload", "missing"},
+			data:     map[string]interface{}{"jsonPayload": "text"},
+			expected: map[string]interface{}{"jsonPayload": "text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			liftFields(tt.data, tt.fields, tt.overwrite)
+			if !reflect.DeepEqual(tt.data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.data)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithLiftedFields(t *testing.T) {
+	input := `{"severity":"WARNING","jsonPayload":{"message":"quota low","user":"ada"}}` + "\n" +
+		`{"severity":"ERROR","jsonPayload":{"message":"quota exceeded","level":"fatal"}}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{level} {message} {user}",
+		WithLiftedFields("jsonPayload"), WithLevelNormalization("severity"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	skip := []SkipPattern{{Field: "user", Value: "ada"}}
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, skip, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	// Lifting comes first, so the severity replaces the lifted level
+	if expected := "error quota exceeded <no value>\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
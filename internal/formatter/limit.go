@@ -0,0 +1,114 @@
+package formatter
+
+import (
+	"bytes"
+	"io"
+)
+
+// WithHead makes ProcessStream stop once it has written n records, without
+// reading the rest of the input. Records removed by skip patterns don't
+// count towards the limit, and neither do non-JSON lines. Zero, the default,
+// means no limit.
+func WithHead(n int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.head = max(n, 0)
+	}
+}
+
+// WithTail makes ProcessStream hold back its output and write only the last n
+// records once the input ends. Like WithHead, the limit applies after skip
+// patterns. Non-JSON lines are kept with the record before them, so a stack
+// trace following an error is shown with it. Zero, the default, means no
+// limit.
+func WithTail(n int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.tail = max(n, 0)
+	}
+}
+
+// tailEntry is the output of a record, and any non-JSON lines after it
+type tailEntry struct {
+	data map[string]interface{}
+	out  bytes.Buffer
+}
+
+// tailWriter keeps the output of the last records written to it in a ring
+// buffer, until WriteTail writes them to the underlying writer. It relies on
+// ProcessStream calling StartRecord before each record.
+type tailWriter struct {
+	w       io.Writer
+	entries []*tailEntry
+	next    int
+	// leading collects output written before the first record
+	leading *tailEntry
+	current *tailEntry
+}
+
+// newTailWriter returns a tailWriter that keeps the last n records written to it
+func newTailWriter(w io.Writer, n int) *tailWriter {
+	leading := &tailEntry{}
+	return &tailWriter{
+		w:       w,
+		entries: make([]*tailEntry, n),
+		leading: leading,
+		current: leading,
+	}
+}
+
+// Unwrap returns the writer the kept records are written to
+func (tw *tailWriter) Unwrap() io.Writer {
+	return tw.w
+}
+
+// StartRecord starts a new entry for a record, replacing the oldest if the
+// buffer is full
+func (tw *tailWriter) StartRecord(data map[string]interface{}) error {
+	entry := tw.entries[tw.next]
+	if entry == nil {
+		entry = &tailEntry{}
+		tw.entries[tw.next] = entry
+	} else {
+		// The oldest record is pushed out, along with anything before it
+		tw.leading = nil
+	}
+	entry.data = data
+	entry.out.Reset()
+
+	tw.current = entry
+	tw.next = (tw.next + 1) % len(tw.entries)
+	return nil
+}
+
+// Write adds p to the output of the current record
+func (tw *tailWriter) Write(p []byte) (int, error) {
+	return tw.current.out.Write(p)
+}
+
+// WriteTail writes the buffered records to the underlying writer, oldest
+// first, passing each record to it as ProcessStream would
+func (tw *tailWriter) WriteTail() error {
+	entries := make([]*tailEntry, 0, len(tw.entries)+1)
+	if tw.leading != nil {
+		entries = append(entries, tw.leading)
+	}
+	for i := range tw.entries {
+		if entry := tw.entries[(tw.next+i)%len(tw.entries)]; entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.data != nil {
+			if err := startRecord(tw.w, entry.data); err != nil {
+				return err
+			}
+		}
+		if _, err := tw.w.Write(entry.out.Bytes()); err != nil {
+			return err
+		}
+		if err := flushRecord(tw.w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
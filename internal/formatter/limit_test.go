@@ -0,0 +1,213 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessStreamWithLimits(t *testing.T) {
+	input := `{"n":1,"level":"info"}` + "\n" +
+		`{"n":2,"level":"debug"}` + "\n" +
+		`{"n":3,"level":"error"}` + "\n" +
+		"panic: boom\n" +
+		"\tmain.go:12\n" +
+		`{"n":4,"level":"info"}` + "\n" +
+		`{"n":5,"level":"debug"}` + "\n" +
+		`{"n":6,"level":"info"}` + "\n"
+
+	tests := []struct {
+		name     string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:     "head",
+			options:  []FormatterOption{WithHead(2)},
+			expected: "1\n3\n",
+		},
+		{
+			name:     "head with non-JSON lines",
+			options:  []FormatterOption{WithHead(3)},
+			expected: "1\n3\n>>> panic: boom\n>>> \tmain.go:12\n4\n",
+		},
+		{
+			name:     "head larger than the input",
+			options:  []FormatterOption{WithHead(10)},
+			expected: "1\n3\n>>> panic: boom\n>>> \tmain.go:12\n4\n6\n",
+		},
+		{
+			name:     "tail",
+			options:  []FormatterOption{WithTail(2)},
+			expected: "4\n6\n",
+		},
+		{
+			name:     "tail keeps non-JSON lines with the record before them",
+			options:  []FormatterOption{WithTail(3)},
+			expected: "3\n>>> panic: boom\n>>> \tmain.go:12\n4\n6\n",
+		},
+		{
+			name:     "tail larger than the input",
+			options:  []FormatterOption{WithTail(10)},
+			expected: "1\n3\n>>> panic: boom\n>>> \tmain.go:12\n4\n6\n",
+		},
+		{
+			name:     "head then tail",
+			options:  []FormatterOption{WithHead(3), WithTail(1)},
+			expected: "4\n",
+		},
+	}
+
+	skip := []SkipPattern{{Field: "level", Value: "debug"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true), WithCompactNonJSON(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestTailKeepsLeadingNonJSON(t *testing.T) {
+	input := "starting up\n" + `{"n":1}` + "\n" + `{"n":2}` + "\n"
+
+	tests := []struct {
+		tail     int
+		expected string
+	}{
+		{tail: 2, expected: ">>> starting up\n1\n2\n"},
+		{tail: 1, expected: "2\n"},
+	}
+
+	for _, tt := range tests {
+		formatter, err := NewTemplateFormatter("{n}", WithNoColors(true), WithCompactNonJSON(true), WithTail(tt.tail))
+		if err != nil {
+			t.Fatalf("Failed to create formatter: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+			t.Fatalf("ProcessStream failed: %v", err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("With a tail of %d, expected %q, got %q", tt.tail, tt.expected, buf.String())
+		}
+	}
+}
+
+// cancelAfter is a Formatter that cancels a context once it has formatted
+// the given number of records
+type cancelAfter struct {
+	Formatter
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfter) Format(data map[string]interface{}) (string, error) {
+	if c.n--; c.n == 0 {
+		c.cancel()
+	}
+	return c.Formatter.Format(data)
+}
+
+func TestTailWrittenWhenCancelled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		// Write three records, then leave the read blocked
+		_, _ = io.WriteString(pw, `{"n":1}`+"\n"+`{"n":2}`+"\n"+`{"n":3}`+"\n")
+	}()
+
+	formatter, err := NewTemplateFormatter("{n}", WithTail(2))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var buf bytes.Buffer
+	err = formatter.ProcessStreamContext(ctx, pr, &buf, &cancelAfter{Formatter: formatter, n: 3, cancel: cancel}, nil, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if buf.String() != "2\n3\n" {
+		t.Errorf("Expected the last two records, got %q", buf.String())
+	}
+}
+
+func TestHeadStopsReading(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		// Keep writing records until the reader goes away
+		for {
+			if _, err := io.WriteString(pw, `{"n":1}`+"\n"); err != nil {
+				return
+			}
+		}
+	}()
+	defer pr.Close()
+
+	formatter, err := NewTemplateFormatter("{n}", WithHead(3))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(pr, &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if buf.String() != "1\n1\n1\n" {
+		t.Errorf("Expected three records, got %q", buf.String())
+	}
+}
+
+// endlessReader returns the same line on every read
+type endlessReader struct{ line string }
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	return copy(p, r.line), nil
+}
+
+func TestHeadReleasesReader(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{n}", WithHead(3))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	// The context outlives the stream, so only the stream can stop the
+	// goroutine reading lines
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+	var buf bytes.Buffer
+	if err := formatter.ProcessStreamContext(ctx, endlessReader{line: `{"n":1}` + "\n"}, &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStreamContext failed: %v", err)
+	}
+	if buf.String() != "1\n1\n1\n" {
+		t.Errorf("Expected three records, got %q", buf.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Line reader still running after the head limit: %d goroutines, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,120 @@
+package formatter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MergeSourceField is the field ProcessMergedStreams adds to each record
+// with the name of the input it was read from, so templates such as a line
+// prefix can show where a record came from
+const MergeSourceField = "_file"
+
+// NamedInput is an input to ProcessMergedStreams and the name it is known by
+type NamedInput struct {
+	Name   string
+	Reader io.Reader
+}
+
+// ProcessMergedStreams is like ProcessStreamContext, but reads several inputs
+// and interleaves their records in timestamp order. Each input is expected to
+// be in time order already, as logs usually are, so only the next line of
+// each input is held while choosing which to write. Records with the same
+// time are written in the order of the inputs.
+//
+// The time of a record is read as when splitting by time or replaying. Lines
+// without a time, such as stack traces and non-JSON output, keep their place
+// after the line before them in the same input, and lines at the start of an
+// input before any time is seen are written first. Each record gets a
+// MergeSourceField with the name of its input.
+func (f *TemplateFormatter) ProcessMergedStreams(ctx context.Context, inputs []NamedInput, w io.Writer, formatter Formatter, skipPatterns []SkipPattern, handleNonJSON bool) error {
+	// Lines are read in goroutines, which must stop if the stream ends
+	// before the inputs do
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return f.processLines(ctx, f.mergeLines(ctx, inputs), w, formatter, skipPatterns, handleNonJSON)
+}
+
+// mergeSource is an input being merged and its next line
+type mergeSource struct {
+	name    string
+	next    func() (string, error)
+	started bool
+	done    bool
+	line    string
+	time    time.Time
+	// stale is set once line has been returned, until the next is read
+	stale bool
+}
+
+// advance reads the next line of the source and its time, which is the time
+// of the line before it if it doesn't have one
+func (f *TemplateFormatter) advance(s *mergeSource) error {
+	line, err := s.next()
+	if errors.Is(err, io.EOF) {
+		s.done = true
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", s.name, err)
+	}
+
+	// Each input may start with its own byte order mark
+	if !s.started {
+		line = strings.TrimPrefix(line, utf8BOM)
+		s.started = true
+	}
+	s.line = line
+
+	if data, err := f.inputParser.Parse([]byte(strings.TrimLeft(line, " \t"))); err == nil {
+		data = normalizeKeys(data, f.normalizeKeys)
+		liftFields(data, f.liftFields, f.liftOverwrite)
+		if t, ok := recordTimestamp(data); ok {
+			s.time = t
+		}
+	}
+	return nil
+}
+
+// mergeLines returns a lineReader that interleaves the lines of inputs by
+// time, as described for ProcessMergedStreams
+func (f *TemplateFormatter) mergeLines(ctx context.Context, inputs []NamedInput) lineReader {
+	sources := make([]*mergeSource, len(inputs))
+	for i, input := range inputs {
+		sources[i] = &mergeSource{
+			name:  input.Name,
+			next:  scanLines(ctx, input.Reader, f.splitFunc(), f.columnsIdle()),
+			stale: true,
+		}
+	}
+
+	// The source of the line returned last is read from again on the next
+	// call, so that no input is read further ahead than needed. A source
+	// whose read fails, such as when its input pauses, is read from again on
+	// the next call too.
+	return func() (string, string, error) {
+		for _, s := range sources {
+			if s.stale && !s.done {
+				if err := f.advance(s); err != nil {
+					return "", "", err
+				}
+				s.stale = false
+			}
+		}
+
+		var last *mergeSource
+		for _, s := range sources {
+			if !s.done && (last == nil || s.time.Before(last.time)) {
+				last = s
+			}
+		}
+		if last == nil {
+			return "", "", io.EOF
+		}
+		last.stale = true
+		return last.line, last.name, nil
+	}
+}
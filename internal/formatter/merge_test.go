@@ -0,0 +1,70 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProcessMergedStreams(t *testing.T) {
+	api := `{"time":"2024-03-10T10:00:01Z","msg":"a1"}` + "\n" +
+		`{"time":"2024-03-10T10:00:03Z","msg":"a2"}` + "\n" +
+		"panic: boom\n" +
+		`{"time":"2024-03-10T10:00:05Z","msg":"a3"}` + "\n"
+	worker := "\uFEFFstarting\n" +
+		`{"time":"2024-03-10T10:00:02Z","msg":"w1"}` + "\n" +
+		`{"msg":"w2"}` + "\n" +
+		`{"time":"2024-03-10T10:00:03Z","msg":"w3"}` + "\n" +
+		`{"time":"2024-03-10T10:00:09Z","msg":"w4"}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{msg}",
+		WithNoColors(true), WithCompactNonJSON(true), WithLineAffixes("{_file | pad 6} ", ""))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	inputs := []NamedInput{
+		{Name: "api", Reader: strings.NewReader(api)},
+		{Name: "worker", Reader: strings.NewReader(worker)},
+	}
+	if err := formatter.ProcessMergedStreams(context.Background(), inputs, &buf, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessMergedStreams failed: %v", err)
+	}
+
+	expected := "worker >>> starting\n" +
+		"api    a1\n" +
+		"worker w1\n" +
+		"worker w2\n" +
+		"api    a2\n" +
+		"api    >>> panic: boom\n" +
+		"worker w3\n" +
+		"api    a3\n" +
+		"worker w4\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestProcessMergedStreamsSkipAndHead(t *testing.T) {
+	first := `{"ts":3,"n":1}` + "\n" + `{"ts":5,"n":2}` + "\n"
+	second := `{"ts":4,"n":3}` + "\n" + `{"ts":6,"n":4}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{n} {_file}", WithNoColors(true), WithHead(3))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	inputs := []NamedInput{
+		{Name: "first", Reader: strings.NewReader(first)},
+		{Name: "second", Reader: strings.NewReader(second)},
+	}
+	if err := formatter.ProcessMergedStreams(context.Background(), inputs, &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessMergedStreams failed: %v", err)
+	}
+	if expected := "1 first\n3 second\n2 first\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
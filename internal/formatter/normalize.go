@@ -0,0 +1,131 @@
+package formatter
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Key normalization modes, which rewrite the separators in record keys
+const (
+	// KeyNormalizeDot converts underscores in keys to dots
+	KeyNormalizeDot = "dot"
+	// KeyNormalizeUnderscore converts dots in keys to underscores
+	KeyNormalizeUnderscore = "underscore"
+)
+
+// normalizeKeys returns a copy of data with the separators in its keys, and
+// the keys of any nested maps, rewritten according to mode. If two keys
+// normalize to the same name, the one that was already in normal form wins.
+func normalizeKeys(data map[string]interface{}, mode string) map[string]interface{} {
+	var from, to string
+	switch mode {
+	case KeyNormalizeDot:
+		from, to = "_", "."
+	case KeyNormalizeUnderscore:
+		from, to = ".", "_"
+	default:
+		return data
+	}
+	return normalizeMap(data, from, to)
+}
+
+// normalizeMap rewrites from to to in the keys of m, recursively
+func normalizeMap(m map[string]interface{}, from, to string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+
+	// Copy keys that are already normalized first, so they take precedence
+	for key, val := range m {
+		if !strings.Contains(key, from) {
+			result[key] = normalizeValue(val, from, to)
+		}
+	}
+	for key, val := range m {
+		if !strings.Contains(key, from) {
+			continue
+		}
+		normalized := strings.ReplaceAll(key, from, to)
+		if _, exists := result[normalized]; !exists {
+			result[normalized] = normalizeValue(val, from, to)
+		}
+	}
+
+	return result
+}
+
+// normalizeValue normalizes the keys of maps within val, including maps
+// nested in arrays
+func normalizeValue(val interface{}, from, to string) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return normalizeMap(v, from, to)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeValue(item, from, to)
+		}
+		return result
+	default:
+		return val
+	}
+}
+
+// TimeNormalizeRFC3339 is the layout name WithTimeNormalization accepts for
+// RFC 3339 timestamps
+const TimeNormalizeRFC3339 = "rfc3339"
+
+// normalizeTime rewrites the first of a record's recordTimeFields that holds
+// a time as that time in UTC, formatted with layout. Records without a time
+// are left unchanged.
+func normalizeTime(data map[string]interface{}, layout string) {
+	if layout == "" {
+		return
+	}
+	for _, field := range recordTimeFields {
+		if t, ok := parseTimestamp(data[field]); ok {
+			data[field] = formatTime(t.UTC(), layout)
+			return
+		}
+	}
+}
+
+// levelAliases maps lowercased level names used by various loggers, such as
+// GCP severities, to the canonical names understood by colorByLevel
+var levelAliases = map[string]string{
+	"warning":     "warn",
+	"err":         "error",
+	"crit":        "critical",
+	"emerg":       "emergency",
+	"information": "info",
+}
+
+// normalizeLevel sets data["level"] to the canonical name of the level found
+// in field. Records without the field, or with a value that isn't a level,
+// are left unchanged.
+func normalizeLevel(data map[string]interface{}, field string) {
+	if field == "" {
+		return
+	}
+	if level := canonicalLevel(data[field]); level != "" {
+		data["level"] = level
+	}
+}
+
+// canonicalLevel returns the canonical name of a level. Numbers from 0 to 7
+// are syslog severities, and names are lowercased and mapped through
+// levelAliases.
+func canonicalLevel(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		level := strings.ToLower(strings.TrimSpace(v))
+		if alias, ok := levelAliases[level]; ok {
+			return alias
+		}
+		return level
+	case json.Number, int, int64, float64:
+		n, ok := toFloat64(v)
+		if ok && n >= 0 && int(n) < len(syslogLevels) && n == float64(int(n)) {
+			return syslogLevels[int(n)]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,358 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "underscores to dots",
+			mode:     KeyNormalizeDot,
+			data:     map[string]interface{}{"grpc_method": "Get", "level": "info"},
+			expected: map[string]interface{}{"grpc.method": "Get", "level": "info"},
+		},
+		{
+			name:     "dots to underscores",
+			mode:     KeyNormalizeUnderscore,
+			data:     map[string]interface{}{"grpc.method": "Get", "http.status_code": 200},
+			expected: map[string]interface{}{"grpc_method": "Get", "http_status_code": 200},
+		},
+		{
+			name: "nested maps and arrays",
+			mode: KeyNormalizeDot,
+			data: map[string]interface{}{
+				"ctx":   map[string]interface{}{"user_id": 1},
+				"spans": []interface{}{map[string]interface{}{"span_id": "a"}, "x"},
+			},
+			expected: map[string]interface{}{
+				"ctx":   map[string]interface{}{"user.id": 1},
+				"spans": []interface{}{map[string]interface{}{"span.id": "a"}, "x"},
+			},
+		},
+		{
+			name:     "already normalized key wins",
+			mode:     KeyNormalizeDot,
+			data:     map[string]interface{}{"grpc_method": "old", "grpc.method": "new"},
+			expected: map[string]interface{}{"grpc.method": "new"},
+		},
+		{
+			name:     "no normalization",
+			mode:     "",
+			data:     map[string]interface{}{"grpc_method": "Get"},
+			expected: map[string]interface{}{"grpc_method": "Get"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := normalizeKeys(tt.data, tt.mode)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithKeyNormalization(t *testing.T) {
+	input := `{"grpc_method":"Get","level":"info"}` + "\n" +
+		`{"grpc.method":"List","level":"debug"}` + "\n"
+
+	tests := []struct {
+		name     string
+		mode     string
+		format   string
+		skip     []SkipPattern
+		expected string
+	}{
+		{
+			name:     "dot",
+			mode:     KeyNormalizeDot,
+			format:   "{{.level}} {{@grpc.method}}",
+			expected: "info Get\ndebug List\n",
+		},
+		{
+			name:     "underscore",
+			mode:     KeyNormalizeUnderscore,
+			format:   "{level} {grpc_method}",
+			expected: "info Get\ndebug List\n",
+		},
+		{
+			name:     "skip patterns see normalized keys",
+			mode:     KeyNormalizeUnderscore,
+			format:   "{level} {grpc_method}",
+			skip:     []SkipPattern{{Field: "grpc_method", Value: "List"}},
+			expected: "info Get\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format, WithKeyNormalization(tt.mode))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, tt.skip, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+
+	t.Run("unknown mode", func(t *testing.T) {
+		if _, err := NewTemplateFormatter("{level}", WithKeyNormalization("camel")); err == nil {
+			t.Error("Expected an error for an unknown normalization mode")
+		}
+	})
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		data     map[string]interface{}
+		expected interface{}
+	}{
+		{
+			name:     "GCP warning",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": "WARNING"},
+			expected: "warn",
+		},
+		{
+			name:     "GCP error",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": "ERROR"},
+			expected: "error",
+		},
+		{
+			name:     "GCP default is lowercased",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": "DEFAULT"},
+			expected: "default",
+		},
+		{
+			name:     "numeric syslog warning",
+			field:    "priority",
+			data:     map[string]interface{}{"priority": float64(4)},
+			expected: "warning",
+		},
+		{
+			name:     "numeric syslog emergency",
+			field:    "sev",
+			data:     map[string]interface{}{"sev": 0},
+			expected: "emergency",
+		},
+		{
+			name:     "syslog abbreviation",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": "crit"},
+			expected: "critical",
+		},
+		{
+			name:     "replaces existing level",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": "Info", "level": 30},
+			expected: "info",
+		},
+		{
+			name:     "out of range number",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": float64(42), "level": "info"},
+			expected: "info",
+		},
+		{
+			name:     "fractional number",
+			field:    "severity",
+			data:     map[string]interface{}{"severity": 2.5},
+			expected: nil,
+		},
+		{
+			name:     "missing field",
+			field:    "severity",
+			data:     map[string]interface{}{"message": "x"},
+			expected: nil,
+		},
+		{
+			name:     "disabled",
+			field:    "",
+			data:     map[string]interface{}{"severity": "ERROR"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizeLevel(tt.data, tt.field)
+			if level := tt.data["level"]; level != tt.expected {
+				t.Errorf("Expected level %v, got %v", tt.expected, level)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithLevelNormalization(t *testing.T) {
+	input := `{"severity":"WARNING","message":"quota low"}` + "\n" +
+		`{"severity":"ERROR","message":"quota exceeded"}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{level | colorByLevel .level} {severity}", WithLevelNormalization("severity"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	skip := []SkipPattern{{Field: "level", Value: "warn"}}
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, skip, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "\033[31merror\033[0m ERROR\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestNormalizeTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		data     map[string]interface{}
+		field    string
+		expected interface{}
+	}{
+		{
+			name:     "epoch seconds",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"time": json.Number("1710083045")},
+			field:    "time",
+			expected: "2024-03-10T15:04:05Z",
+		},
+		{
+			name:     "epoch milliseconds",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"ts": json.Number("1710083045250")},
+			field:    "ts",
+			expected: "2024-03-10T15:04:05.25Z",
+		},
+		{
+			name:     "common log format",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"timestamp": "10/Oct/2000:13:55:36 -0700"},
+			field:    "timestamp",
+			expected: "2000-10-10T20:55:36Z",
+		},
+		{
+			name:     "offset converted to UTC",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"@timestamp": "2024-03-10T17:04:05+02:00"},
+			field:    "@timestamp",
+			expected: "2024-03-10T15:04:05Z",
+		},
+		{
+			name:     "strftime layout",
+			layout:   "%Y-%m-%d %H:%M:%S",
+			data:     map[string]interface{}{"time": float64(1710083045)},
+			field:    "time",
+			expected: "2024-03-10 15:04:05",
+		},
+		{
+			name:     "first field with a time",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"timestamp": "soon", "time": json.Number("1710083045")},
+			field:    "time",
+			expected: "2024-03-10T15:04:05Z",
+		},
+		{
+			name:     "unparseable",
+			layout:   time.RFC3339Nano,
+			data:     map[string]interface{}{"time": "yesterday"},
+			field:    "time",
+			expected: "yesterday",
+		},
+		{
+			name:     "disabled",
+			layout:   "",
+			data:     map[string]interface{}{"time": json.Number("1710083045")},
+			field:    "time",
+			expected: json.Number("1710083045"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizeTime(tt.data, tt.layout)
+			if value := tt.data[tt.field]; value != tt.expected {
+				t.Errorf("Expected %s %v, got %v", tt.field, tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithTimeNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		parser   InputParser
+		template string
+		layout   string
+		input    string
+		expected string
+	}{
+		{
+			name:     "epoch",
+			parser:   JSONParser{},
+			template: "{ts} {msg}",
+			layout:   TimeNormalizeRFC3339,
+			input:    `{"ts":1710083045.5,"msg":"a"}` + "\n" + `{"msg":"b"}` + "\n",
+			expected: "2024-03-10T15:04:05.5Z a\n<no value> b\n",
+		},
+		{
+			name:     "common log format",
+			parser:   AccessLogParser{},
+			template: "{timestamp} {path}",
+			layout:   TimeNormalizeRFC3339,
+			input:    `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326` + "\n",
+			expected: "2000-10-10T20:55:36Z /apache_pb.gif\n",
+		},
+		{
+			name:     "custom layout",
+			parser:   JSONParser{},
+			template: "{ts} {msg}",
+			layout:   "15:04:05",
+			input:    `{"ts":"2024-03-10T17:04:05+02:00","msg":"a"}` + "\n",
+			expected: "15:04:05 a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template,
+				WithInputParser(tt.parser), WithTimeNormalization(tt.layout))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(tt.input), &buf, formatter, nil, false); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
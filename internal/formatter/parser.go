@@ -0,0 +1,152 @@
+package formatter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// InputParser decodes a single line of input into a log record
+type InputParser interface {
+	// Parse converts a line, without its trailing newline, to a log record
+	Parse(line []byte) (map[string]interface{}, error)
+}
+
+// InputParserFunc adapts an ordinary function to the InputParser interface
+type InputParserFunc func(line []byte) (map[string]interface{}, error)
+
+// Parse calls fn(line)
+func (fn InputParserFunc) Parse(line []byte) (map[string]interface{}, error) {
+	return fn(line)
+}
+
+// Names of the built-in input formats
+const (
+	InputFormatJSON   = "json"
+	InputFormatLogfmt = "logfmt"
+	InputFormatSyslog = "syslog"
+	InputFormatAccess = "access"
+)
+
+// inputParsers maps input format names to their parsers
+var inputParsers = map[string]InputParser{
+	InputFormatJSON:   JSONParser{},
+	InputFormatLogfmt: LogfmtParser{},
+	InputFormatSyslog: SyslogParser{},
+	InputFormatAccess: AccessLogParser{},
+}
+
+// NewInputParser returns the built-in parser for the named input format
+func NewInputParser(name string) (InputParser, error) {
+	if parser, ok := inputParsers[strings.ToLower(name)]; ok {
+		return parser, nil
+	}
+	return nil, fmt.Errorf("unknown input format %q (available: %s)", name, strings.Join(InputFormats(), ", "))
+}
+
+// InputFormats returns the sorted names of the built-in input formats
+func InputFormats() []string {
+	names := make([]string, 0, len(inputParsers))
+	for name := range inputParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JSONParser parses lines containing a JSON object
+type JSONParser struct{}
+
+// Parse decodes line as a JSON object
+func (JSONParser) Parse(line []byte) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(line, &data); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("invalid JSON: %s", line))
+	}
+	return data, nil
+}
+
+// LogfmtParser parses lines of logfmt key=value pairs, such as
+// `level=info msg="request complete" duration=12ms`. Values may be quoted
+// using Go string syntax, and keys without a value are set to true. All other
+// values are kept as strings.
+type LogfmtParser struct{}
+
+// Parse decodes line as logfmt, returning an error if it contains no
+// key=value pairs
+func (LogfmtParser) Parse(line []byte) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	text := string(line)
+	hasPair := false
+
+	for {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			break
+		}
+
+		// Read the key, up to an equals sign or whitespace
+		end := strings.IndexFunc(text, func(r rune) bool {
+			return r == '=' || unicode.IsSpace(r)
+		})
+		if end < 0 {
+			end = len(text)
+		}
+		key := text[:end]
+		text = text[end:]
+		if key == "" {
+			return nil, fmt.Errorf("invalid logfmt: missing key: %s", line)
+		}
+
+		// A key without a value is a flag
+		if !strings.HasPrefix(text, "=") {
+			data[key] = true
+			continue
+		}
+		text = text[1:]
+		hasPair = true
+
+		value, rest, err := readLogfmtValue(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logfmt: %w: %s", err, line)
+		}
+		data[key] = value
+		text = rest
+	}
+
+	if !hasPair {
+		return nil, fmt.Errorf("invalid logfmt: no key=value pairs: %s", line)
+	}
+	return data, nil
+}
+
+// readLogfmtValue reads a quoted or bare value from the start of text,
+// returning the value and the remaining text
+func readLogfmtValue(text string) (value, rest string, err error) {
+	if !strings.HasPrefix(text, `"`) {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			return text, "", nil
+		}
+		return text[:end], text[end:], nil
+	}
+
+	// Find the closing quote, skipping escaped characters
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("bad quoted value %s", text[:i+1])
+			}
+			return value, text[i+1:], nil
+		}
+	}
+	return "", "", errors.New("unterminated quoted value")
+}
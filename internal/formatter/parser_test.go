@@ -0,0 +1,171 @@
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONParser(t *testing.T) {
+	data, err := JSONParser{}.Parse([]byte(`{"level":"info","count":2}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	expected := map[string]interface{}{"level": "info", "count": float64(2)}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+
+	if _, err := (JSONParser{}).Parse([]byte("not json")); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
+
+func TestLogfmtParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "bare values",
+			input:    "level=info msg=started duration=12ms",
+			expected: map[string]interface{}{"level": "info", "msg": "started", "duration": "12ms"},
+		},
+		{
+			name:     "quoted values with escapes",
+			input:    `level=warn msg="disk \"/\" is 90% full" path="C:\\tmp"`,
+			expected: map[string]interface{}{"level": "warn", "msg": `disk "/" is 90% full`, "path": `C:\tmp`},
+		},
+		{
+			name:     "flags and empty values",
+			input:    "  level=debug cached user= ",
+			expected: map[string]interface{}{"level": "debug", "cached": true, "user": ""},
+		},
+		{
+			name:     "dotted keys",
+			input:    "http.status=200 http.method=GET",
+			expected: map[string]interface{}{"http.status": "200", "http.method": "GET"},
+		},
+		{
+			name:    "plain text",
+			input:   "panic: something went wrong",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			input:   `level=info msg="oops`,
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			input:   "level=info =value",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := LogfmtParser{}.Parse([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestNewInputParser(t *testing.T) {
+	for _, name := range []string{"json", "logfmt", "JSON"} {
+		if _, err := NewInputParser(name); err != nil {
+			t.Errorf("NewInputParser(%q) failed: %v", name, err)
+		}
+	}
+
+	if _, err := NewInputParser("xml"); err == nil {
+		t.Error("Expected an error for an unknown input format")
+	}
+}
+
+// pipeParser is an example custom parser for pipe-delimited lines in the
+// form "level|message"
+func pipeParser(line []byte) (map[string]interface{}, error) {
+	parts := strings.SplitN(string(line), "|", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("expected level|message")
+	}
+	return map[string]interface{}{"level": parts[0], "message": parts[1]}, nil
+}
+
+func TestProcessStreamWithInputParser(t *testing.T) {
+	tests := []struct {
+		name          string
+		parser        InputParser
+		input         string
+		handleNonJSON bool
+		expected      string
+		wantErr       bool
+	}{
+		{
+			name:     "logfmt",
+			parser:   LogfmtParser{},
+			input:    "level=info message=one\nlevel=error message=\"two words\"\n",
+			expected: "[info] one\n[error] two words\n",
+		},
+		{
+			name:     "custom parser",
+			parser:   InputParserFunc(pipeParser),
+			input:    "info|started\nwarn|slow request\n",
+			expected: "[info] started\n[warn] slow request\n",
+		},
+		{
+			name:          "custom parser with unparseable lines",
+			parser:        InputParserFunc(pipeParser),
+			input:         "info|started\ngoroutine 1 [running]\n",
+			handleNonJSON: true,
+			expected:      "[info] started\n\n>>> goroutine 1 [running]\n",
+		},
+		{
+			name:    "custom parser error",
+			parser:  InputParserFunc(pipeParser),
+			input:   "info|started\ngoroutine 1 [running]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter("[{level}] {message}", WithNoColors(true), WithInputParser(tt.parser))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			err = formatter.ProcessStream(strings.NewReader(tt.input), &buf, formatter, nil, tt.handleNonJSON)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
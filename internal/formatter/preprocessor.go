@@ -18,6 +18,10 @@ func DefaultPreProcessTemplateOptions() PreProcessTemplateOptions {
 	}
 }
 
+// templateCommentRe matches a Go template comment, {{/* ... */}}, including
+// the forms with trim markers, {{- /* ... */ -}}
+var templateCommentRe = regexp.MustCompile(`(?s)\{\{(?:- )?/\*.*?\*/(?: -)?\}\}`)
+
 // PreProcessTemplate transforms custom logista syntax into standard go template
 // syntax.
 func PreProcessTemplate(template string, options PreProcessTemplateOptions) string {
@@ -53,8 +57,12 @@ func transformSimpleSyntax(options PreProcessTemplateOptions, template string) s
 	i := 0
 
 	for i < len(template) {
-		if template[i] == '{' &&
-			(i+1 >= len(template) || template[i+1] != '{') {
+		if strings.HasPrefix(template[i:], "{{") {
+			// Copy Go template actions and comments as is
+			end := goActionEnd(template[i:])
+			result.WriteString(template[i : i+end])
+			i += end
+		} else if template[i] == '{' {
 			// Find closing brace
 			start := i
 			openBraces := 1
@@ -73,9 +81,14 @@ func transformSimpleSyntax(options PreProcessTemplateOptions, template string) s
 			}
 
 			if i < len(template) { // Found closing brace
-				// Replace {field} with {{.field}}
-				result.WriteString("{{.")
-				result.WriteString(template[start+1 : i])
+				// Replace {field} with {{.field}}, and {(index . "field")},
+				// from the @symbol syntax, with {{(index . "field")}}
+				field := template[start+1 : i]
+				result.WriteString("{{")
+				if !strings.HasPrefix(field, "(") {
+					result.WriteString(".")
+				}
+				result.WriteString(field)
 				result.WriteString("}}")
 				i++ // Skip past the closing brace
 			} else {
@@ -93,14 +106,87 @@ func transformSimpleSyntax(options PreProcessTemplateOptions, template string) s
 	return result.String()
 }
 
+// goActionEnd returns the length of the Go template action or comment at the
+// start of template, or the length of template if it is unterminated
+func goActionEnd(template string) int {
+	if loc := templateCommentRe.FindStringIndex(template); loc != nil && loc[0] == 0 {
+		return loc[1]
+	}
+	if end := strings.Index(template[2:], "}}"); end >= 0 {
+		return end + 4
+	}
+	return len(template)
+}
+
+// atSymbolRe matches @symbol where:
+// - \B ensures it's not preceded by a word character (prevents matching email@example.com)
+// - symbol consists of letters, numbers, periods, hyphens, and underscores,
+// optionally preceded by a second @ for fields whose names start with @
+var atSymbolRe = regexp.MustCompile(`\B@(@?[a-zA-Z0-9._-]+)`)
+
 // transformAtSymbol transforms @symbol syntax to (index . "symbol")
-// The 'symbol' can contain alphanumeric characters, period, hyphen, and underscore.
+// The 'symbol' can contain alphanumeric characters, period, hyphen, and
+// underscore, and @@symbol refers to a field named "@symbol", such as ECS's
+// @timestamp. Template comments, and string literals inside {field} and
+// {{action}} blocks, are left untouched, so {{index . "@timestamp"}} works too.
 func transformAtSymbol(template string) string {
-	// \B@([a-zA-Z0-9._-]+) matches @symbol where:
-	// - \B ensures it's not preceded by a word character (prevents matching email@example.com)
-	// - symbol consists of letters, numbers, periods, hyphens, and underscores
-	re := regexp.MustCompile(`\B@([a-zA-Z0-9._-]+)`)
+	var result strings.Builder
+	i := 0
+	for i < len(template) {
+		if template[i] != '{' {
+			// Plain text runs up to the next block
+			end := strings.IndexByte(template[i:], '{')
+			if end < 0 {
+				end = len(template) - i
+			}
+			result.WriteString(atSymbolRe.ReplaceAllString(template[i:i+end], `(index . "$1")`))
+			i += end
+			continue
+		}
+
+		var end int
+		if strings.HasPrefix(template[i:], "{{") {
+			end = goActionEnd(template[i:])
+			if loc := templateCommentRe.FindStringIndex(template[i:]); loc != nil && loc[0] == 0 {
+				result.WriteString(template[i : i+end])
+				i += end
+				continue
+			}
+		} else if end = strings.IndexByte(template[i:], '}') + 1; end == 0 {
+			end = len(template) - i
+		}
+		result.WriteString(transformAtSymbolOutsideStrings(template[i : i+end]))
+		i += end
+	}
+	return result.String()
+}
 
-	// Replace all occurrences of @symbol with (index . "symbol")
-	return re.ReplaceAllString(template, `(index . "$1")`)
+// transformAtSymbolOutsideStrings transforms @symbol syntax in a block,
+// skipping quoted and raw string literals
+func transformAtSymbolOutsideStrings(block string) string {
+	var result strings.Builder
+	last := 0
+	for i := 0; i < len(block); i++ {
+		quote := block[i]
+		if quote != '"' && quote != '`' {
+			continue
+		}
+
+		result.WriteString(atSymbolRe.ReplaceAllString(block[last:i], `(index . "$1")`))
+
+		// Find the end of the literal, honoring escapes in quoted strings
+		end := i + 1
+		for end < len(block) && block[end] != quote {
+			if quote == '"' && block[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end = min(end+1, len(block))
+		result.WriteString(block[i:end])
+		last = end
+		i = end - 1
+	}
+	result.WriteString(atSymbolRe.ReplaceAllString(block[last:], `(index . "$1")`))
+	return result.String()
 }
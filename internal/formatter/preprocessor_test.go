@@ -68,6 +68,38 @@ func TestPreProcessTemplate(t *testing.T) {
 			expected: "(index . \"level\"): {{.message}}",
 			options:  DefaultPreProcessTemplateOptions(),
 		},
+		{
+			name:     "mixed simple and Go template syntax",
+			input:    "{level} {{.message}} {{if .user}}{user}{{end}}",
+			expected: "{{.level}} {{.message}} {{if .user}}{{.user}}{{end}}",
+			options:  DefaultPreProcessTemplateOptions(),
+		},
+		{
+			name:     "comment with simple syntax",
+			input:    "{{/* level first */}}{level} {message}",
+			expected: "{{/* level first */}}{{.level}} {{.message}}",
+			options:  DefaultPreProcessTemplateOptions(),
+		},
+		{
+			name:     "comment containing braces and at symbols",
+			input:    "{level} {{/* use {field} or @field.name */}} @message",
+			expected: "{{.level}} {{/* use {field} or @field.name */}} (index . \"message\")",
+			options:  DefaultPreProcessTemplateOptions(),
+		},
+		{
+			name:     "multi-line comment with trim markers",
+			input:    "{{- /* header\n   {level} @ts */ -}}\n{level}",
+			expected: "{{- /* header\n   {level} @ts */ -}}\n{{.level}}",
+			options:  DefaultPreProcessTemplateOptions(),
+		},
+		{
+			name:     "comment with simple syntax disabled",
+			input:    "{{/* @user.name */}}{{@user.name}}",
+			expected: "{{/* @user.name */}}{{(index . \"user.name\")}}",
+			options: PreProcessTemplateOptions{
+				EnableSimpleSyntax: false,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -136,6 +168,70 @@ func TestFormattingWithPreprocessor(t *testing.T) {
 	}
 }
 
+func TestFormattingWithComments(t *testing.T) {
+	data := map[string]interface{}{
+		"level":      "info",
+		"message":    "ready",
+		"user.name":  "alice",
+		"@timestamp": "2024",
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{
+			name:     "simple syntax",
+			format:   "{{/* the level */}}{level}: {message}",
+			expected: "info: ready",
+		},
+		{
+			name:     "at symbol syntax",
+			format:   "{{@user.name}}{{/* from @user.name */}} {{.message}}",
+			expected: "alice ready",
+		},
+		{
+			name:     "interleaved with both",
+			format:   "{level} {{/* {ignored} @ignored */}}{{@user.name}} {message}",
+			expected: "info alice ready",
+		},
+		{
+			name:     "at symbol in simple syntax",
+			format:   "{@user.name} {{/* @user.name */}}{message}",
+			expected: "alice ready",
+		},
+		{
+			name:     "field names starting with an at sign",
+			format:   "{@@timestamp} {{index . \"@timestamp\"}} {{@@timestamp}}",
+			expected: "2024 2024 2024",
+		},
+		{
+			name:     "trim markers",
+			format:   "{level}\n{{- /*\n  joined to the previous line\n*/ -}}\n {message}",
+			expected: "infoready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format, WithNoColors(true))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestFormattingWithAtSymbolSyntax(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -157,6 +253,11 @@ func TestFormattingWithAtSymbolSyntax(t *testing.T) {
 			template: "@level: {grpc_service}.{grpc_method} - @message",
 			expected: "(index . \"level\"): {{.grpc_service}}.{{.grpc_method}} - (index . \"message\")",
 		},
+		{
+			name:     "at symbol inside simple syntax",
+			template: "{@user.name} {@@timestamp | date}",
+			expected: "{{(index . \"user.name\")}} {{(index . \"@timestamp\") | date}}",
+		},
 	}
 
 	for _, tt := range tests {
@@ -228,6 +329,26 @@ func TestTransformAtSymbol(t *testing.T) {
 			input:    "email@example.com",
 			expected: "email@example.com",
 		},
+		{
+			name:     "field names starting with an at sign",
+			input:    "@@timestamp {{@@timestamp | date}}",
+			expected: "(index . \"@timestamp\") {{(index . \"@timestamp\") | date}}",
+		},
+		{
+			name:     "string literals in actions are left alone",
+			input:    "{{index . \"@timestamp\"}} {{printf \"%s \\\"@x\\\"\" @level}} {{`@raw`}}",
+			expected: "{{index . \"@timestamp\"}} {{printf \"%s \\\"@x\\\"\" (index . \"level\")}} {{`@raw`}}",
+		},
+		{
+			name:     "string literals in simple syntax are left alone",
+			input:    "{@message | default \"@none\"}",
+			expected: "{(index . \"message\") | default \"@none\"}",
+		},
+		{
+			name:     "quotes in plain text are not string literals",
+			input:    "msg=\"@message\"",
+			expected: "msg=\"(index . \"message\")\"",
+		},
 	}
 
 	for _, tt := range tests {
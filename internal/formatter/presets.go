@@ -0,0 +1,74 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Built-in format templates, tuned to the field names used by common JSON
+// loggers. Optional fields are wrapped in {{with}} so records without them
+// don't show "<no value>".
+const (
+	// PresetCompact shows just the level and message
+	PresetCompact = `{{.level | level 5}} {{.message}}`
+
+	// PresetVerbose shows the time, level and message, followed by every other
+	// field as key=value
+	PresetVerbose = `{{with .timestamp}}{{. | date | dim}} {{end}}{{.level | level 5}} {{.message}}` +
+		`{{range $key, $value := filter . "timestamp" "level" "message"}} {{$key | dim}}={{$value | pretty}}{{end}}`
+
+	// PresetGCP matches Google Cloud Logging structured logs, which use a
+	// severity such as INFO or WARNING, and log entries exported from Cloud
+	// Logging, which nest the message in jsonPayload or textPayload
+	PresetGCP = `{{with .timestamp}}{{. | date | dim}} {{end}}{{.severity | level 7}} ` +
+		`{{with .jsonPayload}}{{.message}}{{else}}{{with .textPayload}}{{.}}{{else}}{{.message}}{{end}}{{end}}` +
+		`{{with index . "logging.googleapis.com/sourceLocation"}} {{.file | dim}}{{with .line}}{{printf ":%v" . | dim}}{{end}}{{end}}`
+
+	// PresetZap matches the production JSON encoder of go.uber.org/zap, whose
+	// ts field is a Unix timestamp
+	PresetZap = `{{with .ts}}{{. | date | dim}} {{end}}{{.level | level 5}} {{with .logger}}{{. | bold}} {{end}}{{.msg}}` +
+		`{{with .caller}} {{. | dim}}{{end}}{{with .error}} {{"error" | dim}}={{. | color "red"}}{{end}}`
+
+	// PresetLogrus matches the JSONFormatter of github.com/sirupsen/logrus
+	PresetLogrus = `{{with .time}}{{. | date | dim}} {{end}}{{.level | level 5}} {{.msg}}` +
+		`{{range $key, $value := filter . "time" "level" "msg"}} {{$key | dim}}={{$value | pretty}}{{end}}`
+
+	// PresetECS matches Elastic Common Schema logs, as written by the
+	// ecs-logging libraries, which use dotted field names such as log.level
+	PresetECS = `{{with index . "@timestamp"}}{{. | date | dim}} {{end}}{{index . "log.level" | level 5}} {{with index . "log.logger"}}{{. | bold}} {{end}}{{.message}}` +
+		`{{with index . "error.message"}} {{"error" | dim}}={{. | color "red"}}{{end}}`
+
+	// PresetBunyan matches node-bunyan, which records levels as numbers
+	PresetBunyan = `{{with .time}}{{. | date | dim}} {{end}}{{lookup .level 10 "trace" 20 "debug" 30 "info" 40 "warn" 50 "error" 60 "fatal" | level 5}}` +
+		` {{with .name}}{{. | bold}}{{with $.hostname}}{{printf "@%v" . | dim}}{{end}}: {{end}}{{.msg}}`
+)
+
+// presets maps preset names to their templates
+var presets = map[string]string{
+	"compact": PresetCompact,
+	"verbose": PresetVerbose,
+	"gcp":     PresetGCP,
+	"zap":     PresetZap,
+	"logrus":  PresetLogrus,
+	"bunyan":  PresetBunyan,
+	"ecs":     PresetECS,
+}
+
+// Preset returns the template for the named preset
+func Preset(name string) (string, error) {
+	if format, ok := presets[strings.ToLower(name)]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+}
+
+// PresetNames returns the sorted names of the built-in presets
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
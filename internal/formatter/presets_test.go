@@ -0,0 +1,128 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPresets(t *testing.T) {
+	// zap logs Unix timestamps, which date renders in local time
+	ts := time.Date(2024, time.March, 10, 15, 4, 5, 0, time.Local).Unix()
+
+	tests := []struct {
+		preset   string
+		input    string
+		expected string
+	}{
+		{
+			preset:   "compact",
+			input:    `{"timestamp":"2024-03-10T15:04:05Z","level":"info","message":"server started"}`,
+			expected: "INFO  server started",
+		},
+		{
+			preset:   "verbose",
+			input:    `{"timestamp":"2024-03-10T15:04:05Z","level":"warn","message":"slow query","duration_ms":1200,"table":"users"}`,
+			expected: "15:04:05 WARN  slow query duration_ms=1200 table=users",
+		},
+		{
+			preset:   "gcp",
+			input:    `{"timestamp":"2024-03-10T15:04:05Z","severity":"WARNING","message":"quota low","logging.googleapis.com/sourceLocation":{"file":"main.go","line":"42"}}`,
+			expected: "15:04:05 WARNING quota low main.go:42",
+		},
+		{
+			preset:   "gcp",
+			input:    `{"severity":"INFO","message":"no timestamp"}`,
+			expected: "INFO    no timestamp",
+		},
+		{
+			preset:   "gcp",
+			input:    `{"timestamp":"2024-03-10T15:04:05Z","severity":"ERROR","jsonPayload":{"message":"quota exceeded"},"logName":"projects/p/logs/app"}`,
+			expected: "15:04:05 ERROR   quota exceeded",
+		},
+		{
+			preset:   "gcp",
+			input:    `{"severity":"DEFAULT","textPayload":"plain text"}`,
+			expected: "DEFAULT plain text",
+		},
+		{
+			preset:   "zap",
+			input:    fmt.Sprintf(`{"level":"error","ts":%d.123,"logger":"http","caller":"server/handler.go:88","msg":"request failed","error":"timeout"}`, ts),
+			expected: "15:04:05 ERROR http request failed server/handler.go:88 error=timeout",
+		},
+		{
+			preset:   "zap",
+			input:    fmt.Sprintf(`{"level":"info","ts":%d,"msg":"ready"}`, ts),
+			expected: "15:04:05 INFO  ready",
+		},
+		{
+			preset:   "logrus",
+			input:    `{"time":"2024-03-10T15:04:05Z","level":"debug","msg":"cache miss","key":"user:1"}`,
+			expected: "15:04:05 DEBUG cache miss key=user:1",
+		},
+		{
+			preset:   "bunyan",
+			input:    `{"name":"api","hostname":"web-1","pid":123,"level":50,"msg":"crashed","time":"2024-03-10T15:04:05.000Z","v":0}`,
+			expected: "15:04:05 ERROR api@web-1: crashed",
+		},
+		{
+			preset:   "ecs",
+			input:    `{"@timestamp":"2024-03-10T15:04:05.000Z","log.level":"warn","message":"retrying","log.logger":"db","error.message":"connection reset","ecs.version":"1.6.0"}`,
+			expected: "15:04:05 WARN  db retrying error=connection reset",
+		},
+		{
+			preset:   "ecs",
+			input:    `{"@timestamp":"2024-03-10T15:04:05.000Z","log.level":"info","message":"ready"}`,
+			expected: "15:04:05 INFO  ready",
+		},
+		{
+			preset:   "bunyan",
+			input:    `{"level":30,"msg":"hello","time":"2024-03-10T15:04:05.000Z"}`,
+			expected: "15:04:05 INFO  hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			format, err := Preset(tt.preset)
+			if err != nil {
+				t.Fatalf("Preset(%q) failed: %v", tt.preset, err)
+			}
+
+			f, err := NewTemplateFormatter(format, WithNoColors(true), WithPreferredDateFormat(time.TimeOnly))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			data, err := JSONParser{}.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Failed to parse input: %v", err)
+			}
+
+			result, err := f.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPresetNames(t *testing.T) {
+	expected := "bunyan, compact, ecs, gcp, logrus, verbose, zap"
+	if names := strings.Join(PresetNames(), ", "); names != expected {
+		t.Errorf("Expected presets %q, got %q", expected, names)
+	}
+
+	if _, err := Preset("ZAP"); err != nil {
+		t.Errorf("Expected preset names to be case-insensitive, got %v", err)
+	}
+
+	_, err := Preset("nope")
+	if err == nil || !strings.Contains(err.Error(), "available: bunyan, compact") {
+		t.Errorf("Expected an error listing the available presets, got %v", err)
+	}
+}
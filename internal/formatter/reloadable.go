@@ -0,0 +1,59 @@
+package formatter
+
+import "sync"
+
+// ReloadableFormatter is a Formatter whose underlying formatter and skip
+// patterns can be replaced while a stream is being processed, e.g. when a
+// template or config file is edited. It is safe for concurrent use.
+type ReloadableFormatter struct {
+	mu           sync.RWMutex
+	current      Formatter
+	skipPatterns []SkipPattern
+}
+
+// NewReloadableFormatter creates a ReloadableFormatter wrapping the given formatter
+func NewReloadableFormatter(formatter Formatter) *ReloadableFormatter {
+	return &ReloadableFormatter{current: formatter}
+}
+
+// Set replaces the underlying formatter; subsequent records use the new one
+func (r *ReloadableFormatter) Set(formatter Formatter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = formatter
+}
+
+// Format formats the data using the current underlying formatter
+func (r *ReloadableFormatter) Format(data map[string]interface{}) (string, error) {
+	r.mu.RLock()
+	formatter := r.current
+	r.mu.RUnlock()
+
+	return formatter.Format(data)
+}
+
+// SetSkipPatterns replaces the skip patterns consulted by ProcessStream
+func (r *ReloadableFormatter) SetSkipPatterns(skipPatterns []SkipPattern) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipPatterns = skipPatterns
+}
+
+// SkipPatterns returns the current skip patterns
+func (r *ReloadableFormatter) SkipPatterns() []SkipPattern {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.skipPatterns
+}
+
+// ShouldSkip reports whether the record matches the current skip patterns
+func (r *ReloadableFormatter) ShouldSkip(data map[string]interface{}) bool {
+	return shouldSkip(data, r.SkipPatterns())
+}
+
+// recordSkipper is implemented by formatters that carry their own skip
+// patterns, such as ReloadableFormatter
+type recordSkipper interface {
+	SkipPatterns() []SkipPattern
+	ShouldSkip(data map[string]interface{}) bool
+}
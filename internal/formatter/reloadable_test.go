@@ -0,0 +1,111 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReloadableFormatter(t *testing.T) {
+	first, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	second, err := NewTemplateFormatter("{{.message}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	reloadable := NewReloadableFormatter(first)
+	data := map[string]interface{}{"level": "info", "message": "hello"}
+
+	result, err := reloadable.Format(data)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if result != "info" {
+		t.Errorf("Expected 'info', got '%s'", result)
+	}
+
+	reloadable.Set(second)
+
+	result, err = reloadable.Format(data)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if result != "hello" {
+		t.Errorf("Expected 'hello', got '%s'", result)
+	}
+}
+
+func TestProcessStreamWithReloadableFormatter(t *testing.T) {
+	first, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	second, err := NewTemplateFormatter("{{.message}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	reloadable := NewReloadableFormatter(first)
+
+	// Swap the template after the first record has been formatted
+	swapping := &swapOnFormat{Formatter: reloadable, swap: func() { reloadable.Set(second) }}
+
+	input := `{"level":"info","message":"one"}
+{"level":"warn","message":"two"}`
+	var buf bytes.Buffer
+	if err := first.ProcessStream(strings.NewReader(input), &buf, swapping, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "info\ntwo\n"
+	if buf.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buf.String())
+	}
+}
+
+func TestReloadableFormatterSkipPatterns(t *testing.T) {
+	tmplFormatter, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	reloadable := NewReloadableFormatter(tmplFormatter)
+	reloadable.SetSkipPatterns([]SkipPattern{{Field: "level", Value: "debug"}})
+
+	input := `{"level":"info"}
+{"level":"debug"}
+{"level":"error"}`
+	var buf bytes.Buffer
+	if err := tmplFormatter.ProcessStream(strings.NewReader(input), &buf, reloadable, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "info\nerror\n"
+	if buf.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buf.String())
+	}
+
+	reloadable.SetSkipPatterns(nil)
+	if reloadable.ShouldSkip(map[string]interface{}{"level": "debug"}) {
+		t.Error("Expected record not to be skipped after clearing skip patterns")
+	}
+}
+
+// swapOnFormat invokes swap once, after the first record is formatted
+type swapOnFormat struct {
+	Formatter
+	swap    func()
+	swapped bool
+}
+
+func (s *swapOnFormat) Format(data map[string]interface{}) (string, error) {
+	result, err := s.Formatter.Format(data)
+	if !s.swapped {
+		s.swapped = true
+		s.swap()
+	}
+	return result, err
+}
@@ -0,0 +1,86 @@
+package formatter
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// DefaultReplayMaxGap is the longest pause between records when replaying,
+// unless changed with WithReplay
+const DefaultReplayMaxGap = 5 * time.Second
+
+// WithReplay makes ProcessStream pace its output by the records' timestamps,
+// so that a recorded log plays back as if it were live. Before each record it
+// waits for the time between the record's timestamp and the latest one seen
+// so far, divided by speed, so a speed of 2 plays back twice as fast. Waits
+// are capped at maxGap, or at DefaultReplayMaxGap if maxGap isn't positive.
+// Records without a timestamp, and records that are older than one already
+// written, are written immediately. A speed of zero or less disables replay.
+func WithReplay(speed float64, maxGap time.Duration) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		if speed <= 0 {
+			tf.replaySpeed = 0
+			return
+		}
+		if maxGap <= 0 {
+			maxGap = DefaultReplayMaxGap
+		}
+		tf.replaySpeed = speed
+		tf.replayMaxGap = maxGap
+	}
+}
+
+// replayPacer tracks the time of the latest record in a replayed stream
+type replayPacer struct {
+	speed  float64
+	maxGap time.Duration
+	latest time.Time
+}
+
+// newReplayPacer returns a pacer for a stream, or nil if replay is disabled
+func (f *TemplateFormatter) newReplayPacer() *replayPacer {
+	if f.replaySpeed <= 0 {
+		return nil
+	}
+	return &replayPacer{speed: f.replaySpeed, maxGap: f.replayMaxGap}
+}
+
+// wait pauses until a record is due to be written. Output already written to
+// w is flushed first, so earlier records aren't held back while waiting. It
+// returns early with ctx.Err() if ctx is cancelled.
+func (p *replayPacer) wait(ctx context.Context, w io.Writer, data map[string]interface{}) error {
+	if p == nil {
+		return nil
+	}
+
+	t, ok := recordTimestamp(data)
+	if !ok {
+		return nil
+	}
+	if p.latest.IsZero() {
+		p.latest = t
+		return nil
+	}
+	if !t.After(p.latest) {
+		return nil
+	}
+
+	gap := min(time.Duration(float64(t.Sub(p.latest))/p.speed), p.maxGap)
+	p.latest = t
+	if gap <= 0 {
+		return nil
+	}
+
+	if err := flushWriter(w); err != nil {
+		return err
+	}
+	timer := time.NewTimer(gap)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
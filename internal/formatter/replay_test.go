@@ -0,0 +1,81 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessStreamWithReplay(t *testing.T) {
+	// Two seconds between the first records at 100x is 20ms, the hour-long gap
+	// is capped at 30ms, and the rest are written immediately: the record
+	// without a timestamp, the one older than the latest, and the last one,
+	// which shares the latest timestamp
+	input := `{"ts":"2024-01-02T15:00:00Z","msg":"first"}` + "\n" +
+		`{"ts":"2024-01-02T15:00:02Z","msg":"second"}` + "\n" +
+		`{"msg":"untimed"}` + "\n" +
+		`{"ts":"2024-01-02T16:00:02Z","msg":"third"}` + "\n" +
+		`{"ts":"2024-01-02T15:30:00Z","msg":"late"}` + "\n" +
+		`{"ts":"2024-01-02T16:00:02Z","msg":"fourth"}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{msg}", WithNoColors(true), WithReplay(100, 30*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var buf bytes.Buffer
+	start := time.Now()
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if expected := "first\nsecond\nuntimed\nthird\nlate\nfourth\n"; buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+	if elapsed < 50*time.Millisecond {
+		t.Errorf("Expected replay to take at least 50ms, took %v", elapsed)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Expected gaps to be capped, replay took %v", elapsed)
+	}
+}
+
+func TestProcessStreamWithReplayCancelled(t *testing.T) {
+	input := `{"ts":"2024-01-02T15:00:00Z","msg":"first"}` + "\n" +
+		`{"ts":"2024-01-02T15:01:00Z","msg":"second"}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{msg}", WithNoColors(true), WithReplay(1, time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+	start := time.Now()
+	err = formatter.ProcessStreamContext(ctx, strings.NewReader(input), &buf, formatter, nil, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the wait to end when cancelled, took %v", elapsed)
+	}
+	if buf.String() != "first\n" {
+		t.Errorf("Expected 'first\\n', got %q", buf.String())
+	}
+}
+
+func TestWithReplayDisabled(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{msg}", WithReplay(0, time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	if pacer := formatter.newReplayPacer(); pacer != nil {
+		t.Errorf("Expected no pacer, got %+v", pacer)
+	}
+}
@@ -0,0 +1,290 @@
+package formatter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ways a RotatingWriter can split output into files
+const (
+	// SplitHourly starts a new file for each hour, named like 2024-01-02-15.log
+	SplitHourly = "hourly"
+	// SplitDaily starts a new file for each day, named like 2024-01-02.log
+	SplitDaily = "daily"
+	// SplitSizePrefix starts a split by size, such as size=10MB. Files are
+	// numbered, like logista-0001.log.
+	SplitSizePrefix = "size="
+)
+
+// Layouts used to name the files for each time window
+var splitLayouts = map[string]string{
+	SplitHourly: "2006-01-02-15",
+	SplitDaily:  "2006-01-02",
+}
+
+// recordTimeFields are the fields a record's time is read from, in order of
+// preference, when splitting by time or replaying
+var recordTimeFields = []string{"timestamp", "time", "ts", "@timestamp"}
+
+// Units accepted in a size split, as multiples of a byte
+var sizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+}
+
+var sizeRe = regexp.MustCompile(`^(\d+)\s*([a-z]*)$`)
+
+// sizeFileRe matches the names of the files written when splitting by size
+var sizeFileRe = regexp.MustCompile(`^logista-(\d+)\.log$`)
+
+// Split describes when a RotatingWriter starts a new file
+type Split struct {
+	// layout names files by the time window of their records, if set
+	layout string
+	// maxSize is the size at which a new file is started, if set
+	maxSize int64
+}
+
+// ParseSplit parses a split of SplitHourly, SplitDaily, or a size such as
+// size=10MB. Sizes may be given in bytes or with a unit of KB, MB or GB,
+// which are powers of 1024.
+func ParseSplit(spec string) (Split, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if layout, ok := splitLayouts[spec]; ok {
+		return Split{layout: layout}, nil
+	}
+
+	if size, ok := strings.CutPrefix(spec, SplitSizePrefix); ok {
+		m := sizeRe.FindStringSubmatch(strings.TrimSpace(size))
+		if m != nil {
+			n, err := strconv.ParseInt(m[1], 10, 64)
+			unit, known := sizeUnits[m[2]]
+			if err == nil && known && n > 0 {
+				return Split{maxSize: n * unit}, nil
+			}
+		}
+		return Split{}, fmt.Errorf("invalid split size %q: use a number of bytes, KB, MB or GB, e.g. size=10MB", size)
+	}
+
+	return Split{}, fmt.Errorf("unknown split %q: use %s, %s or %sN (e.g. size=10MB)", spec, SplitHourly, SplitDaily, SplitSizePrefix)
+}
+
+// RotatingWriter writes output to a series of files in a directory, starting
+// a new file for each time window or when the current file reaches a size.
+// Time windows are taken from each record's timestamp, time, ts or
+// @timestamp field, falling back to the current time, and files are named
+// after the window in local time. Files are appended to, so records that
+// arrive late, or a restarted stream, never overwrite earlier output.
+//
+// Files are only switched between records, when ProcessStream is about to
+// write the next one, so a record is never split across files. When wrapped
+// in a BufferedWriter, buffered output is flushed to the old file first. It
+// is safe for concurrent use, so it can be closed from a signal handler.
+type RotatingWriter struct {
+	dir   string
+	split Split
+	clock func() time.Time
+
+	mu    sync.Mutex
+	file  *os.File
+	name  string
+	size  int64
+	index int
+}
+
+// RotatingWriterOption configures a RotatingWriter
+type RotatingWriterOption func(*RotatingWriter)
+
+// WithRotationClock sets the function a RotatingWriter gets the current time
+// from, for records without a timestamp. It defaults to time.Now, and is
+// mostly useful for tests.
+func WithRotationClock(clock func() time.Time) RotatingWriterOption {
+	return func(rw *RotatingWriter) {
+		rw.clock = clock
+	}
+}
+
+// NewRotatingWriter creates a RotatingWriter that writes files to dir,
+// creating it if needed. No file is opened until output is written. Close
+// must be called to close the current file.
+func NewRotatingWriter(dir string, split Split, opts ...RotatingWriterOption) *RotatingWriter {
+	if dir == "" {
+		dir = "."
+	}
+	rw := &RotatingWriter{dir: dir, split: split}
+	for _, opt := range opts {
+		opt(rw)
+	}
+	return rw
+}
+
+// Write writes p to the current file, opening the file for the current time
+// window, or the last numbered file, if none is open yet
+func (rw *RotatingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.file == nil {
+		if err := rw.openFirst(rw.now()); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+// StartRecord is called by ProcessStream before each record is written. It
+// switches to the file for the record's time window, or to the next numbered
+// file if the current one has reached the maximum size.
+func (rw *RotatingWriter) StartRecord(data map[string]interface{}) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.split.layout != "" {
+		name := rw.recordTime(data).Local().Format(rw.split.layout) + ".log"
+		if name == rw.name && rw.file != nil {
+			return nil
+		}
+		return rw.open(name)
+	}
+
+	if rw.file == nil {
+		if err := rw.openFirst(rw.now()); err != nil {
+			return err
+		}
+	}
+	if rw.split.maxSize > 0 && rw.size >= rw.split.maxSize {
+		rw.index++
+		return rw.open(sizeFileName(rw.index))
+	}
+	return nil
+}
+
+// Close closes the current file
+func (rw *RotatingWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.closeFile()
+}
+
+// openFirst opens the file for the window containing now, or continues the
+// last numbered file in the directory when splitting by size
+func (rw *RotatingWriter) openFirst(now time.Time) error {
+	if rw.split.layout != "" {
+		return rw.open(now.Local().Format(rw.split.layout) + ".log")
+	}
+
+	index, err := lastSizeFileIndex(rw.dir)
+	if err != nil {
+		return err
+	}
+	rw.index = max(index, 1)
+	return rw.open(sizeFileName(rw.index))
+}
+
+// open closes the current file and opens name for appending
+func (rw *RotatingWriter) open(name string) error {
+	if err := rw.closeFile(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(rw.dir, 0o755); err != nil { //nolint:gosec // Output directories are meant to be readable
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	path := filepath.Join(rw.dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644) //nolint:gosec // Writing to a user-specified output directory is intended
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+
+	rw.file = file
+	rw.name = name
+	rw.size = info.Size()
+	return nil
+}
+
+// closeFile closes the current file, if one is open
+func (rw *RotatingWriter) closeFile() error {
+	if rw.file == nil {
+		return nil
+	}
+	err := rw.file.Close()
+	rw.file = nil
+	return err
+}
+
+// now returns the current time from the writer's clock
+func (rw *RotatingWriter) now() time.Time {
+	if rw.clock != nil {
+		return rw.clock()
+	}
+	return time.Now()
+}
+
+// recordTime returns the time of a record, or the current time if it
+// doesn't have one
+func (rw *RotatingWriter) recordTime(data map[string]interface{}) time.Time {
+	if t, ok := recordTimestamp(data); ok {
+		return t
+	}
+	return rw.now()
+}
+
+// recordTimestamp returns the time of a record from the first of its
+// recordTimeFields that can be parsed
+func recordTimestamp(data map[string]interface{}) (time.Time, bool) {
+	for _, field := range recordTimeFields {
+		if value, ok := data[field]; ok {
+			if t, ok := parseTimestamp(value); ok {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// sizeFileName returns the name of the numbered file used when splitting by size
+func sizeFileName(index int) string {
+	return fmt.Sprintf("logista-%04d.log", index)
+}
+
+// lastSizeFileIndex returns the highest index of the numbered files in dir,
+// or 0 if there are none
+func lastSizeFileIndex(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	last := 0
+	for _, entry := range entries {
+		if m := sizeFileRe.FindStringSubmatch(entry.Name()); m != nil {
+			if index, err := strconv.Atoi(m[1]); err == nil {
+				last = max(last, index)
+			}
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,193 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSplit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Split
+		wantErr bool
+	}{
+		{spec: "hourly", want: Split{layout: "2006-01-02-15"}},
+		{spec: "Daily", want: Split{layout: "2006-01-02"}},
+		{spec: "size=512", want: Split{maxSize: 512}},
+		{spec: "size=10MB", want: Split{maxSize: 10 << 20}},
+		{spec: "size=64k", want: Split{maxSize: 64 << 10}},
+		{spec: "size=1 GB", want: Split{maxSize: 1 << 30}},
+		{spec: "size=0", wantErr: true},
+		{spec: "size=10XB", wantErr: true},
+		{spec: "size=", wantErr: true},
+		{spec: "weekly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSplit(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSplit(%q) = %+v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSplit(%q) failed: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSplit(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// readDir returns the contents of each file in dir, by name
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", entry.Name(), err)
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
+
+func TestProcessStreamWithHourlySplit(t *testing.T) {
+	// Files are named in local time, so build timestamps from a local hour
+	hour := time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local)
+	at := func(d time.Duration) string {
+		return hour.Add(d).Format(time.RFC3339)
+	}
+
+	input := `{"timestamp":"` + at(10*time.Minute) + `","message":"first"}` + "\n" +
+		"panic: not json\n" +
+		`{"timestamp":"` + at(time.Hour-time.Second) + `","message":"second"}` + "\n" +
+		`{"timestamp":"` + at(time.Hour) + `","message":"third"}` + "\n" +
+		`{"timestamp":"` + at(30*time.Minute) + `","message":"late"}` + "\n"
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	split, err := ParseSplit(SplitHourly)
+	if err != nil {
+		t.Fatalf("ParseSplit failed: %v", err)
+	}
+	rotating := NewRotatingWriter(dir, split)
+	out := NewBufferedWriter(rotating, FlushWhenFull)
+
+	formatter, err := NewTemplateFormatter("{message}", WithNoColors(true), WithCompactNonJSON(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	if err := formatter.ProcessStream(strings.NewReader(input), out, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := rotating.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"2024-01-02-15.log": "first\n>>> panic: not json\nsecond\nlate\n",
+		"2024-01-02-16.log": "third\n",
+	}
+	files := readDir(t, dir)
+	if len(files) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, files[name])
+		}
+	}
+}
+
+func TestRotatingWriterClock(t *testing.T) {
+	dir := t.TempDir()
+	split, err := ParseSplit(SplitDaily)
+	if err != nil {
+		t.Fatalf("ParseSplit failed: %v", err)
+	}
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.Local)
+	rw := NewRotatingWriter(dir, split, WithRotationClock(func() time.Time { return now }))
+
+	// Output written before any record goes to the file for the current time
+	if _, err := rw.Write([]byte("header\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	now = now.AddDate(0, 0, 1)
+	if err := rw.StartRecord(map[string]interface{}{"message": "no timestamp"}); err != nil {
+		t.Fatalf("StartRecord failed: %v", err)
+	}
+	if _, err := rw.Write([]byte("record\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"2024-01-02.log": "header\n",
+		"2024-01-03.log": "record\n",
+	}
+	files := readDir(t, dir)
+	if len(files) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, files[name])
+		}
+	}
+}
+
+func TestRotatingWriterSplitsBySize(t *testing.T) {
+	dir := t.TempDir()
+
+	// An earlier run left a full file, which shouldn't be appended to
+	if err := os.WriteFile(filepath.Join(dir, "logista-0002.log"), []byte("0123456789\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	rw := NewRotatingWriter(dir, Split{maxSize: 10})
+	for _, record := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		if err := rw.StartRecord(map[string]interface{}{}); err != nil {
+			t.Fatalf("StartRecord failed: %v", err)
+		}
+		if _, err := rw.Write([]byte(record)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Records aren't split across files, so a file can exceed the size by
+	// up to one record
+	expected := map[string]string{
+		"logista-0002.log": "0123456789\n",
+		"logista-0003.log": "one\ntwo\nthree\n",
+		"logista-0004.log": "four\n",
+	}
+	files := readDir(t, dir)
+	if len(files) != len(expected) {
+		t.Fatalf("Expected files %v, got %v", expected, files)
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, files[name])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+)
+
+// levelSeverities orders the canonical level names, from least to most severe
+var levelSeverities = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    3,
+	"warn":      4,
+	"warning":   4,
+	"error":     5,
+	"critical":  6,
+	"fatal":     6,
+	"alert":     7,
+	"emergency": 8,
+	"panic":     8,
+}
+
+// levelFields are the fields a record's level is read from, in order of
+// preference, after the field set by WithLevelNormalization. GCP records use
+// severity instead of level.
+var levelFields = []string{"level", "severity"}
+
+// recordLevel returns the level of a record from the field set by
+// WithLevelNormalization, or else the first of its levelFields that is set
+func (f *TemplateFormatter) recordLevel(data map[string]interface{}) interface{} {
+	if f.levelField != "" {
+		if level, ok := data[f.levelField]; ok && level != nil {
+			return level
+		}
+	}
+	for _, field := range levelFields {
+		if level, ok := data[field]; ok && level != nil {
+			return level
+		}
+	}
+	return nil
+}
+
+// levelSeverity returns how severe a level is, accepting the same names and
+// numbers as level normalization. The second result is false if the level
+// isn't recognized.
+func levelSeverity(level interface{}) (int, bool) {
+	severity, ok := levelSeverities[canonicalLevel(level)]
+	return severity, ok
+}
+
+// WithLevelWriter makes ProcessStream write records whose level is minLevel
+// or more severe to w instead of the stream's writer, such as errors to
+// stderr. The level is read from the field set by WithLevelNormalization,
+// falling back to the level field, or the severity field of GCP records, and
+// records without a recognized level stay on the stream's writer. Non-JSON
+// lines are written with the record before them, so a stack trace goes to the
+// same place as the error it follows. The order of the records written to
+// each writer is kept.
+func WithLevelWriter(w io.Writer, minLevel string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.levelWriter = w
+		tf.levelWriterMin = minLevel
+	}
+}
+
+// checkLevelWriter validates the level set by WithLevelWriter
+func (f *TemplateFormatter) checkLevelWriter() error {
+	if f.levelWriter == nil {
+		return nil
+	}
+	if _, ok := levelSeverity(f.levelWriterMin); !ok {
+		return fmt.Errorf("unknown level %q (expected a level such as warn or error)", f.levelWriterMin)
+	}
+	return nil
+}
+
+// recordWriter returns the writer a record is written to: the level writer if
+// its level is severe enough, or w
+func (f *TemplateFormatter) recordWriter(w io.Writer, data map[string]interface{}) io.Writer {
+	if f.levelWriter == nil {
+		return w
+	}
+
+	minSeverity, _ := levelSeverity(f.levelWriterMin)
+	if severity, ok := levelSeverity(f.recordLevel(data)); ok && severity >= minSeverity {
+		return f.levelWriter
+	}
+	return w
+}
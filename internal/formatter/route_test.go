@@ -0,0 +1,112 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithLevelWriter(t *testing.T) {
+	input := `{"level":"info","n":1}` + "\n" +
+		`{"level":"ERROR","n":2}` + "\n" +
+		"panic: boom\n" +
+		"\tmain.go:12\n" +
+		`{"level":"warning","n":3}` + "\n" +
+		`{"n":4}` + "\n" +
+		`{"level":"fatal","n":5}` + "\n" +
+		`{"level":"debug","n":6}` + "\n"
+
+	tests := []struct {
+		name     string
+		minLevel string
+		options  []FormatterOption
+		stdout   string
+		stderr   string
+	}{
+		{
+			name:     "errors",
+			minLevel: "error",
+			stdout:   "1\n3\n4\n6\n",
+			stderr:   "2\n>>> panic: boom\n>>> \tmain.go:12\n5\n",
+		},
+		{
+			name:     "warnings",
+			minLevel: "WARN",
+			stdout:   "1\n4\n6\n",
+			stderr:   "2\n>>> panic: boom\n>>> \tmain.go:12\n3\n5\n",
+		},
+		{
+			name:     "fatal only",
+			minLevel: "fatal",
+			options:  []FormatterOption{WithHead(5)},
+			stdout:   "1\n2\n>>> panic: boom\n>>> \tmain.go:12\n3\n4\n",
+			stderr:   "5\n",
+		},
+		{
+			name:     "normalized level field",
+			minLevel: "error",
+			options:  []FormatterOption{WithLevelNormalization("n")},
+			stdout:   "4\n5\n6\n",
+			stderr:   "1\n2\n>>> panic: boom\n>>> \tmain.go:12\n3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			options := append([]FormatterOption{
+				WithNoColors(true), WithCompactNonJSON(true), WithLevelWriter(&stderr, tt.minLevel),
+			}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			if err := formatter.ProcessStream(strings.NewReader(input), &stdout, formatter, nil, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("Expected stdout %q, got %q", tt.stdout, stdout.String())
+			}
+			if stderr.String() != tt.stderr {
+				t.Errorf("Expected stderr %q, got %q", tt.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestLevelWriterSyslogSeverity(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	formatter, err := NewTemplateFormatter("{n}", WithLevelWriter(&stderr, "error"), WithLevelNormalization("severity"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	// Syslog severity 3 is an error, and 4 a warning
+	input := `{"severity":3,"n":1}` + "\n" + `{"severity":4,"n":2}` + "\n"
+	if err := formatter.ProcessStream(strings.NewReader(input), &stdout, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if stdout.String() != "2\n" || stderr.String() != "1\n" {
+		t.Errorf("Expected 2 on stdout and 1 on stderr, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	// GCP records are routed by severity without normalization
+	stdout.Reset()
+	stderr.Reset()
+	formatter, err = NewTemplateFormatter("{n}", WithLevelWriter(&stderr, "error"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	input = `{"severity":"CRITICAL","n":1}` + "\n" + `{"severity":"NOTICE","n":2}` + "\n"
+	if err := formatter.ProcessStream(strings.NewReader(input), &stdout, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if stdout.String() != "2\n" || stderr.String() != "1\n" {
+		t.Errorf("Expected 2 on stdout and 1 on stderr, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	if _, err := NewTemplateFormatter("{n}", WithLevelWriter(&stderr, "loud")); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
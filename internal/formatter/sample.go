@@ -0,0 +1,68 @@
+package formatter
+
+import "math/rand/v2"
+
+// WithSample makes ProcessStream write only every nth record, starting with
+// the first, to thin out a high-volume stream. Records removed by skip
+// patterns aren't counted, so this is one in n of the records that would
+// otherwise be shown. Non-JSON lines are always written. Values of one or
+// less write every record.
+func WithSample(n int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.sampleEvery = n
+	}
+}
+
+// WithSampleRate makes ProcessStream write each record with the given
+// probability, between 0 and 1. Each stream decides with its own random
+// source, seeded with seed so that the same records are sampled every time,
+// or randomly if seed is 0. Like WithSample, it applies to the records left
+// after skip patterns, and non-JSON lines are always written. A rate of 1 or
+// more writes every record.
+func WithSampleRate(rate float64, seed uint64) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.sampleRate = rate
+		tf.sampleSeed = seed
+	}
+}
+
+// sampler decides which records of a stream are written
+type sampler struct {
+	every int
+	rate  float64
+	rand  *rand.Rand
+	seen  int
+}
+
+// newSampler returns a sampler for a stream, or nil if every record is written
+func (f *TemplateFormatter) newSampler() *sampler {
+	if f.sampleEvery <= 1 && (f.sampleRate <= 0 || f.sampleRate >= 1) {
+		return nil
+	}
+
+	s := &sampler{every: max(f.sampleEvery, 1), rate: f.sampleRate}
+	if s.rate > 0 && s.rate < 1 {
+		src := rand.NewPCG(f.sampleSeed, f.sampleSeed)
+		if f.sampleSeed == 0 {
+			src = rand.NewPCG(rand.Uint64(), rand.Uint64())
+		}
+		s.rand = rand.New(src) //nolint:gosec // Sampling doesn't need a secure source
+	}
+	return s
+}
+
+// keep reports whether the next record should be written
+func (s *sampler) keep() bool {
+	if s == nil {
+		return true
+	}
+
+	s.seen++
+	if (s.seen-1)%s.every != 0 {
+		return false
+	}
+	if s.rand != nil {
+		return s.rand.Float64() < s.rate
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedRecords returns n records with an increasing n field, with every
+// record whose number is divisible by skipEvery at debug level
+func numberedRecords(n, skipEvery int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		level := "info"
+		if skipEvery > 0 && i%skipEvery == 0 {
+			level = "debug"
+		}
+		fmt.Fprintf(&b, `{"n":%d,"level":%q}`+"\n", i, level)
+	}
+	return b.String()
+}
+
+func TestProcessStreamWithSample(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:     "every third record",
+			input:    numberedRecords(10, 0),
+			options:  []FormatterOption{WithSample(3)},
+			expected: "1\n4\n7\n10\n",
+		},
+		{
+			name:  "skipped records aren't counted",
+			input: numberedRecords(10, 2),
+			// 1, 3, 5, 7 and 9 are left after skipping
+			options:  []FormatterOption{WithSample(2)},
+			expected: "1\n5\n9\n",
+		},
+		{
+			name:     "non-JSON lines are always written",
+			input:    `{"n":1}` + "\n" + "oops\n" + `{"n":2}` + "\n" + `{"n":3}` + "\n",
+			options:  []FormatterOption{WithSample(2)},
+			expected: "1\n>>> oops\n3\n",
+		},
+		{
+			name:     "sample of one writes every record",
+			input:    numberedRecords(3, 0),
+			options:  []FormatterOption{WithSample(1)},
+			expected: "1\n2\n3\n",
+		},
+		{
+			name:     "head applies to sampled records",
+			input:    numberedRecords(10, 0),
+			options:  []FormatterOption{WithSample(4), WithHead(2)},
+			expected: "1\n5\n",
+		},
+	}
+
+	skip := []SkipPattern{{Field: "level", Value: "debug"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true), WithCompactNonJSON(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(tt.input), &buf, formatter, skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithSampleRate(t *testing.T) {
+	input := numberedRecords(1000, 0)
+
+	process := func(formatter *TemplateFormatter) string {
+		var buf bytes.Buffer
+		if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, false); err != nil {
+			t.Fatalf("ProcessStream failed: %v", err)
+		}
+		return buf.String()
+	}
+	newFormatter := func(seed uint64) *TemplateFormatter {
+		formatter, err := NewTemplateFormatter("{n}", WithSampleRate(0.1, seed))
+		if err != nil {
+			t.Fatalf("Failed to create formatter: %v", err)
+		}
+		return formatter
+	}
+
+	formatter := newFormatter(42)
+	first := process(formatter)
+	if second := process(newFormatter(42)); first != second {
+		t.Error("Expected the same seed to sample the same records")
+	}
+	if second := process(formatter); first != second {
+		t.Error("Expected each stream to sample the same records with a seed")
+	}
+	if n := strings.Count(first, "\n"); n < 50 || n > 150 {
+		t.Errorf("Expected about 100 of 1000 records at a rate of 0.1, got %d", n)
+	}
+}
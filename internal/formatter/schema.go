@@ -0,0 +1,86 @@
+package formatter
+
+import "sync"
+
+// schemaFingerprints lists the fields that identify the records of common
+// loggers, in the order they are checked. Each schema is named after the
+// preset that formats it. bunyan is checked before logrus since its records
+// have the same fields plus a version.
+var schemaFingerprints = []struct {
+	schema string
+	fields []string
+}{
+	{"bunyan", []string{"v", "time", "level", "msg"}},
+	{"ecs", []string{"@timestamp", "log.level", "message"}},
+	{"zap", []string{"ts", "level", "msg"}},
+	{"gcp", []string{"severity", "message"}},
+	{"gcp", []string{"severity", "jsonPayload"}},
+	{"gcp", []string{"severity", "textPayload"}},
+	{"logrus", []string{"time", "level", "msg"}},
+}
+
+// DetectSchema guesses which logger wrote a record from its field names,
+// returning the name of the matching preset, or an empty string if the record
+// doesn't match a known logger
+func DetectSchema(data map[string]interface{}) string {
+	for _, fingerprint := range schemaFingerprints {
+		if hasFields(data, fingerprint.fields) {
+			return fingerprint.schema
+		}
+	}
+	return ""
+}
+
+// hasFields reports whether data has all of the given top-level fields
+func hasFields(data map[string]interface{}, fields []string) bool {
+	for _, field := range fields {
+		if _, ok := data[field]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// AutoFormatter is a Formatter that detects the schema of the first record it
+// formats and, if it is a known logger, switches to a formatter built for that
+// schema. Records are formatted with the fallback formatter if no schema is
+// detected or the schema's formatter can't be built. It is safe for
+// concurrent use.
+type AutoFormatter struct {
+	once     sync.Once
+	fallback Formatter
+	current  Formatter
+	build    func(schema string) (Formatter, error)
+	onDetect func(schema string)
+}
+
+// NewAutoFormatter creates an AutoFormatter that calls build to create a
+// formatter for the detected schema, and onDetect, if not nil, once a schema
+// has been detected
+func NewAutoFormatter(fallback Formatter, build func(schema string) (Formatter, error), onDetect func(schema string)) *AutoFormatter {
+	return &AutoFormatter{
+		fallback: fallback,
+		current:  fallback,
+		build:    build,
+		onDetect: onDetect,
+	}
+}
+
+// Format formats the data, detecting the schema on the first call
+func (a *AutoFormatter) Format(data map[string]interface{}) (string, error) {
+	a.once.Do(func() {
+		schema := DetectSchema(data)
+		if schema == "" {
+			return
+		}
+		formatter, err := a.build(schema)
+		if err != nil {
+			return
+		}
+		a.current = formatter
+		if a.onDetect != nil {
+			a.onDetect(schema)
+		}
+	})
+	return a.current.Format(data)
+}
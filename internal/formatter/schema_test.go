@@ -0,0 +1,139 @@
+package formatter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "zap",
+			input:    `{"level":"info","ts":1710083045.123,"caller":"main.go:12","msg":"ready"}`,
+			expected: "zap",
+		},
+		{
+			name:     "logrus",
+			input:    `{"level":"info","msg":"ready","time":"2024-03-10T15:04:05Z"}`,
+			expected: "logrus",
+		},
+		{
+			name:     "bunyan",
+			input:    `{"name":"api","hostname":"web-1","pid":1,"level":30,"msg":"ready","time":"2024-03-10T15:04:05.000Z","v":0}`,
+			expected: "bunyan",
+		},
+		{
+			name:     "gcp",
+			input:    `{"severity":"INFO","message":"ready","timestamp":"2024-03-10T15:04:05Z"}`,
+			expected: "gcp",
+		},
+		{
+			name:     "ecs",
+			input:    `{"@timestamp":"2024-03-10T15:04:05.000Z","log.level":"info","message":"ready","ecs.version":"1.6.0"}`,
+			expected: "ecs",
+		},
+		{
+			name:     "gcp without timestamp",
+			input:    `{"severity":"INFO","message":"ready"}`,
+			expected: "gcp",
+		},
+		{
+			name:     "gcp log entry",
+			input:    `{"severity":"INFO","jsonPayload":{"message":"ready"},"logName":"projects/p/logs/app"}`,
+			expected: "gcp",
+		},
+		{
+			name:     "default field names",
+			input:    `{"level":"info","message":"ready","timestamp":"2024-03-10T15:04:05Z"}`,
+			expected: "",
+		},
+		{
+			name:     "partial match",
+			input:    `{"ts":1710083045,"msg":"ready"}`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := JSONParser{}.Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Failed to parse input: %v", err)
+			}
+			if schema := DetectSchema(data); schema != tt.expected {
+				t.Errorf("Expected schema %q, got %q", tt.expected, schema)
+			}
+		})
+	}
+}
+
+func TestAutoFormatter(t *testing.T) {
+	fallback, err := NewTemplateFormatter("{{.level}} {{.message}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	build := func(schema string) (Formatter, error) {
+		return NewTemplateFormatter(schema + ": {{.msg}}")
+	}
+
+	t.Run("detected schema", func(t *testing.T) {
+		var detected []string
+		auto := NewAutoFormatter(fallback, build, func(schema string) { detected = append(detected, schema) })
+
+		records := []map[string]interface{}{
+			{"ts": 1.0, "level": "info", "msg": "one"},
+			{"level": "info", "message": "two"},
+		}
+		expected := []string{"zap: one", "zap: <no value>"}
+		for i, record := range records {
+			result, err := auto.Format(record)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != expected[i] {
+				t.Errorf("Expected %q, got %q", expected[i], result)
+			}
+		}
+		if len(detected) != 1 || detected[0] != "zap" {
+			t.Errorf("Expected onDetect to be called once with zap, got %v", detected)
+		}
+	})
+
+	t.Run("no schema detected", func(t *testing.T) {
+		auto := NewAutoFormatter(fallback, build, func(schema string) {
+			t.Errorf("Expected no schema to be detected, got %q", schema)
+		})
+
+		records := []map[string]interface{}{
+			{"level": "info", "message": "one"},
+			{"ts": 1.0, "level": "info", "msg": "two"},
+		}
+		expected := []string{"info one", "info <no value>"}
+		for i, record := range records {
+			result, err := auto.Format(record)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != expected[i] {
+				t.Errorf("Expected %q, got %q", expected[i], result)
+			}
+		}
+	})
+
+	t.Run("build error falls back", func(t *testing.T) {
+		failing := func(string) (Formatter, error) { return nil, errors.New("boom") }
+		auto := NewAutoFormatter(fallback, failing, nil)
+
+		result, err := auto.Format(map[string]interface{}{"ts": 1.0, "level": "warn", "msg": "x", "message": "fallback"})
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		if result != "warn fallback" {
+			t.Errorf("Expected the fallback formatter to be used, got %q", result)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package formatter
+
+import "slices"
+
+// WithSkipFieldCheck makes ProcessStream look for the fields of its skip
+// patterns in the records it reads, to catch typos such as loger=x, which
+// would silently never match. Once it has read the given number of records,
+// or the stream ends first, report is called with the number of records read
+// and the fields that none of them had, in the order of the patterns. Report
+// isn't called if every field was found or no records were read, and is
+// called at most once per stream. Records are checked whether or not they
+// are skipped, after WithKeyNormalization and WithLiftedFields are applied.
+// The skip patterns of a ReloadableFormatter are checked as they were when
+// the stream started.
+func WithSkipFieldCheck(records int, report func(records int, fields []string)) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.skipCheckRecords = records
+		tf.skipCheckReport = report
+	}
+}
+
+// skipFieldCheck tracks which skip pattern fields have appeared in a stream
+type skipFieldCheck struct {
+	limit  int
+	read   int
+	report func(records int, fields []string)
+	// missing holds the fields not seen yet, or nil once all have been seen
+	// or the check has been reported
+	missing []string
+}
+
+// newSkipFieldCheck returns a skipFieldCheck for a stream with the given
+// skip patterns and those of its formatter, or nil if their fields aren't
+// checked
+func (f *TemplateFormatter) newSkipFieldCheck(patterns []SkipPattern, formatter Formatter) *skipFieldCheck {
+	if skipper, ok := formatter.(recordSkipper); ok {
+		patterns = append(slices.Clip(patterns), skipper.SkipPatterns()...)
+	}
+	if f.skipCheckReport == nil || f.skipCheckRecords <= 0 || len(patterns) == 0 {
+		return nil
+	}
+
+	c := &skipFieldCheck{limit: f.skipCheckRecords, report: f.skipCheckReport}
+	seen := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		if !seen[pattern.Field] {
+			seen[pattern.Field] = true
+			c.missing = append(c.missing, pattern.Field)
+		}
+	}
+	return c
+}
+
+// observe notes the fields of a record, reporting the fields still missing
+// once enough records have been read
+func (c *skipFieldCheck) observe(data map[string]interface{}) {
+	if c == nil || c.missing == nil {
+		return
+	}
+
+	missing := c.missing[:0]
+	for _, field := range c.missing {
+		if _, ok := lookupField(data, field); !ok {
+			missing = append(missing, field)
+		}
+	}
+	c.missing = missing
+	if len(c.missing) == 0 {
+		c.missing = nil
+		return
+	}
+
+	c.read++
+	if c.read >= c.limit {
+		c.finish()
+	}
+}
+
+// finish reports the fields still missing, if any records were read
+func (c *skipFieldCheck) finish() {
+	if c == nil || c.missing == nil {
+		return
+	}
+	if c.read > 0 {
+		c.report(c.read, c.missing)
+	}
+	c.missing = nil
+}
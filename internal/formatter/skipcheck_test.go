@@ -0,0 +1,119 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSkipFieldCheck(t *testing.T) {
+	input := `{"n":1,"logger":"db","level":"info"}` + "\n" +
+		"not json\n" +
+		`{"n":2,"logger":"http","level":"debug","ctx":{"user":"ann"}}` + "\n" +
+		`{"n":3,"logger":"http","level":"info"}` + "\n"
+
+	tests := []struct {
+		name    string
+		records int
+		skip    []SkipPattern
+		input   string
+		read    int
+		fields  []string
+	}{
+		{
+			name:    "typo",
+			records: 10,
+			skip:    []SkipPattern{{Field: "loger", Value: "db"}, {Field: "level", Value: "debug"}},
+			read:    3,
+			fields:  []string{"loger"},
+		},
+		{
+			name:    "reported after the first records",
+			records: 1,
+			skip:    []SkipPattern{{Field: "ctx.user", Value: "ann"}, {Field: "svc", Value: "a"}, {Field: "svc", Value: "b"}},
+			read:    1,
+			fields:  []string{"ctx.user", "svc"},
+		},
+		{
+			name:    "nested field found later",
+			records: 10,
+			skip:    []SkipPattern{{Field: "ctx.user", Value: "ann"}},
+		},
+		{
+			name:    "all fields found",
+			records: 10,
+			skip:    []SkipPattern{{Field: "logger", Value: "db"}},
+		},
+		{
+			name:    "no records",
+			records: 10,
+			skip:    []SkipPattern{{Field: "loger", Value: "db"}},
+			input:   "not json\n",
+		},
+		{
+			name:    "disabled",
+			records: 0,
+			skip:    []SkipPattern{{Field: "loger", Value: "db"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var read int
+			var fields []string
+			formatter, err := NewTemplateFormatter("{n}", WithNoColors(true),
+				WithSkipFieldCheck(tt.records, func(records int, missing []string) {
+					calls++
+					read, fields = records, missing
+				}))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			in := input
+			if tt.input != "" {
+				in = tt.input
+			}
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(in), &buf, formatter, tt.skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+
+			if tt.fields == nil {
+				if calls != 0 {
+					t.Errorf("Expected no report, got %v after %d records", fields, read)
+				}
+				return
+			}
+			if calls != 1 {
+				t.Fatalf("Expected one report, got %d", calls)
+			}
+			if read != tt.read || !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("Expected %v after %d records, got %v after %d", tt.fields, tt.read, fields, read)
+			}
+		})
+	}
+}
+
+func TestSkipFieldCheckReloadable(t *testing.T) {
+	var fields []string
+	tmplFormatter, err := NewTemplateFormatter("{n}", WithNoColors(true),
+		WithSkipFieldCheck(100, func(records int, missing []string) { fields = missing }))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	reloadable := NewReloadableFormatter(tmplFormatter)
+	reloadable.SetSkipPatterns([]SkipPattern{{Field: "lvl", Value: "debug"}})
+
+	var buf bytes.Buffer
+	input := `{"n":1,"level":"debug"}` + "\n"
+	skip := []SkipPattern{{Field: "n", Value: "2"}}
+	if err := tmplFormatter.ProcessStream(strings.NewReader(input), &buf, reloadable, skip, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if expected := []string{"lvl"}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+}
@@ -0,0 +1,89 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Formatters that can be applied to nested fields by WithSmartFieldFormatting
+const (
+	// SmartFormatDate renders values like the date function
+	SmartFormatDate = "date"
+	// SmartFormatDuration renders values like the duration function, treating
+	// numbers as milliseconds
+	SmartFormatDuration = "duration"
+)
+
+// smartField applies a formatter to fields whose names end with suffix
+type smartField struct {
+	suffix string
+	format string
+}
+
+// WithSmartFieldFormatting makes pretty, table, tree and list render fields
+// of nested maps with a formatter chosen by the end of their key, so that
+// timestamps and durations inside context objects are readable. rules maps
+// key suffixes to SmartFormatDate or SmartFormatDuration, for example
+// {"_ms": "duration", "_at": "date"}. When several suffixes match a key the
+// longest wins. Only scalar values are formatted, and values the formatter
+// can't parse are rendered as usual. Later calls add to earlier rules.
+func WithSmartFieldFormatting(rules map[string]string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		for suffix, format := range rules {
+			tf.smartFields = append(tf.smartFields, smartField{suffix: suffix, format: format})
+		}
+	}
+}
+
+// prepareSmartFields validates the smart field rules and orders them so the
+// longest suffix is tried first, replacing earlier rules for the same suffix
+func prepareSmartFields(fields []smartField) ([]smartField, error) {
+	bySuffix := make(map[string]smartField, len(fields))
+	for _, field := range fields {
+		if field.suffix == "" {
+			return nil, fmt.Errorf("smart field format %q needs a key suffix", field.format)
+		}
+		switch field.format {
+		case SmartFormatDate, SmartFormatDuration:
+		default:
+			return nil, fmt.Errorf("unknown smart field format %q for %q (expected %s or %s)",
+				field.format, field.suffix, SmartFormatDate, SmartFormatDuration)
+		}
+		bySuffix[field.suffix] = field
+	}
+
+	result := make([]smartField, 0, len(bySuffix))
+	for _, field := range bySuffix {
+		result = append(result, field)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].suffix) != len(result[j].suffix) {
+			return len(result[i].suffix) > len(result[j].suffix)
+		}
+		return result[i].suffix < result[j].suffix
+	})
+	return result, nil
+}
+
+// prettyField pretty-prints the value of a map field found depth levels deep,
+// using the smart formatter for the key if one matches
+func (f *TemplateFormatter) prettyField(key string, value interface{}, depth, maxDepth int) string {
+	switch value.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return f.prettyValue(value, depth, maxDepth)
+	}
+
+	for _, field := range f.smartFields {
+		if !strings.HasSuffix(key, field.suffix) {
+			continue
+		}
+		switch field.format {
+		case SmartFormatDate:
+			return f.dateFunc(value)
+		case SmartFormatDuration:
+			return f.durationFunc(value)
+		}
+	}
+	return f.prettyValue(value, depth, maxDepth)
+}
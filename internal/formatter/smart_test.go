@@ -0,0 +1,97 @@
+package formatter
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSmartFieldFormatting(t *testing.T) {
+	// Unix timestamps are shown in local time
+	started := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	startedAt := started.Local().Format("15:04:05")
+
+	var data map[string]interface{}
+	record := `{"context":{"latency_ms":1500,"started_at":` + strconv.FormatInt(started.Unix(), 10) + `,` +
+		`"retry":{"backoff_ms":250,"count":2},"end_time":"2024-01-02T15:04:06Z","tags_ms":[1,2],"name_at":"home"}}`
+	decoder := json.NewDecoder(strings.NewReader(record))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+
+	rules := map[string]string{
+		"_ms":   SmartFormatDuration,
+		"_at":   SmartFormatDate,
+		"_time": SmartFormatDate,
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		rules    map[string]string
+		expected string
+	}{
+		{
+			name:     "disabled by default",
+			format:   "{context | pretty}",
+			expected: "{end_time=2024-01-02T15:04:06Z, latency_ms=1500, name_at=home, retry={backoff_ms=250, count=2}, started_at=1704207845, tags_ms=[1, 2]}",
+		},
+		{
+			name:     "pretty",
+			format:   "{context | pretty}",
+			rules:    rules,
+			expected: "{end_time=15:04:06, latency_ms=1.50s, name_at=home, retry={backoff_ms=250.00ms, count=2}, started_at=" + startedAt + ", tags_ms=[1, 2]}",
+		},
+		{
+			name:   "table",
+			format: "{{table 12 .context}}",
+			rules:  rules,
+			expected: "  end_time    15:04:06\n" +
+				"  latency_ms  1.50s\n" +
+				"  name_at     home\n" +
+				"  retry       {backoff_ms=250.00ms, count=2}\n" +
+				"  started_at  " + startedAt + "\n" +
+				"  tags_ms     [1, 2]",
+		},
+		{
+			name:     "longest suffix wins",
+			format:   "{context | pretty}",
+			rules:    map[string]string{"_ms": SmartFormatDuration, "latency_ms": SmartFormatDate, "_at": SmartFormatDate},
+			expected: "{end_time=2024-01-02T15:04:06Z, latency_ms=" + time.Unix(1500, 0).Format("15:04:05") + ", name_at=home, retry={backoff_ms=250.00ms, count=2}, started_at=" + startedAt + ", tags_ms=[1, 2]}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.format,
+				WithNoColors(true),
+				WithPreferredDateFormat("15:04:05"),
+				WithSmartFieldFormatting(tt.rules))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rules := range []map[string]string{
+			{"_ms": "seconds"},
+			{"": SmartFormatDate},
+		} {
+			if _, err := NewTemplateFormatter("{message}", WithSmartFieldFormatting(rules)); err == nil {
+				t.Errorf("Expected an error for %v", rules)
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// strftimeLayouts maps strftime conversion characters to the equivalent Go
+// layout
+var strftimeLayouts = map[byte]string{
+	'a': "Mon",
+	'A': "Monday",
+	'b': "Jan",
+	'B': "January",
+	'c': time.ANSIC,
+	'd': "02",
+	'D': "01/02/06",
+	'e': "_2",
+	'F': "2006-01-02",
+	'h': "Jan",
+	'H': "15",
+	'I': "03",
+	'm': "01",
+	'M': "04",
+	'p': "PM",
+	'R': "15:04",
+	'S': "05",
+	'T': "15:04:05",
+	'y': "06",
+	'Y': "2006",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+// isStrftimeLayout reports whether a date format uses strftime-style
+// conversions (e.g. "%Y-%m-%d") rather than Go's reference time layout
+func isStrftimeLayout(format string) bool {
+	return strings.Contains(format, "%")
+}
+
+// formatTime formats t using either a strftime-style format or a Go layout
+func formatTime(t time.Time, format string) string {
+	if isStrftimeLayout(format) {
+		return strftime(t, format)
+	}
+	return t.Format(format)
+}
+
+// strftime formats t according to a strftime-style format string. Each
+// conversion is formatted separately, so literal text is never mistaken for
+// part of a Go layout. Conversions with no Go layout equivalent, such as ISO
+// week numbers (%V) and day of the year (%j), are computed directly, and
+// unknown conversions are written unchanged.
+func strftime(t time.Time, format string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			builder.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		conv := format[i]
+		if layout, ok := strftimeLayouts[conv]; ok {
+			builder.WriteString(t.Format(layout))
+			continue
+		}
+
+		switch conv {
+		case 'f':
+			// Microseconds, as in Python
+			builder.WriteString(zeroPad(t.Nanosecond()/1e3, 6))
+		case 'L':
+			// Milliseconds, as in Ruby
+			builder.WriteString(zeroPad(t.Nanosecond()/1e6, 3))
+		case 'N':
+			builder.WriteString(zeroPad(t.Nanosecond(), 9))
+		case 'j':
+			builder.WriteString(zeroPad(t.YearDay(), 3))
+		case 'u':
+			// Day of the week, Monday is 1
+			builder.WriteString(strconv.Itoa((int(t.Weekday())+6)%7 + 1))
+		case 'w':
+			// Day of the week, Sunday is 0
+			builder.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'U':
+			// Week of the year, starting on the first Sunday
+			builder.WriteString(zeroPad((t.YearDay()+6-int(t.Weekday()))/7, 2))
+		case 'W':
+			// Week of the year, starting on the first Monday
+			builder.WriteString(zeroPad((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2))
+		case 'V':
+			_, week := t.ISOWeek()
+			builder.WriteString(zeroPad(week, 2))
+		case 'G':
+			year, _ := t.ISOWeek()
+			builder.WriteString(strconv.Itoa(year))
+		case 'g':
+			year, _ := t.ISOWeek()
+			builder.WriteString(zeroPad(year%100, 2))
+		case 's':
+			builder.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'n':
+			builder.WriteByte('\n')
+		case 't':
+			builder.WriteByte('\t')
+		case '%':
+			builder.WriteByte('%')
+		default:
+			builder.WriteByte('%')
+			builder.WriteByte(conv)
+		}
+	}
+
+	return builder.String()
+}
+
+// zeroPad formats n with leading zeros to the given width
+func zeroPad(n, width int) string {
+	s := strconv.Itoa(n)
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
@@ -0,0 +1,82 @@
+package formatter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStrftime(t *testing.T) {
+	// Tuesday, day 70 of a leap year, ISO week 11
+	ts := time.Date(2024, time.March, 10, 15, 4, 5, 123456789, time.UTC).AddDate(0, 0, 2)
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"%Y-%m-%d %H:%M:%S", "2024-03-12 15:04:05"},
+		{"%F %T", "2024-03-12 15:04:05"},
+		{"%y/%m/%d", "24/03/12"},
+		{"%D %R", "03/12/24 15:04"},
+		{"%I:%M %p", "03:04 PM"},
+		{"%a %A %b %B %h", "Tue Tuesday Mar March Mar"},
+		{"[%e]", "[12]"},
+		{"%H:%M:%S.%L", "15:04:05.123"},
+		{"%S.%f", "05.123456"},
+		{"%S.%N", "05.123456789"},
+		{"%z %Z", "+0000 UTC"},
+		{"day %j", "day 072"},
+		{"%G-W%V-%u", "2024-W11-2"},
+		{"%g", "24"},
+		{"%w %U %W", "2 10 11"},
+		{"%s", "1710255845"},
+		{"100%% at %H%n%t", "100% at 15\n\t"},
+		{"Jan 2 %Y", "Jan 2 2024"},
+		{"%Q unknown", "%Q unknown"},
+		{"trailing %", "trailing %"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			result := formatTime(ts, tt.format)
+			if result != tt.expected {
+				t.Errorf("formatTime(%q) = %q, want %q", tt.format, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStrftimeISOWeekYearBoundary(t *testing.T) {
+	// December 30, 2024 is in week 1 of ISO year 2025
+	ts := time.Date(2024, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if result := strftime(ts, "%G-W%V %j"); result != "2025-W01 365" {
+		t.Errorf("Expected '2025-W01 365', got %q", result)
+	}
+}
+
+func TestDateFormatStyles(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{ts | date}", WithPreferredDateFormat("Jan 2 15:04"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	result, err := formatter.Format(map[string]interface{}{"ts": "2024-03-10T15:04:05Z"})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if result != "Mar 10 15:04" {
+		t.Errorf("Expected 'Mar 10 15:04', got %q", result)
+	}
+
+	formatter, err = NewTemplateFormatter("{ts | date}", WithPreferredDateFormat("%d.%m.%Y %H:%M"))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	result, err = formatter.Format(map[string]interface{}{"ts": "2024-03-10T15:04:05Z"})
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if result != "10.03.2024 15:04" {
+		t.Errorf("Expected '10.03.2024 15:04', got %q", result)
+	}
+}
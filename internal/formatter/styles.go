@@ -0,0 +1,74 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithStyleAliases defines names for combinations of colors and styles, so
+// a template can use {level | color "warn"} instead of repeating bold and
+// yellow. Each alias maps to the colors and styles it combines, given as for
+// the color function, and may include other aliases. Aliases take precedence
+// over built-in colors of the same name.
+func WithStyleAliases(aliases map[string][]string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.styleAliases = aliases
+	}
+}
+
+// resolveStyleAliases returns the ANSI SGR codes of each style alias, with
+// colors limited to the given color depth. It is an error for an alias to
+// include an unknown color or, directly or indirectly, itself.
+func resolveStyleAliases(aliases map[string][]string, depth string) (map[string]string, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	codes := make(map[string]string, len(aliases))
+	var resolve func(name string, path []string) (string, error)
+	resolve = func(name string, path []string) (string, error) {
+		if code, ok := codes[name]; ok {
+			return code, nil
+		}
+		for i, seen := range path {
+			if seen == name {
+				return "", fmt.Errorf("style alias %s includes itself (%s)",
+					name, strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+		path = append(path, name)
+
+		parts := make([]string, 0, len(aliases[name]))
+		for _, style := range aliases[name] {
+			if _, isAlias := aliases[style]; isAlias {
+				code, err := resolve(style, path)
+				if err != nil {
+					return "", err
+				}
+				parts = append(parts, code)
+			} else if code, ok := colorCode(style, depth); ok {
+				parts = append(parts, code)
+			} else {
+				return "", fmt.Errorf("style alias %s: unknown color %q", name, style)
+			}
+		}
+
+		codes[name] = strings.Join(parts, ";")
+		return codes[name], nil
+	}
+
+	// Resolve aliases in a fixed order so errors are reported consistently
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := resolve(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
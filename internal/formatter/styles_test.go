@@ -0,0 +1,82 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStyleAliases(t *testing.T) {
+	aliases := map[string][]string{
+		"warn":   {"bold", "yellow"},
+		"alert":  {"warn", "underline"},
+		"brand":  {"#ff8800"},
+		"banner": {"brand", "bg-black"},
+		"red":    {"bold", "brightred"},
+		"plain":  {},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		depth    string
+		expected string
+	}{
+		{name: "alias", template: `{msg | color "warn"}`, expected: "\033[1;33mhi\033[0m"},
+		{name: "alias of an alias", template: `{msg | color "alert"}`, expected: "\033[1;33;4mhi\033[0m"},
+		{name: "nested hex colors", template: `{msg | color "banner"}`, expected: "\033[38;2;255;136;0;40mhi\033[0m"},
+		{name: "limited color depth", template: `{msg | color "brand"}`, depth: ColorDepth256, expected: "\033[38;5;208mhi\033[0m"},
+		{name: "overrides a built-in color", template: `{msg | color "red"}`, expected: "\033[1;91mhi\033[0m"},
+		{name: "empty alias", template: `{msg | color "plain"}`, expected: "hi"},
+		{name: "built-in colors still work", template: `{msg | color "green"}`, expected: "\033[32mhi\033[0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewTemplateFormatter(tt.template, WithStyleAliases(aliases), WithColorDepth(tt.depth))
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := formatter.Format(map[string]interface{}{"msg": "hi"})
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestStyleAliasErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		aliases  map[string][]string
+		contains string
+	}{
+		{
+			name:     "cycle",
+			aliases:  map[string][]string{"a": {"b"}, "b": {"c", "bold"}, "c": {"a"}},
+			contains: "style alias a includes itself (a -> b -> c -> a)",
+		},
+		{
+			name:     "includes itself",
+			aliases:  map[string][]string{"warn": {"bold", "warn"}},
+			contains: "style alias warn includes itself (warn -> warn)",
+		},
+		{
+			name:     "unknown color",
+			aliases:  map[string][]string{"warn": {"bold", "mauve"}},
+			contains: `style alias warn: unknown color "mauve"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTemplateFormatter("{msg}", WithStyleAliases(tt.aliases))
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected an error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+}
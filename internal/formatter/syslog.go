@@ -0,0 +1,186 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog facility names, indexed by facility code
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Syslog severity names and the equivalent log levels, indexed by severity code
+var (
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	syslogLevels     = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+)
+
+// Patterns for the two syslog formats
+var (
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]
+	rfc5424Re = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\S+) (\S+) (.*)$`)
+	// [<PRI>]Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG, where PRI is often
+	// omitted in log files
+	rfc3164Re = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^\s:\[]+)(?:\[([^\]]*)\])?: ?(.*)$`)
+	// A single SD-PARAM within a structured data element
+	sdParamRe = regexp.MustCompile(`^ ([^\s=\]"]+)="((?:[^"\\\]]|\\.)*)"`)
+)
+
+// SyslogParser parses syslog lines in either the IETF (RFC 5424) or BSD
+// (RFC 3164) format. Records include the facility and severity names, and a
+// level derived from the severity so colorByLevel works as expected.
+type SyslogParser struct {
+	// now returns the current time, used to infer the year of BSD timestamps
+	now func() time.Time
+}
+
+// Parse decodes a syslog line, returning an error if it matches neither format
+func (p SyslogParser) Parse(line []byte) (map[string]interface{}, error) {
+	text := strings.TrimRight(string(line), "\r")
+
+	if m := rfc5424Re.FindStringSubmatch(text); m != nil {
+		return parseRFC5424(m)
+	}
+	if m := rfc3164Re.FindStringSubmatch(text); m != nil {
+		return p.parseRFC3164(m)
+	}
+	return nil, fmt.Errorf("invalid syslog: %s", line)
+}
+
+// parseRFC5424 builds a record from the submatches of rfc5424Re
+func parseRFC5424(m []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if err := setSyslogPriority(data, m[1]); err != nil {
+		return nil, err
+	}
+
+	// The nil value "-" marks fields that aren't present
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"timestamp", m[2]},
+		{"hostname", m[3]},
+		{"app_name", m[4]},
+		{"pid", m[5]},
+		{"msgid", m[6]},
+	}
+	for _, field := range fields {
+		if field.value != "-" {
+			data[field.key] = field.value
+		}
+	}
+
+	structured, message, err := parseStructuredData(m[7])
+	if err != nil {
+		return nil, err
+	}
+	if structured != nil {
+		data["structured_data"] = structured
+	}
+	data["message"] = strings.TrimPrefix(message, "\ufeff")
+
+	return data, nil
+}
+
+// parseRFC3164 builds a record from the submatches of rfc3164Re
+func (p SyslogParser) parseRFC3164(m []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if m[1] != "" {
+		if err := setSyslogPriority(data, m[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	// BSD timestamps have no year, so assume the most recent matching date
+	timestamp, err := time.ParseInLocation(time.Stamp, m[2], time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog timestamp %q: %w", m[2], err)
+	}
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+	timestamp = timestamp.AddDate(now.Year(), 0, 0)
+	if timestamp.After(now.Add(24 * time.Hour)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+
+	data["timestamp"] = timestamp.Format(time.RFC3339)
+	data["hostname"] = m[3]
+	data["app_name"] = m[4]
+	if m[5] != "" {
+		data["pid"] = m[5]
+	}
+	data["message"] = m[6]
+
+	return data, nil
+}
+
+// setSyslogPriority decodes a PRI value into priority, facility, severity and
+// level fields
+func setSyslogPriority(data map[string]interface{}, pri string) error {
+	priority, err := strconv.Atoi(pri)
+	if err != nil || priority > 191 {
+		return fmt.Errorf("invalid syslog priority %q", pri)
+	}
+
+	facility, severity := priority/8, priority%8
+	data["priority"] = priority
+	data["facility"] = syslogFacilities[facility]
+	data["severity"] = syslogSeverities[severity]
+	data["level"] = syslogLevels[severity]
+	return nil
+}
+
+// parseStructuredData parses the STRUCTURED-DATA part of an RFC 5424 message
+// into a map of element IDs to their parameters, returning the remaining text
+// as the message. It returns a nil map if the structured data is "-".
+func parseStructuredData(text string) (map[string]interface{}, string, error) {
+	if text == "-" || strings.HasPrefix(text, "- ") {
+		return nil, strings.TrimPrefix(text[1:], " "), nil
+	}
+
+	elements := make(map[string]interface{})
+	for strings.HasPrefix(text, "[") {
+		end := strings.IndexAny(text, " ]")
+		if end < 0 {
+			return nil, "", errors.New("invalid syslog: unterminated structured data")
+		}
+		id := text[1:end]
+		text = text[end:]
+
+		params := make(map[string]interface{})
+		for {
+			m := sdParamRe.FindStringSubmatch(text)
+			if m == nil {
+				break
+			}
+			params[m[1]] = unescapeSDValue(m[2])
+			text = text[len(m[0]):]
+		}
+
+		if !strings.HasPrefix(text, "]") {
+			return nil, "", fmt.Errorf("invalid syslog: malformed structured data element %q", id)
+		}
+		text = text[1:]
+		elements[id] = params
+	}
+
+	if len(elements) == 0 {
+		return nil, "", errors.New("invalid syslog: missing structured data")
+	}
+	return elements, strings.TrimPrefix(text, " "), nil
+}
+
+// unescapeSDValue removes the backslash escapes allowed in SD-PARAM values
+func unescapeSDValue(value string) string {
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\]`, `]`).Replace(value)
+}
@@ -0,0 +1,198 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogParser(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.Local)
+	parser := SyslogParser{now: func() time.Time { return now }}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:  "RFC 5424 with structured data",
+			input: `<165>1 2024-03-10T15:04:05.003Z web1.example.com api 8710 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][meta seq="1"] An application event`,
+			expected: map[string]interface{}{
+				"priority":  165,
+				"facility":  "local4",
+				"severity":  "notice",
+				"level":     "notice",
+				"timestamp": "2024-03-10T15:04:05.003Z",
+				"hostname":  "web1.example.com",
+				"app_name":  "api",
+				"pid":       "8710",
+				"msgid":     "ID47",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": `App"lication`},
+					"meta":              map[string]interface{}{"seq": "1"},
+				},
+				"message": "An application event",
+			},
+		},
+		{
+			name:  "RFC 5424 with nil values",
+			input: "<34>1 2024-03-10T15:04:05Z mymachine su - - - \ufeff'su root' failed",
+			expected: map[string]interface{}{
+				"priority":  34,
+				"facility":  "auth",
+				"severity":  "crit",
+				"level":     "critical",
+				"timestamp": "2024-03-10T15:04:05Z",
+				"hostname":  "mymachine",
+				"app_name":  "su",
+				"message":   "'su root' failed",
+			},
+		},
+		{
+			name:  "RFC 5424 without message",
+			input: "<14>1 2024-03-10T15:04:05Z host app - - -",
+			expected: map[string]interface{}{
+				"priority":  14,
+				"facility":  "user",
+				"severity":  "info",
+				"level":     "info",
+				"timestamp": "2024-03-10T15:04:05Z",
+				"hostname":  "host",
+				"app_name":  "app",
+				"message":   "",
+			},
+		},
+		{
+			name:  "RFC 3164 with priority and pid",
+			input: "<35>Mar 10 15:04:05 mymachine sshd[1234]: Failed password for root",
+			expected: map[string]interface{}{
+				"priority":  35,
+				"facility":  "auth",
+				"severity":  "err",
+				"level":     "error",
+				"timestamp": time.Date(2024, time.March, 10, 15, 4, 5, 0, time.Local).Format(time.RFC3339),
+				"hostname":  "mymachine",
+				"app_name":  "sshd",
+				"pid":       "1234",
+				"message":   "Failed password for root",
+			},
+		},
+		{
+			name:  "RFC 3164 from a log file without priority",
+			input: "Mar  1 08:00:00 host kernel: eth0: link up",
+			expected: map[string]interface{}{
+				"timestamp": time.Date(2024, time.March, 1, 8, 0, 0, 0, time.Local).Format(time.RFC3339),
+				"hostname":  "host",
+				"app_name":  "kernel",
+				"message":   "eth0: link up",
+			},
+		},
+		{
+			name:  "RFC 3164 date in the future is from last year",
+			input: "Dec 31 23:59:59 host cron[1]: job done",
+			expected: map[string]interface{}{
+				"timestamp": time.Date(2023, time.December, 31, 23, 59, 59, 0, time.Local).Format(time.RFC3339),
+				"hostname":  "host",
+				"app_name":  "cron",
+				"pid":       "1",
+				"message":   "job done",
+			},
+		},
+		{
+			name:    "plain text",
+			input:   "goroutine 1 [running]:",
+			wantErr: true,
+		},
+		{
+			name:    "invalid priority",
+			input:   "<999>1 2024-03-10T15:04:05Z host app - - - msg",
+			wantErr: true,
+		},
+		{
+			name:    "malformed structured data",
+			input:   `<14>1 2024-03-10T15:04:05Z host app - - [meta seq=1] msg`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parser.Parse([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %v", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithSyslog(t *testing.T) {
+	parser, err := NewInputParser(InputFormatSyslog)
+	if err != nil {
+		t.Fatalf("NewInputParser failed: %v", err)
+	}
+
+	formatter, err := NewTemplateFormatter("{level} {app_name}: {message}", WithNoColors(true), WithInputParser(parser))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	input := "<11>1 2024-03-10T15:04:05Z host api - - - disk full\n" +
+		"not a syslog line\n" +
+		"<30>Mar 10 15:04:05 host systemd[1]: Started job\n"
+
+	var buf bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, nil, true); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	expected := "error api: disk full\n\n>>> not a syslog line\n\ninfo systemd: Started job\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestSyslogUsesFormatterClock(t *testing.T) {
+	parser, err := NewInputParser(InputFormatSyslog)
+	if err != nil {
+		t.Fatalf("NewInputParser failed: %v", err)
+	}
+
+	tests := []struct {
+		now      time.Time
+		expected string
+	}{
+		{now: time.Date(2019, time.June, 1, 0, 0, 0, 0, time.Local), expected: "2019-03-10 15:04:05\n"},
+		// A date later in the year than the clock is from the year before
+		{now: time.Date(2019, time.February, 1, 0, 0, 0, 0, time.Local), expected: "2018-03-10 15:04:05\n"},
+	}
+
+	for _, tt := range tests {
+		now := tt.now
+		formatter, err := NewTemplateFormatter("{timestamp | date}",
+			WithNoColors(true), WithInputParser(parser), WithClock(func() time.Time { return now }))
+		if err != nil {
+			t.Fatalf("Failed to create formatter: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := formatter.ProcessStream(strings.NewReader("<30>Mar 10 15:04:05 host systemd[1]: Started job\n"), &buf, formatter, nil, false); err != nil {
+			t.Fatalf("ProcessStream failed: %v", err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("With a clock at %s, expected %q, got %q", now, tt.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package formatter
+
+// truncationMarker is appended to strings cut short by WithMaxFieldLength
+const truncationMarker = "…"
+
+// WithMaxFieldLength truncates string values longer than maxLen runes, in
+// each record read by ProcessStream, before it is formatted, appending "…".
+// Strings in nested maps and arrays are truncated too, so a runaway value such
+// as an embedded stack trace never reaches the template. Skip patterns still
+// see the full values. Zero, the default, disables truncation.
+func WithMaxFieldLength(maxLen int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.maxFieldLength = maxLen
+	}
+}
+
+// truncateFields truncates the string values in data, and in any maps or
+// arrays it contains, to maxLen runes
+func truncateFields(data map[string]interface{}, maxLen int) {
+	if maxLen <= 0 {
+		return
+	}
+	mapStrings(data, func(s string) string { return truncateString(s, maxLen) })
+}
+
+// truncateString cuts s to maxLen runes, appending truncationMarker if
+// anything was removed
+func truncateString(s string, maxLen int) string {
+	// A string can't have more runes than bytes
+	if len(s) <= maxLen {
+		return s
+	}
+
+	count := 0
+	for i := range s {
+		if count == maxLen {
+			return s[:i] + truncationMarker
+		}
+		count++
+	}
+	return s
+}
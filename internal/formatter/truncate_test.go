@@ -0,0 +1,130 @@
+package formatter
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTruncateFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxLen   int
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "disabled",
+			maxLen:   0,
+			data:     map[string]interface{}{"message": "a long message"},
+			expected: map[string]interface{}{"message": "a long message"},
+		},
+		{
+			name:     "short and exact strings are unchanged",
+			maxLen:   5,
+			data:     map[string]interface{}{"level": "info", "msg": "hello"},
+			expected: map[string]interface{}{"level": "info", "msg": "hello"},
+		},
+		{
+			name:     "long string",
+			maxLen:   5,
+			data:     map[string]interface{}{"msg": "hello world"},
+			expected: map[string]interface{}{"msg": "hello…"},
+		},
+		{
+			name:     "counts runes rather than bytes",
+			maxLen:   3,
+			data:     map[string]interface{}{"short": "héé", "long": "日本語です"},
+			expected: map[string]interface{}{"short": "héé", "long": "日本語…"},
+		},
+		{
+			name:   "nested maps and arrays",
+			maxLen: 4,
+			data: map[string]interface{}{
+				"error": map[string]interface{}{
+					"stack": "goroutine 1 [running]",
+					"frames": []interface{}{
+						"main.main()",
+						map[string]interface{}{"func": "runtime.main"},
+						float64(42),
+					},
+				},
+			},
+			expected: map[string]interface{}{
+				"error": map[string]interface{}{
+					"stack": "goro…",
+					"frames": []interface{}{
+						"main…",
+						map[string]interface{}{"func": "runt…"},
+						float64(42),
+					},
+				},
+			},
+		},
+		{
+			name:     "non-string values are unchanged",
+			maxLen:   1,
+			data:     map[string]interface{}{"count": float64(123456), "ok": true, "missing": nil},
+			expected: map[string]interface{}{"count": float64(123456), "ok": true, "missing": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			truncateFields(tt.data, tt.maxLen)
+			if !reflect.DeepEqual(tt.data, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.data)
+			}
+		})
+	}
+}
+
+func TestProcessStreamWithMaxFieldLength(t *testing.T) {
+	stack := strings.Repeat("at frame\n", 1000)
+	input := `{"level":"error","message":"request failed","error":{"stack":` + strconv.Quote(stack) + `}}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{level} {message} {{.error.stack}}",
+		WithNoColors(true), WithMaxFieldLength(10))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	// Skip patterns see the full values
+	skip := []SkipPattern{{Field: "message", Value: "request failed"}}
+	var skipped bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &skipped, formatter, skip, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if skipped.String() != "" {
+		t.Errorf("Expected the record to be skipped, got %q", skipped.String())
+	}
+
+	var out bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &out, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if expected := "error request fa… at frame\na…\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestProcessStreamTruncatesLinesLongerThanScannerDefault(t *testing.T) {
+	// Longer than the 64KB bufio.Scanner allows by default
+	stack := strings.Repeat("x", 200*1024)
+	input := `{"level":"error","stack":"` + stack + `"}` + "\n" + `{"level":"info","stack":"ok"}` + "\n"
+
+	formatter, err := NewTemplateFormatter("{level} {{.stack}}", WithNoColors(true), WithMaxFieldLength(5))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := formatter.ProcessStream(strings.NewReader(input), &out, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+	if expected := "error xxxxx…\ninfo ok\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
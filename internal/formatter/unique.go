@@ -0,0 +1,86 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithUniqueBy makes ProcessStream write only the first record with each
+// distinct value of the given fields, such as one record per error code.
+// Given several fields, records are compared by the combination of their
+// values. Fields may be dotted paths into nested maps, values are compared by
+// their string form, like skip patterns, and records without a field are
+// treated as having "<no value>". Every distinct value is remembered for the
+// rest of the stream unless limited with WithUniqueCap.
+func WithUniqueBy(fields ...string) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.uniqueBy = fields
+	}
+}
+
+// WithUniqueCap limits how many distinct values WithUniqueBy remembers. Once
+// the limit is reached the oldest value is forgotten for each new one, so a
+// record whose value was last seen long ago may be written again. Zero, the
+// default, means no limit.
+func WithUniqueCap(n int) FormatterOption {
+	return func(tf *TemplateFormatter) {
+		tf.uniqueCap = max(n, 0)
+	}
+}
+
+// uniqueFilter remembers the values seen in a stream to drop repeats
+type uniqueFilter struct {
+	fields []string
+	seen   map[string]struct{}
+	// order holds the remembered values, oldest first from next, when the
+	// number of values is capped
+	order []string
+	next  int
+}
+
+// newUniqueFilter returns a uniqueFilter for a stream, or nil if records
+// aren't filtered by value
+func (f *TemplateFormatter) newUniqueFilter() *uniqueFilter {
+	if len(f.uniqueBy) == 0 {
+		return nil
+	}
+
+	u := &uniqueFilter{fields: f.uniqueBy, seen: make(map[string]struct{})}
+	if f.uniqueCap > 0 {
+		u.order = make([]string, 0, f.uniqueCap)
+	}
+	return u
+}
+
+// isNew reports whether a record is the first with its values, remembering
+// them if it is
+func (u *uniqueFilter) isNew(data map[string]interface{}) bool {
+	if u == nil {
+		return true
+	}
+
+	values := make([]string, len(u.fields))
+	for i, field := range u.fields {
+		values[i] = noValueStr
+		if value, ok := lookupField(data, field); ok {
+			values[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	key := strings.Join(values, "\x00")
+
+	if _, ok := u.seen[key]; ok {
+		return false
+	}
+	u.seen[key] = struct{}{}
+
+	if u.order != nil {
+		if len(u.order) < cap(u.order) {
+			u.order = append(u.order, key)
+		} else {
+			delete(u.seen, u.order[u.next])
+			u.order[u.next] = key
+			u.next = (u.next + 1) % len(u.order)
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessStreamWithUniqueBy(t *testing.T) {
+	input := `{"n":1,"code":"E1","host":"a"}` + "\n" +
+		`{"n":2,"code":"E2","host":"a"}` + "\n" +
+		`{"n":3,"code":"E1","host":"b"}` + "\n" +
+		"oops\n" +
+		`{"n":4,"code":"E1","host":"a"}` + "\n" +
+		`{"n":5,"host":"a"}` + "\n" +
+		`{"n":6,"code":"E3","host":"a","level":"debug"}` + "\n" +
+		`{"n":7,"code":"E2","host":"b"}` + "\n" +
+		`{"n":8,"host":"b"}` + "\n" +
+		`{"n":9,"code":"E3","host":"a"}` + "\n" +
+		`{"n":10,"code":"E1","host":"a"}` + "\n"
+
+	tests := []struct {
+		name     string
+		options  []FormatterOption
+		expected string
+	}{
+		{
+			name:     "single field",
+			options:  []FormatterOption{WithUniqueBy("code")},
+			expected: "1\n2\n>>> oops\n5\n9\n",
+		},
+		{
+			name:     "composite key",
+			options:  []FormatterOption{WithUniqueBy("code", "host")},
+			expected: "1\n2\n3\n>>> oops\n5\n7\n8\n9\n",
+		},
+		{
+			name: "capped",
+			// E1 is forgotten when the record without a code is seen
+			options:  []FormatterOption{WithUniqueBy("code"), WithUniqueCap(2)},
+			expected: "1\n2\n>>> oops\n5\n9\n10\n",
+		},
+		{
+			name:     "cap of one",
+			options:  []FormatterOption{WithUniqueBy("host"), WithUniqueCap(1)},
+			expected: "1\n3\n>>> oops\n4\n7\n9\n",
+		},
+	}
+
+	skip := []SkipPattern{{Field: "level", Value: "debug"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]FormatterOption{WithNoColors(true), WithCompactNonJSON(true)}, tt.options...)
+			formatter, err := NewTemplateFormatter("{n}", options...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := formatter.ProcessStream(strings.NewReader(input), &buf, formatter, skip, true); err != nil {
+				t.Fatalf("ProcessStream failed: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestUniqueFilterCap(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{n}", WithUniqueBy("code"), WithUniqueCap(2))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+	unique := formatter.newUniqueFilter()
+
+	steps := []struct {
+		code  string
+		isNew bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"a", false},
+		{"c", true}, // Forgets a
+		{"b", false},
+		{"a", true}, // Forgets b
+		{"b", true},
+	}
+	for i, step := range steps {
+		if isNew := unique.isNew(map[string]interface{}{"code": step.code}); isNew != step.isNew {
+			t.Errorf("Step %d: expected %q to be new: %v, got %v", i, step.code, step.isNew, isNew)
+		}
+	}
+	if len(unique.seen) != 2 {
+		t.Errorf("Expected 2 remembered values, got %d", len(unique.seen))
+	}
+}
@@ -0,0 +1,151 @@
+package formatter
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// FlushWhenFull is a flush interval that only flushes a BufferedWriter when
+// its buffer fills or it is closed, which gives the best throughput when the
+// output isn't being watched
+const FlushWhenFull time.Duration = -1
+
+// BufferedWriter buffers output written to an underlying writer. With a zero
+// flush interval it flushes after every record, which keeps live tailing
+// responsive; with a positive interval records are batched and flushed
+// periodically for throughput; with FlushWhenFull output is only flushed when
+// the buffer fills. It is safe for concurrent use, so it can be flushed from a
+// signal handler while a stream is being processed.
+type BufferedWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	buf      *bufio.Writer
+	interval time.Duration
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewBufferedWriter creates a BufferedWriter that flushes to w after every
+// record, every interval when interval is positive, or only when the buffer
+// fills when interval is FlushWhenFull. Close must be called to flush
+// remaining output and stop the background flusher.
+func NewBufferedWriter(w io.Writer, interval time.Duration) *BufferedWriter {
+	bw := &BufferedWriter{
+		w:        w,
+		buf:      bufio.NewWriter(w),
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+
+	if interval > 0 {
+		go bw.flushPeriodically()
+	}
+
+	return bw
+}
+
+// Write buffers p for writing to the underlying writer
+func (bw *BufferedWriter) Write(p []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.buf.Write(p)
+}
+
+// Flush writes any buffered output to the underlying writer
+func (bw *BufferedWriter) Flush() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.buf.Flush()
+}
+
+// Unwrap returns the underlying writer
+func (bw *BufferedWriter) Unwrap() io.Writer {
+	return bw.w
+}
+
+// FlushRecord is called by ProcessStream after each record is written. It
+// flushes immediately unless a flush interval or FlushWhenFull is set.
+func (bw *BufferedWriter) FlushRecord() error {
+	if bw.interval != 0 {
+		return nil
+	}
+	return bw.Flush()
+}
+
+// StartRecord is called by ProcessStream before each record is written. If
+// the underlying writer also wants to see each record, such as a
+// RotatingWriter, buffered output is flushed before passing the record on,
+// so it is written to the right file.
+func (bw *BufferedWriter) StartRecord(data map[string]interface{}) error {
+	rs, ok := bw.w.(recordStarter)
+	if !ok {
+		return nil
+	}
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if err := bw.buf.Flush(); err != nil {
+		return err
+	}
+	return rs.StartRecord(data)
+}
+
+// Close stops the background flusher and flushes any remaining output
+func (bw *BufferedWriter) Close() error {
+	bw.once.Do(func() { close(bw.done) })
+	return bw.Flush()
+}
+
+// flushPeriodically flushes the buffer every interval until Close is called
+func (bw *BufferedWriter) flushPeriodically() {
+	ticker := time.NewTicker(bw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Errors resurface on the next Write or Flush
+			_ = bw.Flush()
+		case <-bw.done:
+			return
+		}
+	}
+}
+
+// recordStarter is implemented by writers that want to see each record
+// before it is written, such as RotatingWriter
+type recordStarter interface {
+	StartRecord(data map[string]interface{}) error
+}
+
+// startRecord passes a record that is about to be written to w, if it cares
+func startRecord(w io.Writer, data map[string]interface{}) error {
+	if rs, ok := w.(recordStarter); ok {
+		return rs.StartRecord(data)
+	}
+	return nil
+}
+
+// recordFlusher is implemented by writers that want to be notified after
+// each record is written, such as BufferedWriter
+type recordFlusher interface {
+	FlushRecord() error
+}
+
+// flushRecord notifies w that a record has been written, if it cares
+func flushRecord(w io.Writer) error {
+	if rf, ok := w.(recordFlusher); ok {
+		return rf.FlushRecord()
+	}
+	return nil
+}
+
+// flushWriter flushes any output buffered by w at the end of a stream
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
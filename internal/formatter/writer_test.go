@@ -0,0 +1,124 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestBufferedWriterFlushesEachRecord(t *testing.T) {
+	var out syncBuffer
+	bw := NewBufferedWriter(&out, 0)
+	defer bw.Close()
+
+	if _, err := bw.Write([]byte("one\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if out.String() != "" {
+		t.Errorf("Expected output to be buffered, got %q", out.String())
+	}
+
+	if err := bw.FlushRecord(); err != nil {
+		t.Fatalf("FlushRecord failed: %v", err)
+	}
+	if out.String() != "one\n" {
+		t.Errorf("Expected 'one\\n' after FlushRecord, got %q", out.String())
+	}
+}
+
+func TestBufferedWriterFlushesOnInterval(t *testing.T) {
+	var out syncBuffer
+	bw := NewBufferedWriter(&out, 10*time.Millisecond)
+	defer bw.Close()
+
+	if _, err := bw.Write([]byte("one\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := bw.FlushRecord(); err != nil {
+		t.Fatalf("FlushRecord failed: %v", err)
+	}
+	if out.String() != "" {
+		t.Errorf("Expected FlushRecord to defer to the interval, got %q", out.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for out.String() == "" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if out.String() != "one\n" {
+		t.Errorf("Expected 'one\\n' after the flush interval, got %q", out.String())
+	}
+}
+
+func TestBufferedWriterFlushesWhenFull(t *testing.T) {
+	var out syncBuffer
+	bw := NewBufferedWriter(&out, FlushWhenFull)
+
+	if _, err := bw.Write([]byte("one\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := bw.FlushRecord(); err != nil {
+		t.Fatalf("FlushRecord failed: %v", err)
+	}
+	if out.String() != "" {
+		t.Errorf("Expected FlushRecord to leave output buffered, got %q", out.String())
+	}
+
+	// Writing more than the buffer holds flushes it
+	large := strings.Repeat("x", 8192)
+	if _, err := bw.Write([]byte(large)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "one\n") {
+		t.Errorf("Expected a full buffer to be flushed, got %d bytes", len(out.String()))
+	}
+
+	if err := bw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if out.String() != "one\n"+large {
+		t.Errorf("Expected all output after Close, got %d bytes", len(out.String()))
+	}
+}
+
+func TestProcessStreamFlushesBufferedWriter(t *testing.T) {
+	formatter, err := NewTemplateFormatter("{{.level}}")
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	var out syncBuffer
+	bw := NewBufferedWriter(&out, time.Hour)
+	defer bw.Close()
+
+	input := `{"level":"info"}
+{"level":"error"}`
+	if err := formatter.ProcessStream(strings.NewReader(input), bw, formatter, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	// The final flush happens at EOF even though the interval hasn't elapsed
+	if out.String() != "info\nerror\n" {
+		t.Errorf("Expected 'info\\nerror\\n', got %q", out.String())
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
 func main() {
-	if err := Execute(); err != nil {
+	err := Execute()
+	if errors.Is(err, errInterrupted) {
+		os.Exit(130) // Conventional exit status for SIGINT
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dpup/logista/internal/formatter"
+
+	"github.com/spf13/viper"
+)
+
+// openOutput opens the file formatted output is written to, creating it if
+// needed. The file is truncated unless appendOutput is set. If path is empty
+// or "-", output is written to stdout.
+func openOutput(path string, appendOutput bool) (*os.File, error) {
+	if path == "" || path == "-" {
+		return os.Stdout, nil
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendOutput {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0o644) //nolint:gosec // Writing to a user-specified output file is intended
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	return file, nil
+}
+
+// writingToFile reports whether formatted output is written to files
+// rather than stdout
+func writingToFile() bool {
+	path := viper.GetString(keyOutput)
+	return (path != "" && path != "-") || viper.GetString(keySplit) != ""
+}
+
+// stdoutSupportsANSI and stderrSupportsANSI report whether stdout and stderr
+// can show colors, turning on support for them the first time they are
+// called on a Windows console
+var (
+	stdoutSupportsANSI = sync.OnceValue(func() bool {
+		return enableVirtualTerminal(os.Stdout)
+	})
+	stderrSupportsANSI = sync.OnceValue(func() bool {
+		return enableVirtualTerminal(os.Stderr)
+	})
+)
+
+// noColorEnv reports whether the NO_COLOR environment variable asks for
+// output without colors, which --no_colors overrides
+func noColorEnv() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// showsColors reports whether colors are written to w when --no_colors isn't
+// given, which they are for terminals that can show them
+func showsColors(w io.Writer) bool {
+	return formatter.IsTerminal(w) && supportsANSI(w)
+}
+
+// supportsANSI reports whether w, or the stdout or stderr it wraps, can show
+// colors. Other writers are written to as-is, so they report true.
+func supportsANSI(w io.Writer) bool {
+	for {
+		switch v := w.(type) {
+		case *os.File:
+			switch v {
+			case os.Stdout:
+				return stdoutSupportsANSI()
+			case os.Stderr:
+				return stderrSupportsANSI()
+			}
+			return true
+		case interface{ Unwrap() io.Writer }:
+			w = v.Unwrap()
+		default:
+			return true
+		}
+	}
+}
+
+// parseSplit returns how --split divides output into files, or nil if output
+// isn't split
+func parseSplit() (*formatter.Split, error) {
+	spec := viper.GetString(keySplit)
+	if spec == "" {
+		return nil, nil
+	}
+
+	if path := viper.GetString(keyOutput); path != "" && path != "-" {
+		return nil, fmt.Errorf("--%s writes files to --%s and can't be combined with --%s", keySplit, keyOutputDir, keyOutput)
+	}
+	if viper.GetBool(keySingle) {
+		return nil, fmt.Errorf("--%s can't be combined with --%s", keySplit, keySingle)
+	}
+
+	split, err := formatter.ParseSplit(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dpup/logista/internal/formatter"
+)
+
+func TestOpenOutput(t *testing.T) {
+	input := `{"level":"info","message":"one"}` + "\n" +
+		`{"level":"warn","message":"two"}` + "\n"
+
+	f, err := formatter.NewTemplateFormatter("{level} {message}", formatter.WithNoColors(true))
+	if err != nil {
+		t.Fatalf("Failed to create formatter: %v", err)
+	}
+
+	// writeOutput formats the input to path, as logista --output does
+	writeOutput := func(path string, appendOutput bool) {
+		t.Helper()
+		file, err := openOutput(path, appendOutput)
+		if err != nil {
+			t.Fatalf("openOutput failed: %v", err)
+		}
+		defer file.Close()
+
+		out := formatter.NewBufferedWriter(file, formatter.FlushWhenFull)
+		if err := f.ProcessStream(strings.NewReader(input), out, f, nil, false); err != nil {
+			t.Fatalf("ProcessStream failed: %v", err)
+		}
+		if err := out.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := f.ProcessStream(strings.NewReader(input), &stdout, f, nil, false); err != nil {
+		t.Fatalf("ProcessStream failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "formatted.log")
+
+	t.Run("matches stdout", func(t *testing.T) {
+		writeOutput(path, false)
+		if content, _ := os.ReadFile(path); string(content) != stdout.String() {
+			t.Errorf("Expected file to contain %q, got %q", stdout.String(), content)
+		}
+	})
+
+	t.Run("truncates", func(t *testing.T) {
+		writeOutput(path, false)
+		if content, _ := os.ReadFile(path); string(content) != stdout.String() {
+			t.Errorf("Expected file to be truncated, got %q", content)
+		}
+	})
+
+	t.Run("appends", func(t *testing.T) {
+		writeOutput(path, true)
+		if content, _ := os.ReadFile(path); string(content) != stdout.String()+stdout.String() {
+			t.Errorf("Expected output to be appended, got %q", content)
+		}
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		for _, path := range []string{"", "-"} {
+			if file, err := openOutput(path, false); err != nil || file != os.Stdout {
+				t.Errorf("Expected %q to write to stdout, got %v, %v", path, file, err)
+			}
+		}
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := openOutput(filepath.Join(t.TempDir(), "missing", "out.log"), false)
+		if err == nil || !strings.Contains(err.Error(), "failed to open output file") {
+			t.Errorf("Expected a clear error for an invalid path, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+// Package formatter formats structured log records using logista templates.
+//
+// It exposes the same template language as the logista command line tool,
+// including the simple {field} syntax, @-prefixed field names, and helper
+// functions such as date, pad, table and colorByLevel, so services can format
+// their own logs without shelling out:
+//
+//	f, err := formatter.New("{timestamp | date} [{level}] {message}", formatter.WithoutColors())
+//	if err != nil {
+//		return err
+//	}
+//	line, err := f.Format(map[string]interface{}{"level": "info", "message": "ready"})
+//
+// The API in this package follows semantic versioning.
+package formatter
+
+import (
+	"text/template"
+
+	internal "github.com/dpup/logista/internal/formatter"
+)
+
+// DefaultDateFormat is the layout used by the date function unless overridden
+// with WithDateFormat
+const DefaultDateFormat = "2006-01-02 15:04:05"
+
+// TemplateError is returned by New when the template can't be parsed. It
+// reports the line and column of the error within the original template.
+type TemplateError = internal.TemplateError
+
+// Formatter formats log records with a template. It is safe for concurrent
+// use by multiple goroutines.
+type Formatter struct {
+	tf *internal.TemplateFormatter
+}
+
+// config holds the settings applied by Option values
+type config struct {
+	dateFormat   string
+	noColors     bool
+	simpleSyntax bool
+	funcs        template.FuncMap
+	kvSeparator  *string
+	mapSort      string
+	strictEq     bool
+	tableOmit    func(key string, val interface{}) bool
+}
+
+// Option configures a Formatter
+type Option func(*config)
+
+// WithDateFormat sets the output layout of the date function, using either
+// Go's reference time syntax (e.g. "15:04:05") or, if the layout contains a
+// %, strftime syntax (e.g. "%H:%M:%S")
+func WithDateFormat(layout string) Option {
+	return func(c *config) {
+		c.dateFormat = layout
+	}
+}
+
+// WithoutColors disables ANSI color and style codes in the output
+func WithoutColors() Option {
+	return func(c *config) {
+		c.noColors = true
+	}
+}
+
+// WithSimpleSyntax enables or disables the simple {field} syntax. When
+// disabled, templates must use Go template syntax, e.g. {{.field}}. Simple
+// syntax is enabled by default.
+func WithSimpleSyntax(enabled bool) Option {
+	return func(c *config) {
+		c.simpleSyntax = enabled
+	}
+}
+
+// WithKVSeparator sets the separator written between keys and values by the
+// pretty, table and tree functions, e.g. ": " or " → ". By default pretty
+// renders maps as key=value, while tables separate keys from values with
+// padding only.
+func WithKVSeparator(separator string) Option {
+	return func(c *config) {
+		c.kvSeparator = &separator
+	}
+}
+
+// Orders in which pretty, table and tree render the fields of a map
+const (
+	// MapSortKey orders fields alphabetically by key
+	MapSortKey = internal.MapSortKey
+	// MapSortValue orders fields by value, smallest first
+	MapSortValue = internal.MapSortValue
+	// MapSortValueDesc orders fields by value, largest first
+	MapSortValueDesc = internal.MapSortValueDesc
+)
+
+// WithMapSort sets the order in which map fields are rendered: MapSortKey
+// (the default), MapSortValue or MapSortValueDesc. Values are compared
+// numerically when both are numbers, and as strings otherwise.
+func WithMapSort(order string) Option {
+	return func(c *config) {
+		c.mapSort = order
+	}
+}
+
+// WithStrictEquality makes the eq and ne functions type-sensitive, so a
+// string never equals a number. By default numeric strings are compared by
+// value, so eq "007" 7 is true.
+func WithStrictEquality() Option {
+	return func(c *config) {
+		c.strictEq = true
+	}
+}
+
+// WithFuncs registers custom template functions, e.g. to decode
+// domain-specific identifiers. Custom functions take precedence over built-in
+// functions of the same name, and later calls override earlier ones.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(c *config) {
+		if c.funcs == nil {
+			c.funcs = make(template.FuncMap, len(funcs))
+		}
+		for name, fn := range funcs {
+			c.funcs[name] = fn
+		}
+	}
+}
+
+// WithTableOmitFunc sets the predicate the table and tree functions use to
+// decide which fields to leave out, e.g. to hide false booleans or sentinel
+// values. It is called with the key and value of each field, including those
+// of nested maps. By default nil values and empty strings are left out.
+func WithTableOmitFunc(omit func(key string, val interface{}) bool) Option {
+	return func(c *config) {
+		c.tableOmit = omit
+	}
+}
+
+// New creates a Formatter for the given template. It returns a
+// *TemplateError if the template is invalid, or an error if an option is
+// invalid, such as a custom function with an unsupported signature.
+func New(format string, opts ...Option) (*Formatter, error) {
+	cfg := config{
+		dateFormat:   DefaultDateFormat,
+		simpleSyntax: true,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	internalOpts := []internal.FormatterOption{
+		internal.WithPreferredDateFormat(cfg.dateFormat),
+		internal.WithNoColors(cfg.noColors),
+		internal.WithFuncs(cfg.funcs),
+		internal.WithMapSort(cfg.mapSort),
+		internal.WithStrictEquality(cfg.strictEq),
+		internal.WithTableOmitFunc(cfg.tableOmit),
+	}
+	if cfg.kvSeparator != nil {
+		internalOpts = append(internalOpts, internal.WithKVSeparator(*cfg.kvSeparator))
+	}
+
+	tf, err := internal.NewTemplateFormatterWithOptions(
+		format,
+		internal.PreProcessTemplateOptions{EnableSimpleSyntax: cfg.simpleSyntax},
+		internalOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Formatter{tf: tf}, nil
+}
+
+// Format renders a single log record, typically decoded from a JSON object
+func (f *Formatter) Format(data map[string]interface{}) (string, error) {
+	return f.tf.Format(data)
+}
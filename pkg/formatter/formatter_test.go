@@ -0,0 +1,136 @@
+package formatter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		opts     []Option
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "simple syntax",
+			format:   "[{level}] {message}",
+			data:     map[string]interface{}{"level": "info", "message": "ready"},
+			expected: "[info] ready",
+		},
+		{
+			name:     "go template syntax",
+			format:   "{{.level}} {{.message}}",
+			data:     map[string]interface{}{"level": "info", "message": "ready"},
+			expected: "info ready",
+		},
+		{
+			name:     "simple syntax disabled",
+			format:   "{level} {{.message}}",
+			opts:     []Option{WithSimpleSyntax(false)},
+			data:     map[string]interface{}{"level": "info", "message": "ready"},
+			expected: "{level} ready",
+		},
+		{
+			name:     "date format",
+			format:   "{timestamp | date}",
+			opts:     []Option{WithDateFormat("15:04:05")},
+			data:     map[string]interface{}{"timestamp": "2024-03-10T15:04:05Z"},
+			expected: "15:04:05",
+		},
+		{
+			name:     "default date format",
+			format:   "{timestamp | date}",
+			data:     map[string]interface{}{"timestamp": "2024-03-10T15:04:05Z"},
+			expected: "2024-03-10 15:04:05",
+		},
+		{
+			name:     "without colors",
+			format:   `{level | color "red"}`,
+			opts:     []Option{WithoutColors()},
+			data:     map[string]interface{}{"level": "error"},
+			expected: "error",
+		},
+		{
+			name:   "custom function",
+			format: "{id | decodeID}",
+			opts: []Option{WithFuncs(template.FuncMap{
+				"decodeID": func(id string) string { return strings.TrimPrefix(id, "usr_") },
+			})},
+			data:     map[string]interface{}{"id": "usr_1234"},
+			expected: "1234",
+		},
+		{
+			name:     "key-value separator",
+			format:   "{ctx | pretty}",
+			opts:     []Option{WithKVSeparator(": "), WithoutColors()},
+			data:     map[string]interface{}{"ctx": map[string]interface{}{"a": 1}},
+			expected: "{a: 1}",
+		},
+		{
+			name:     "map sort by value",
+			format:   "{counts | pretty}",
+			opts:     []Option{WithMapSort(MapSortValueDesc), WithoutColors()},
+			data:     map[string]interface{}{"counts": map[string]interface{}{"a": 1, "b": 3}},
+			expected: "{b=3, a=1}",
+		},
+		{
+			name:     "strict equality",
+			format:   `{{if eq .code 7}}match{{else}}no match{{end}}`,
+			opts:     []Option{WithStrictEquality()},
+			data:     map[string]interface{}{"code": "007"},
+			expected: "no match",
+		},
+		{
+			name:   "table omit predicate",
+			format: "{{table 6 .}}",
+			opts: []Option{WithoutColors(), WithTableOmitFunc(func(key string, val interface{}) bool {
+				return val == false
+			})},
+			data:     map[string]interface{}{"cached": false, "ok": true},
+			expected: "  ok    true",
+		},
+		{
+			name:     "with colors",
+			format:   `{level | color "red"}`,
+			data:     map[string]interface{}{"level": "error"},
+			expected: "\033[31merror\033[0m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.format, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+
+			result, err := f.Format(tt.data)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewInvalidTemplate(t *testing.T) {
+	_, err := New("{{.level | nosuchfunc}}")
+	if err == nil {
+		t.Fatal("Expected an error for an invalid template")
+	}
+
+	var templateErr *TemplateError
+	if !errors.As(err, &templateErr) {
+		t.Fatalf("Expected a *TemplateError, got %T", err)
+	}
+	if templateErr.Line != 1 {
+		t.Errorf("Expected error on line 1, got %d", templateErr.Line)
+	}
+}
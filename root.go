@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/dpup/logista/internal/diag"
 	"github.com/dpup/logista/internal/formatter"
 	"github.com/dpup/logista/internal/version"
 
@@ -15,61 +27,428 @@ import (
 // Default format template
 const defaultFormat = "{{.timestamp | date}} {{.level}} {{.message}}"
 
+// Default format template with --level_badges
+const badgeFormat = "{{.timestamp | date}} {{.level | badgeByLevel 5}} {{.message}}"
+
 // Config options
 const (
-	keyFormat        = "format"
-	keyDateFormat    = "date_format"
-	keyNoColors      = "no_colors"
-	keyConfig        = "config"
-	keyEnableSimple  = "enable_simple_syntax"
-	keySkip          = "skip"
-	keyHandleNonJSON = "handle_non_json"
+	keyFormat         = "format"
+	keyDateFormat     = "date_format"
+	keyNoColors       = "no_colors"
+	keyColorDepth     = "color_depth"
+	keyConfig         = "config"
+	keyEnableSimple   = "enable_simple_syntax"
+	keySkip           = "skip"
+	keySkipCheck      = "skip_check"
+	keyHandleNonJSON  = "handle_non_json"
+	keyExplain        = "explain"
+	keyDryRun         = "dry_run"
+	keyFormatFile     = "format_file"
+	keyWatchTemplate  = "watch_template"
+	keyFlushInterval  = "flush_interval"
+	keyWatch          = "watch"
+	keySingle         = "single"
+	keyKVSeparator    = "kv_separator"
+	keyInputFormat    = "input_format"
+	keyRecordDelim    = "record_delimiter"
+	keyMapSort        = "map_sort"
+	keyCompactNonJSON = "compact_non_json"
+	keyComments       = "comments"
+	keyCommentPrefix  = "comment_prefix"
+	keyNormalizeKeys  = "normalize_keys"
+	keyQuiet          = "quiet"
+	keyLogFormat      = "log_format"
+	keyExecField      = "exec_field"
+	keySet            = "set"
+	keyPrefix         = "prefix"
+	keyHighlight      = "highlight"
+	keySuffix         = "suffix"
+	keyExecTimeout    = "exec_timeout"
+	keyReconnect      = "reconnect"
+	keyMerge          = "merge"
+	keyStrictEquality = "strict_equality"
+	keyRoundDurations = "round_durations"
+	keyDurationStyle  = "duration_style"
+	keyPreset         = "preset"
+	keyTemplates      = "templates"
+	keyStyles         = "styles"
+	keyTemplateName   = "template_name"
+	keyLineBuffered   = "line_buffered"
+	keyNoAuto         = "no_auto"
+	keyLevelBadges    = "level_badges"
+	keyRawFormat      = "raw_format"
+	keyNormalizeLevel = "normalize_level"
+	keyNormalizeTime  = "normalize_time"
+	keyLift           = "lift"
+	keyLiftOverwrite  = "lift_overwrite"
+	keyOutput         = "output"
+	keyAppend         = "append"
+	keyCountBy        = "count_by"
+	keySplit          = "split"
+	keyOutputDir      = "output_dir"
+	keyMaxFieldLength = "max_field_length"
+	keyEscapeNewlines = "escape_newlines"
+	keyPrettyMaxDepth = "pretty_max_depth"
+	keySingleLine     = "single_line"
+	keySingleLineSep  = "single_line_separator"
+	keySmartFields    = "smart_fields"
+	keyIndent         = "indent"
+	keyReplay         = "replay"
+	keySplitStreams   = "split_streams"
+	keyHead           = "head"
+	keyTail           = "tail"
+	keyColumns        = "columns"
+	keyColumnsBatch   = "columns_batch"
+	keyUniqueBy       = "unique_by"
+	keyGroupBy        = "group_by"
+	keyUniqueCap      = "unique_cap"
+	keySample         = "sample"
+	keySampleRate     = "sample_rate"
+	keySampleSeed     = "sample_seed"
+	keyReplayMaxGap   = "replay_max_gap"
+)
+
+// Config file names and locations
+const (
+	configName    = ".logista"
+	xdgConfigDir  = "logista"
+	xdgConfigName = "config"
+	envConfigDir  = "LOGISTA_CONFIG_DIR"
 )
 
+// configTypes are the extensions of the config files logista looks for, in
+// the order they are tried
+var configTypes = []string{"yaml", "yml", "json", "toml"}
+
 // Initialize cobra command
 var rootCmd = &cobra.Command{
-	Use:   "logista",
+	Use:   "logista [file...]",
 	Short: "Utility for formatting JSON log streams",
 	Long: `Logista is a CLI tool that accepts a stream of JSON log entries 
 and formats them according to a specified template.`,
+	Args:    checkArgs,
 	RunE:    runLogista,
 	Version: version.Version,
 }
 
 var cfgFile string
 
+// execFields and execTimeout hold the --exec_field options, which aren't
+// bound to viper so they can only be given on the command line
+var (
+	execFields  []string
+	execTimeout time.Duration
+)
+
+// commandLine is rootCmd, for code run by rootCmd that checks which flags
+// were given, which can't refer to rootCmd without an initialization cycle
+var commandLine *cobra.Command
+
+// configFilesUsed holds the config files loaded at startup
+var configFilesUsed []string
+
+// errInterrupted is returned by runLogista when the stream is stopped by an
+// interrupt, once the output has been written
+var errInterrupted = errors.New("interrupted")
+
+// configMu guards viper once the stream has started. Viper isn't safe for
+// concurrent use, and reloads write to it from a watcher's goroutine while
+// formatters built lazily on the stream's goroutine read it.
+var configMu sync.Mutex
+
+// diagnostics writes logista's own warnings and status messages to stderr
+var diagnostics = diag.New(os.Stderr)
+
 func init() { //nolint:gochecknoinits // Required for cobra command initialization
+	commandLine = rootCmd
 	cobra.OnInitialize(initConfig)
 
 	// Config file flag
-	rootCmd.PersistentFlags().StringVar(&cfgFile, keyConfig, "", "config file (default is $HOME/.logista.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, keyConfig, "", "config file in YAML, JSON or TOML (default is the first of $HOME/.logista.yaml, $XDG_CONFIG_HOME/logista/config.yaml and ./.logista.yaml)")
 
 	// Command line flags
-	rootCmd.PersistentFlags().String(keyFormat, defaultFormat, "Format template")
-	rootCmd.PersistentFlags().String(keyDateFormat, "2006-01-02 15:04:05", "Preferred date format for the date function")
-	rootCmd.PersistentFlags().Bool(keyNoColors, false, "Disable colored output")
+	rootCmd.PersistentFlags().String(keyFormat, defaultFormat, "Format template, or @- to read it from stdin when logs are read from a file argument")
+	rootCmd.PersistentFlags().String(keyPreset, "", "Use a built-in template for a common logger ("+strings.Join(formatter.PresetNames(), ", ")+"), unless --format is also given")
+	rootCmd.PersistentFlags().String(keyTemplateName, "", "Use a named template from the templates map in the config file, instead of --format or --preset")
+	rootCmd.PersistentFlags().Bool(keyRawFormat, false, "Don't interpret Go escapes such as \\t, \\n and \\\\ in the text of --format, --prefix and --suffix on the command line")
+	rootCmd.PersistentFlags().Bool(keyLevelBadges, false, "Show the level as a badge colored by level, such as white on red for errors, in the default template")
+	rootCmd.PersistentFlags().Bool(keyNoAuto, false, "Don't detect zap, logrus, bunyan, ECS or GCP records and pick a matching preset when no template is given; only the first record is checked")
+	rootCmd.PersistentFlags().String(keyDateFormat, "2006-01-02 15:04:05", "Preferred date format for the date function, as a Go layout or strftime format (e.g. %Y-%m-%d %H:%M:%S)")
+	rootCmd.PersistentFlags().Bool(keyNoColors, false, "Disable colored output; by default colors are only written to terminals, and --no_colors=false keeps them in pipes")
+	rootCmd.PersistentFlags().String(keyColorDepth, formatter.ColorDepthAuto, "Colors the terminal can show (16, 256, truecolor, or auto to detect from TERM and COLORTERM); hex and palette colors are replaced by the nearest supported color")
+	rootCmd.PersistentFlags().String(keyMapSort, formatter.MapSortKey, "Order of map fields in pretty, table and tree output (key, value, value_desc)")
+	rootCmd.PersistentFlags().StringSlice(keySmartFields, []string{}, "Format nested fields in pretty, table and tree output by key suffix, as suffix=date or suffix=duration (e.g. --smart_fields _ms=duration,_at=date)")
+	rootCmd.PersistentFlags().Int(keyPrettyMaxDepth, 0, "Show maps and arrays nested deeper than this in pretty output as {…} or […] (0 for no limit)")
+	rootCmd.PersistentFlags().Int(keyIndent, formatter.DefaultIndent, "Number of spaces table, tree and list rows are indented by, and the default for the indent function")
+	rootCmd.PersistentFlags().String(keyKVSeparator, "", "Separator between keys and values in pretty, table and tree output (default \"=\" for pretty, padding only for tables)")
+	rootCmd.PersistentFlags().Bool(keyStrictEquality, false, "Make eq, ne, in and notIn type-sensitive, so \"10\" and 10 are not equal")
+	rootCmd.PersistentFlags().String(keyDurationStyle, formatter.DurationStyleShort, "How the duration function shows durations (short, long, ms, clock)")
+	rootCmd.PersistentFlags().Bool(keyRoundDurations, false, "Round durations of ten seconds or more to their two most significant units (e.g. 1h30m instead of 1h30m45.123s)")
+	rootCmd.PersistentFlags().Bool(keySingleLine, false, "Write each record on a single line, joining the lines of multi-line templates with --single_line_separator; blank lines are dropped")
+	rootCmd.PersistentFlags().String(keySingleLineSep, " | ", "Separator between the lines of a record with --single_line")
 	rootCmd.PersistentFlags().Bool(keyEnableSimple, true, "Enable simple {field} syntax in templates")
-	rootCmd.PersistentFlags().StringSlice(keySkip, []string{}, "Skip log records matching key=value pairs (e.g. --skip logger=Uploader.download). Values are matched as substrings, so 'msg=upload: Downloading' will match records containing that text.")
+	rootCmd.PersistentFlags().Int(keySkipCheck, 0, "Warn about --skip fields that none of the first this many records have, which are likely typos (e.g. --skip_check 100; 0 disables the check)")
+	rootCmd.PersistentFlags().StringSlice(keySkip, []string{}, "Skip log records matching key=value pairs (e.g. --skip logger=Uploader.download). Values are matched as substrings, so 'msg=upload: Downloading' will match records containing that text. Use key==value to match the whole value.")
 	rootCmd.PersistentFlags().Bool(keyHandleNonJSON, false, "Gracefully handle non-JSON data in the input stream")
+	rootCmd.PersistentFlags().Bool(keyCompactNonJSON, false, "Don't add blank lines around blocks of non-JSON data")
+	rootCmd.PersistentFlags().String(keyComments, "", "Handle lines starting with --comment_prefix as comments: pass to write them unchanged, or drop to leave them out")
+	rootCmd.PersistentFlags().String(keyCommentPrefix, formatter.DefaultCommentPrefix, "Prefix of comment lines handled by --comments")
+	rootCmd.PersistentFlags().String(keyNormalizeKeys, "", "Rewrite separators in record keys: dot (a_b becomes a.b) or underscore (a.b becomes a_b)")
+	rootCmd.PersistentFlags().Int(keyMaxFieldLength, 0, "Truncate string values, including nested ones, longer than this many characters before formatting (0 for no limit)")
+	rootCmd.PersistentFlags().Bool(keyEscapeNewlines, false, "Write newlines, carriage returns and tabs in field values as \\n, \\r and \\t, keeping newlines in the template")
+	rootCmd.PersistentFlags().String(keyNormalizeLevel, "", "Set a canonical lowercase level field from this field (e.g. severity), mapping GCP severities and numeric syslog levels")
+	rootCmd.PersistentFlags().String(keyNormalizeTime, "", "Rewrite each record's timestamp field in UTC with this Go layout or strftime format; --normalize_time alone writes RFC 3339")
+	rootCmd.PersistentFlags().Lookup(keyNormalizeTime).NoOptDefVal = formatter.TimeNormalizeRFC3339
+	rootCmd.PersistentFlags().StringSlice(keyLift, []string{}, "Promote the keys of these object fields, or dotted paths to nested objects, to the top level of each record before formatting (e.g. --lift jsonPayload; can be specified multiple times)")
+	rootCmd.PersistentFlags().Bool(keyLiftOverwrite, false, "Let keys promoted by --lift replace top-level fields of the same name, which are kept by default")
+	rootCmd.PersistentFlags().String(keyInputFormat, formatter.InputFormatJSON, "Format of input records ("+strings.Join(formatter.InputFormats(), ", ")+")")
+	rootCmd.PersistentFlags().String(keyRecordDelim, "", "Split input into records on this delimiter instead of newlines, with Go escapes such as \\0 or \\x1e (e.g. --record_delimiter '\\0')")
+	rootCmd.PersistentFlags().String(keyFormatFile, "", "Read the format template from a file (overrides --format)")
+	rootCmd.PersistentFlags().Bool(keyWatchTemplate, false, "Reload the template when the --format_file changes")
+	rootCmd.PersistentFlags().StringP(keyOutput, "o", "", "Write formatted output to this file instead of stdout (colors are disabled unless --no_colors=false is given)")
+	rootCmd.PersistentFlags().Bool(keyAppend, false, "Append to the --output file instead of truncating it")
+	rootCmd.PersistentFlags().String(keySplit, "", "Write output to a new file in --output_dir for each time window (hourly, daily) or size (e.g. size=10MB)")
+	rootCmd.PersistentFlags().String(keyOutputDir, ".", "Directory that --split writes files to")
+	rootCmd.PersistentFlags().String(keyCountBy, "", "Count records by the value of this field (e.g. status) and print the most frequent values to stderr when the stream ends")
+	rootCmd.PersistentFlags().Bool(keySingle, false, "Read a single record from stdin, format it and exit; JSON objects may span multiple lines, and with --record_delimiter the record ends at the first delimiter")
+	rootCmd.PersistentFlags().Bool(keyWatch, false, "Reload the template and skip patterns when a config file changes")
+	rootCmd.PersistentFlags().Bool(keyReconnect, false, "Reopen a named pipe or unix socket input at EOF, so output resumes when a producer reconnects")
+	rootCmd.PersistentFlags().Bool(keyMerge, false, "Read several file arguments and interleave their records in timestamp order, with each record's file in the _file field")
+	rootCmd.PersistentFlags().Duration(keyFlushInterval, 0, "Batch output and flush at this interval (e.g. 500ms)")
+	rootCmd.PersistentFlags().Bool(keyLineBuffered, false, "Flush output after every record (default true when stdout is a terminal, otherwise output is flushed when the buffer fills)")
+	rootCmd.PersistentFlags().StringArray(keySet, []string{}, "Compute a field before formatting, as name=template, for the format template to use (e.g. --set 'request={method} {path}'; can be specified multiple times)")
+	rootCmd.PersistentFlags().String(keyHighlight, "", "Highlight text matching this case-insensitive regular expression in every record and non-JSON line, whatever the template (e.g. --highlight 'timeout|refused')")
+	rootCmd.PersistentFlags().String(keyPrefix, "", "Add this to the start of every output line, as a plain string or a template evaluated against each record (e.g. --prefix '[{service}] ')")
+	rootCmd.PersistentFlags().String(keySuffix, "", "Add this to the end of every output line, as a plain string or a template evaluated against each record")
+	rootCmd.PersistentFlags().StringSliceVar(&execFields, keyExecField, []string{}, "Pipe a field's value through a shell command and show its output instead (e.g. --exec_field query='pg_format -'). Only accepted on the command line; see the README for security considerations.")
+	rootCmd.PersistentFlags().DurationVar(&execTimeout, keyExecTimeout, formatter.DefaultFieldCommandTimeout, "Maximum time each --exec_field command may run before the raw value is used")
+	rootCmd.PersistentFlags().Bool(keyQuiet, false, "Suppress logista's own informational and warning messages")
+	rootCmd.PersistentFlags().String(keyLogFormat, diag.FormatText, "Format of logista's own messages on stderr (text, json)")
+	rootCmd.PersistentFlags().Bool(keyExplain, false, "Print the preprocessed template and effective options to stderr")
+	rootCmd.PersistentFlags().Int(keyHead, 0, "Stop after writing this many records, not counting skipped records (0 for no limit)")
+	rootCmd.PersistentFlags().Int(keyTail, 0, "Only write the last this many records, once the input ends, not counting skipped records (0 for no limit)")
+	rootCmd.PersistentFlags().StringSlice(keyColumns, []string{}, "Write these fields as aligned columns under a header, instead of using the template (e.g. --columns level,status,path)")
+	rootCmd.PersistentFlags().Int(keyColumnsBatch, formatter.DefaultColumnsBatch, "Number of records aligned together with --columns, which are held back until the batch is full or the input pauses")
+	rootCmd.PersistentFlags().String(keyGroupBy, "", "Write a header whenever the value of this field changes and indent the records under it (e.g. --group_by tenant)")
+	rootCmd.PersistentFlags().StringSlice(keyUniqueBy, []string{}, "Only write the first record with each distinct value of these fields (e.g. --unique_by error_code)")
+	rootCmd.PersistentFlags().Int(keyUniqueCap, 0, "Most distinct values --unique_by remembers, forgetting the oldest beyond it (0 for no limit)")
+	rootCmd.PersistentFlags().Int(keySample, 0, "Only write every nth record, not counting skipped records (e.g. --sample 100)")
+	rootCmd.PersistentFlags().Float64(keySampleRate, 0, "Write each record with this probability, between 0 and 1 (e.g. --sample_rate 0.01)")
+	rootCmd.PersistentFlags().Uint64(keySampleSeed, 0, "Seed for --sample_rate, to sample the same records on every run (0 for a random seed)")
+	rootCmd.PersistentFlags().String(keySplitStreams, "", "Write records at this level or above to stderr and the rest to stdout (e.g. --split_streams=warn); --split_streams alone splits off errors. Each stream is buffered separately, so when both go to the same place records may appear out of order across them unless --line_buffered is set")
+	rootCmd.PersistentFlags().Lookup(keySplitStreams).NoOptDefVal = "error"
+	rootCmd.PersistentFlags().Float64(keyReplay, 0, "Pace output by the records' timestamps, sped up by this factor (e.g. --replay=2); --replay alone plays back in real time")
+	rootCmd.PersistentFlags().Lookup(keyReplay).NoOptDefVal = "1"
+	rootCmd.PersistentFlags().Duration(keyReplayMaxGap, formatter.DefaultReplayMaxGap, "Longest pause between records with --replay")
+	rootCmd.PersistentFlags().Bool(keyDryRun, false, "Exit after validating the template, without processing input (implies --explain)")
 
 	// Bind flags to viper
 	if err := viper.BindPFlag(keyFormat, rootCmd.PersistentFlags().Lookup(keyFormat)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keyFormat, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keyFormat, err)
+	}
+	if err := viper.BindPFlag(keyPreset, rootCmd.PersistentFlags().Lookup(keyPreset)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyPreset, err)
+	}
+	if err := viper.BindPFlag(keyTemplateName, rootCmd.PersistentFlags().Lookup(keyTemplateName)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyTemplateName, err)
+	}
+	if err := viper.BindPFlag(keyNoAuto, rootCmd.PersistentFlags().Lookup(keyNoAuto)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyNoAuto, err)
+	}
+	if err := viper.BindPFlag(keyLevelBadges, rootCmd.PersistentFlags().Lookup(keyLevelBadges)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyLevelBadges, err)
+	}
+	if err := viper.BindPFlag(keyRawFormat, rootCmd.PersistentFlags().Lookup(keyRawFormat)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyRawFormat, err)
 	}
 	if err := viper.BindPFlag(keyDateFormat, rootCmd.PersistentFlags().Lookup(keyDateFormat)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keyDateFormat, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keyDateFormat, err)
 	}
 	if err := viper.BindPFlag(keyNoColors, rootCmd.PersistentFlags().Lookup(keyNoColors)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keyNoColors, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keyNoColors, err)
 	}
 	if err := viper.BindPFlag(keyEnableSimple, rootCmd.PersistentFlags().Lookup(keyEnableSimple)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keyEnableSimple, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keyEnableSimple, err)
 	}
 	if err := viper.BindPFlag(keySkip, rootCmd.PersistentFlags().Lookup(keySkip)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keySkip, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keySkip, err)
+	}
+	if err := viper.BindPFlag(keySkipCheck, rootCmd.PersistentFlags().Lookup(keySkipCheck)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySkipCheck, err)
 	}
 	if err := viper.BindPFlag(keyHandleNonJSON, rootCmd.PersistentFlags().Lookup(keyHandleNonJSON)); err != nil {
-		fmt.Fprintf(os.Stderr, "Error binding flag %s: %v\n", keyHandleNonJSON, err)
+		diagnostics.Errorf("failed to bind flag %s: %v", keyHandleNonJSON, err)
+	}
+	if err := viper.BindPFlag(keyFormatFile, rootCmd.PersistentFlags().Lookup(keyFormatFile)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyFormatFile, err)
+	}
+	if err := viper.BindPFlag(keyWatchTemplate, rootCmd.PersistentFlags().Lookup(keyWatchTemplate)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyWatchTemplate, err)
+	}
+	if err := viper.BindPFlag(keyCompactNonJSON, rootCmd.PersistentFlags().Lookup(keyCompactNonJSON)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyCompactNonJSON, err)
+	}
+	if err := viper.BindPFlag(keyComments, rootCmd.PersistentFlags().Lookup(keyComments)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyComments, err)
+	}
+	if err := viper.BindPFlag(keyCommentPrefix, rootCmd.PersistentFlags().Lookup(keyCommentPrefix)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyCommentPrefix, err)
+	}
+	if err := viper.BindPFlag(keyReconnect, rootCmd.PersistentFlags().Lookup(keyReconnect)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyReconnect, err)
+	}
+	if err := viper.BindPFlag(keyMerge, rootCmd.PersistentFlags().Lookup(keyMerge)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyMerge, err)
+	}
+	if err := viper.BindPFlag(keyStrictEquality, rootCmd.PersistentFlags().Lookup(keyStrictEquality)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyStrictEquality, err)
+	}
+	if err := viper.BindPFlag(keyRoundDurations, rootCmd.PersistentFlags().Lookup(keyRoundDurations)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyRoundDurations, err)
+	}
+	if err := viper.BindPFlag(keyDurationStyle, rootCmd.PersistentFlags().Lookup(keyDurationStyle)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyDurationStyle, err)
+	}
+	if err := viper.BindPFlag(keyMaxFieldLength, rootCmd.PersistentFlags().Lookup(keyMaxFieldLength)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyMaxFieldLength, err)
+	}
+	if err := viper.BindPFlag(keyEscapeNewlines, rootCmd.PersistentFlags().Lookup(keyEscapeNewlines)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyEscapeNewlines, err)
+	}
+	if err := viper.BindPFlag(keyNormalizeLevel, rootCmd.PersistentFlags().Lookup(keyNormalizeLevel)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyNormalizeLevel, err)
+	}
+	if err := viper.BindPFlag(keyNormalizeTime, rootCmd.PersistentFlags().Lookup(keyNormalizeTime)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyNormalizeTime, err)
+	}
+	if err := viper.BindPFlag(keyLift, rootCmd.PersistentFlags().Lookup(keyLift)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyLift, err)
+	}
+	if err := viper.BindPFlag(keyLiftOverwrite, rootCmd.PersistentFlags().Lookup(keyLiftOverwrite)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyLiftOverwrite, err)
+	}
+	if err := viper.BindPFlag(keyNormalizeKeys, rootCmd.PersistentFlags().Lookup(keyNormalizeKeys)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyNormalizeKeys, err)
+	}
+	if err := viper.BindPFlag(keyInputFormat, rootCmd.PersistentFlags().Lookup(keyInputFormat)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyInputFormat, err)
+	}
+	if err := viper.BindPFlag(keyRecordDelim, rootCmd.PersistentFlags().Lookup(keyRecordDelim)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyRecordDelim, err)
+	}
+	if err := viper.BindPFlag(keySmartFields, rootCmd.PersistentFlags().Lookup(keySmartFields)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySmartFields, err)
+	}
+	if err := viper.BindPFlag(keyPrettyMaxDepth, rootCmd.PersistentFlags().Lookup(keyPrettyMaxDepth)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyPrettyMaxDepth, err)
+	}
+	if err := viper.BindPFlag(keyHead, rootCmd.PersistentFlags().Lookup(keyHead)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyHead, err)
+	}
+	if err := viper.BindPFlag(keyTail, rootCmd.PersistentFlags().Lookup(keyTail)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyTail, err)
+	}
+	if err := viper.BindPFlag(keyColumns, rootCmd.PersistentFlags().Lookup(keyColumns)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyColumns, err)
+	}
+	if err := viper.BindPFlag(keyColumnsBatch, rootCmd.PersistentFlags().Lookup(keyColumnsBatch)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyColumnsBatch, err)
+	}
+	if err := viper.BindPFlag(keyUniqueBy, rootCmd.PersistentFlags().Lookup(keyUniqueBy)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyUniqueBy, err)
+	}
+	if err := viper.BindPFlag(keyGroupBy, rootCmd.PersistentFlags().Lookup(keyGroupBy)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyGroupBy, err)
+	}
+	if err := viper.BindPFlag(keyUniqueCap, rootCmd.PersistentFlags().Lookup(keyUniqueCap)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyUniqueCap, err)
+	}
+	if err := viper.BindPFlag(keySample, rootCmd.PersistentFlags().Lookup(keySample)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySample, err)
+	}
+	if err := viper.BindPFlag(keySampleRate, rootCmd.PersistentFlags().Lookup(keySampleRate)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySampleRate, err)
+	}
+	if err := viper.BindPFlag(keySampleSeed, rootCmd.PersistentFlags().Lookup(keySampleSeed)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySampleSeed, err)
+	}
+	if err := viper.BindPFlag(keySplitStreams, rootCmd.PersistentFlags().Lookup(keySplitStreams)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySplitStreams, err)
+	}
+	if err := viper.BindPFlag(keyReplay, rootCmd.PersistentFlags().Lookup(keyReplay)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyReplay, err)
+	}
+	if err := viper.BindPFlag(keyReplayMaxGap, rootCmd.PersistentFlags().Lookup(keyReplayMaxGap)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyReplayMaxGap, err)
+	}
+	if err := viper.BindPFlag(keyIndent, rootCmd.PersistentFlags().Lookup(keyIndent)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyIndent, err)
+	}
+	if err := viper.BindPFlag(keySingleLine, rootCmd.PersistentFlags().Lookup(keySingleLine)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySingleLine, err)
+	}
+	if err := viper.BindPFlag(keySingleLineSep, rootCmd.PersistentFlags().Lookup(keySingleLineSep)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySingleLineSep, err)
+	}
+	if err := viper.BindPFlag(keyColorDepth, rootCmd.PersistentFlags().Lookup(keyColorDepth)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyColorDepth, err)
+	}
+	if err := viper.BindPFlag(keySet, rootCmd.PersistentFlags().Lookup(keySet)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySet, err)
+	}
+	if err := viper.BindPFlag(keyHighlight, rootCmd.PersistentFlags().Lookup(keyHighlight)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyHighlight, err)
+	}
+	if err := viper.BindPFlag(keyPrefix, rootCmd.PersistentFlags().Lookup(keyPrefix)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyPrefix, err)
+	}
+	if err := viper.BindPFlag(keySuffix, rootCmd.PersistentFlags().Lookup(keySuffix)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySuffix, err)
+	}
+	if err := viper.BindPFlag(keyMapSort, rootCmd.PersistentFlags().Lookup(keyMapSort)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyMapSort, err)
+	}
+	if err := viper.BindPFlag(keyKVSeparator, rootCmd.PersistentFlags().Lookup(keyKVSeparator)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyKVSeparator, err)
+	}
+	if err := viper.BindPFlag(keySingle, rootCmd.PersistentFlags().Lookup(keySingle)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySingle, err)
+	}
+	if err := viper.BindPFlag(keyWatch, rootCmd.PersistentFlags().Lookup(keyWatch)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyWatch, err)
+	}
+	if err := viper.BindPFlag(keyLineBuffered, rootCmd.PersistentFlags().Lookup(keyLineBuffered)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyLineBuffered, err)
+	}
+	if err := viper.BindPFlag(keyOutput, rootCmd.PersistentFlags().Lookup(keyOutput)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyOutput, err)
+	}
+	if err := viper.BindPFlag(keyAppend, rootCmd.PersistentFlags().Lookup(keyAppend)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyAppend, err)
+	}
+	if err := viper.BindPFlag(keySplit, rootCmd.PersistentFlags().Lookup(keySplit)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keySplit, err)
+	}
+	if err := viper.BindPFlag(keyOutputDir, rootCmd.PersistentFlags().Lookup(keyOutputDir)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyOutputDir, err)
+	}
+	if err := viper.BindPFlag(keyCountBy, rootCmd.PersistentFlags().Lookup(keyCountBy)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyCountBy, err)
+	}
+	if err := viper.BindPFlag(keyFlushInterval, rootCmd.PersistentFlags().Lookup(keyFlushInterval)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyFlushInterval, err)
+	}
+	if err := viper.BindPFlag(keyQuiet, rootCmd.PersistentFlags().Lookup(keyQuiet)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyQuiet, err)
+	}
+	if err := viper.BindPFlag(keyLogFormat, rootCmd.PersistentFlags().Lookup(keyLogFormat)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyLogFormat, err)
+	}
+	if err := viper.BindPFlag(keyExplain, rootCmd.PersistentFlags().Lookup(keyExplain)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyExplain, err)
+	}
+	if err := viper.BindPFlag(keyDryRun, rootCmd.PersistentFlags().Lookup(keyDryRun)); err != nil {
+		diagnostics.Errorf("failed to bind flag %s: %v", keyDryRun, err)
 	}
 
 	// Set environment variable prefix
@@ -78,77 +457,1106 @@ func init() { //nolint:gochecknoinits // Required for cobra command initializati
 	viper.AutomaticEnv()
 }
 
-// initConfig reads in config file and ENV variables if set
+// initConfig reads in config files and ENV variables if set
 func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
+	// Read in environment variables that match
+	viper.AutomaticEnv()
 
-		// Search config in home directory with name ".logista" (without extension)
-		viper.AddConfigPath(home)
-		viper.AddConfigPath(".")
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".logista")
+	// Configure diagnostics from flags and the environment before reading the
+	// config files, so messages about them honor --quiet, then again in case
+	// the config files change the settings
+	configureDiagnostics()
+	configFilesUsed = loadConfigFiles()
+	configureDiagnostics()
+}
+
+// configureDiagnostics applies the quiet and log format settings
+func configureDiagnostics() {
+	diagnostics.SetQuiet(viper.GetBool(keyQuiet))
+	if err := diagnostics.SetFormat(viper.GetString(keyLogFormat)); err != nil {
+		diagnostics.Warnf("%v", err)
 	}
+}
 
-	// Read in environment variables that match
-	viper.AutomaticEnv()
+// loadConfigFiles reads the config files, returning the paths of the files
+// that were loaded. It can be called again to reload the configuration.
+//
+// Config files are merged in the following order, with later files overriding
+// earlier ones:
+//
+//  1. The base config (--config or the first found by configSearchPaths)
+//  2. All config files in $LOGISTA_CONFIG_DIR, in lexical order
+//  3. The per-user overlay, such as .logista.local.yaml, found alongside the
+//     base config
+//
+// Environment variables and command-line flags take precedence over all
+// config files.
+func loadConfigFiles() []string {
+	// Use the config file from the flag, or search for one
+	configFile := cfgFile
+	if configFile == "" {
+		configFile = findConfigFile(configSearchPaths(userHomeDir()))
+	}
 
 	// If a config file is found, read it in
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	var loaded []string
+	baseConfig := ""
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		viper.SetConfigType(strings.TrimPrefix(filepath.Ext(configFile), "."))
+		if err := viper.ReadInConfig(); err == nil {
+			baseConfig = viper.ConfigFileUsed()
+			loaded = append(loaded, baseConfig)
+			diagnostics.Infof("Using config file: %s", baseConfig)
+		}
 	}
+
+	// Merge shared config fragments from the config directory
+	if dir := os.Getenv(envConfigDir); dir != "" {
+		var matches []string
+		for _, ext := range configTypes {
+			found, err := filepath.Glob(filepath.Join(dir, "*."+ext))
+			if err != nil {
+				diagnostics.Warnf("invalid config directory %s: %v", dir, err)
+				break
+			}
+			matches = append(matches, found...)
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			if mergeConfigFile(path) {
+				loaded = append(loaded, path)
+			}
+		}
+	}
+
+	// Finally apply the per-user overlay
+	if overlay := findLocalConfig(baseConfig); overlay != "" && mergeConfigFile(overlay) {
+		loaded = append(loaded, overlay)
+	}
+
+	return loaded
+}
+
+// userHomeDir returns the home directory, or an empty string if it isn't
+// known, such as when HOME isn't set under cron or in a container
+func userHomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
+
+// configSearchPaths returns the paths, without an extension, where the base
+// config is looked for when --config isn't given, in order: .logista in the
+// home directory, config in the logista directory of $XDG_CONFIG_HOME
+// (~/.config by default), and .logista in the current directory. Paths in
+// the home directory are left out if home is empty.
+func configSearchPaths(home string) []string {
+	var paths []string
+	if home != "" {
+		paths = append(paths, filepath.Join(home, configName))
+	}
+
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgHome == "" && home != "" {
+		xdgHome = filepath.Join(home, ".config")
+	}
+	if xdgHome != "" {
+		paths = append(paths, filepath.Join(xdgHome, xdgConfigDir, xdgConfigName))
+	}
+
+	return append(paths, configName)
+}
+
+// findConfigFile returns the first of the paths that exists with one of the
+// config file extensions, or an empty string if none do
+func findConfigFile(paths []string) string {
+	for _, path := range paths {
+		for _, ext := range configTypes {
+			if info, err := os.Stat(path + "." + ext); err == nil && !info.IsDir() {
+				return path + "." + ext
+			}
+		}
+	}
+	return ""
+}
+
+// localConfigPath returns the path of the overlay for a config file, which
+// has .local before the extension, e.g. team.yaml -> team.local.yaml
+func localConfigPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+// findLocalConfig returns the path of the per-user overlay config, or an empty
+// string if none exists. The overlay lives next to the base config file; when
+// no base config was loaded the default search paths are used.
+func findLocalConfig(baseConfig string) string {
+	var candidates []string
+	switch {
+	case cfgFile != "":
+		candidates = []string{localConfigPath(cfgFile)}
+	case baseConfig != "":
+		candidates = []string{localConfigPath(baseConfig)}
+	default:
+		var paths []string
+		for _, path := range configSearchPaths(userHomeDir()) {
+			paths = append(paths, path+".local")
+		}
+		return findConfigFile(paths)
+	}
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// mergeConfigFile merges the given config file into the current
+// configuration, reporting whether it succeeded
+func mergeConfigFile(path string) bool {
+	file, err := os.Open(path) //nolint:gosec // Reading user-specified config files is intended
+	if err == nil {
+		defer file.Close()
+		viper.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+		err = viper.MergeConfig(file)
+	}
+	if err != nil {
+		diagnostics.Warnf("failed to merge config file %s: %v", path, err)
+		return false
+	}
+	diagnostics.Infof("Merged config file: %s", path)
+	return true
+}
+
+// warnMissingSkipFields warns that no record read had the fields of some
+// skip patterns
+func warnMissingSkipFields(records int, fields []string) {
+	noun := "records have"
+	if records == 1 {
+		noun = "record has"
+	}
+	diagnostics.Warnf("none of the first %d %s the --%s field %s; check for typos",
+		records, noun, keySkip, strings.Join(fields, ", "))
+}
+
+// checkArgs accepts a single input file argument, or several when merging
+func checkArgs(cmd *cobra.Command, args []string) error {
+	if viper.GetBool(keyMerge) {
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("accepts at most 1 file argument, received %d (use --%s to read several)", len(args), keyMerge)
+	}
+	return nil
 }
 
 // runLogista is the main function that processes the log stream
 func runLogista(cmd *cobra.Command, args []string) error {
+	if err := readStdinFormat(args, os.Stdin); err != nil {
+		return err
+	}
+
+	formatTemplate, err := loadFormatTemplate()
+	if err != nil {
+		return err
+	}
+
+	skipPatterns := parseSkipPatterns()
+
+	if _, err := formatter.NewInputParser(viper.GetString(keyInputFormat)); err != nil {
+		return err
+	}
+	if _, err := parseRecordDelimiter(); err != nil {
+		return err
+	}
+	if _, err := parseHighlight(); err != nil {
+		return err
+	}
+
+	split, err := parseSplit()
+	if err != nil {
+		return err
+	}
+
+	// Explain the effective configuration before parsing, so it is shown even
+	// when the template is invalid
+	dryRun := viper.GetBool(keyDryRun)
+	if dryRun || viper.GetBool(keyExplain) {
+		explainConfig(os.Stderr, formatTemplate, preprocessOptions(), skipPatterns)
+	}
+
+	// Count records by a field, summarizing them once the stream ends
+	var streamOptions []formatter.FormatterOption
+	summarize := func() {}
+	if field := viper.GetString(keyCountBy); field != "" {
+		counter := formatter.NewFieldCounter(field)
+		streamOptions = append(streamOptions, formatter.WithFieldCounter(counter))
+		noColors := viper.GetBool(keyNoColors) || (!viper.IsSet(keyNoColors) && (noColorEnv() || !showsColors(os.Stderr)))
+		summarize = func() {
+			if err := counter.WriteSummary(os.Stderr, noColors); err != nil {
+				diagnostics.Errorf("failed to write summary: %v", err)
+			}
+		}
+	}
+
+	// Drop repeated values and thin out the stream, then limit output to the
+	// first or last records
+	if viper.GetInt(keyUniqueCap) < 0 {
+		return fmt.Errorf("--%s must not be negative", keyUniqueCap)
+	}
+	if fields := viper.GetStringSlice(keyUniqueBy); len(fields) > 0 {
+		streamOptions = append(streamOptions,
+			formatter.WithUniqueBy(fields...),
+			formatter.WithUniqueCap(viper.GetInt(keyUniqueCap)))
+	}
+	sampleOptions, err := parseSampling()
+	if err != nil {
+		return err
+	}
+	if field := viper.GetString(keyGroupBy); field != "" {
+		if viper.GetInt(keyTail) > 0 {
+			return fmt.Errorf("--%s can't be combined with --%s", keyGroupBy, keyTail)
+		}
+		streamOptions = append(streamOptions, formatter.WithGroupBy(field))
+	}
+
+	// Point out skip patterns whose fields never appear, which are likely typos
+	streamOptions = append(streamOptions, formatter.WithSkipFieldCheck(viper.GetInt(keySkipCheck), warnMissingSkipFields))
+	streamOptions = append(streamOptions, sampleOptions...)
+	for _, key := range []string{keyHead, keyTail} {
+		if viper.GetInt(key) < 0 {
+			return fmt.Errorf("--%s must not be negative", key)
+		}
+	}
+	streamOptions = append(streamOptions,
+		formatter.WithHead(viper.GetInt(keyHead)),
+		formatter.WithTail(viper.GetInt(keyTail)))
+
+	// Align fields into columns across records instead of using the template
+	if columns := viper.GetStringSlice(keyColumns); len(columns) > 0 {
+		if viper.GetInt(keyColumnsBatch) < 1 {
+			return fmt.Errorf("--%s must be at least 1", keyColumnsBatch)
+		}
+		streamOptions = append(streamOptions, formatter.WithColumns(columns, viper.GetInt(keyColumnsBatch)))
+	}
+
+	// Write severe records to stderr, if splitting streams
+	if level := viper.GetString(keySplitStreams); level != "" {
+		if viper.GetInt(keyTail) > 0 {
+			return fmt.Errorf("--%s can't be combined with --%s", keySplitStreams, keyTail)
+		}
+		if len(viper.GetStringSlice(keyColumns)) > 0 {
+			return fmt.Errorf("--%s can't be combined with --%s", keySplitStreams, keyColumns)
+		}
+		// Buffer stderr the same way as stdout
+		errOut := formatter.NewBufferedWriter(os.Stderr, flushInterval(formatter.IsTerminal(os.Stderr)))
+		defer errOut.Close() //nolint:errcheck // ProcessStream already reports flush errors
+		streamOptions = append(streamOptions, formatter.WithLevelWriter(errOut, level))
+	}
+
+	// Pace output by the records' timestamps, if replaying
+	if speed := viper.GetFloat64(keyReplay); speed < 0 {
+		return fmt.Errorf("--%s speed must be positive", keyReplay)
+	} else if speed > 0 {
+		streamOptions = append(streamOptions, formatter.WithReplay(speed, viper.GetDuration(keyReplayMaxGap)))
+	}
+
+	// Create the formatter with format template, preprocessor options, and formatter options
+	tmplFormatter, err := newFormatter(formatTemplate, streamOptions...)
+	if err != nil {
+		return fmt.Errorf("invalid format template: %w", err)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	// Without a template, pick a preset matching the logger that wrote the
+	// first record
+	active := withSchemaDetection(tmplFormatter)
+
+	// Read from the file argument, or stdin if there isn't one
+	var input io.Reader
+	var inputs []formatter.NamedInput
+	if viper.GetBool(keyMerge) {
+		if viper.GetBool(keySingle) || viper.GetBool(keyReconnect) {
+			return fmt.Errorf("--%s can't be combined with --%s or --%s", keyMerge, keySingle, keyReconnect)
+		}
+		paths := args
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+		for _, path := range paths {
+			merged, err := openInput(path, false)
+			if err != nil {
+				return err
+			}
+			defer merged.Close() //nolint:errcheck // Nothing useful to do if closing the input fails
+			inputs = append(inputs, formatter.NamedInput{Name: path, Reader: merged})
+		}
+	} else {
+		var inputPath string
+		if len(args) > 0 {
+			inputPath = args[0]
+		}
+		single, err := openInput(inputPath, viper.GetBool(keyReconnect))
+		if err != nil {
+			return err
+		}
+		defer single.Close() //nolint:errcheck // Nothing useful to do if closing the input fails
+		input = single
+	}
+
+	// Write to the output file, or stdout if there isn't one
+	output, err := openOutput(viper.GetString(keyOutput), viper.GetBool(keyAppend))
+	if err != nil {
+		return err
+	}
+	if output != os.Stdout {
+		defer output.Close() //nolint:errcheck // Output is flushed before closing, which reports write errors
+	}
+
+	// Format exactly one object, without the streaming loop
+	if viper.GetBool(keySingle) {
+		return tmplFormatter.ProcessSingle(input, output, active)
+	}
+
+	// Get the handleNonJSON flag value
+	handleNonJSON := viper.GetBool(keyHandleNonJSON)
+
+	// The active formatter and skip patterns can be swapped while the stream
+	// is running when watching the template or config files
+	reloadable := formatter.NewReloadableFormatter(active)
+
+	// Reload the template whenever the template file changes, keeping the last
+	// good template active if the new one fails to parse
+	if viper.GetBool(keyWatchTemplate) {
+		formatFile := viper.GetString(keyFormatFile)
+		if formatFile == "" {
+			return fmt.Errorf("--%s requires --%s", keyWatchTemplate, keyFormatFile)
+		}
+
+		stop, err := watchFile(formatFile, func() {
+			configMu.Lock()
+			defer configMu.Unlock()
+
+			format, err := readFormatFile(formatFile)
+			if err == nil {
+				var reloaded *formatter.TemplateFormatter
+				if reloaded, err = newFormatter(format); err == nil {
+					reloadable.Set(reloaded)
+					diagnostics.Infof("Reloaded template: %s", formatFile)
+					return
+				}
+			}
+			diagnostics.Warnf("keeping previous template, reload failed: %v", err)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch template file: %w", err)
+		}
+		defer stop() //nolint:errcheck // Nothing useful to do if closing the watcher fails
+	}
+
+	// Reload the template and skip patterns whenever a config file changes
+	if viper.GetBool(keyWatch) {
+		stop, err := watchConfig(reloadable)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	// Split output into files in the output directory instead, if configured
+	var dest io.Writer = output
+	terminal := formatter.IsTerminal(output)
+	if split != nil {
+		rotating := formatter.NewRotatingWriter(viper.GetString(keyOutputDir), *split)
+		defer rotating.Close() //nolint:errcheck // Files are only closed after the final flush, which reports write errors
+		dest, terminal = rotating, false
+	}
+
+	// Buffer output, making sure it is flushed at EOF and on interrupt
+	if viper.GetDuration(keyFlushInterval) > 0 && viper.GetBool(keyLineBuffered) {
+		diagnostics.Warnf("--%s overrides --%s", keyLineBuffered, keyFlushInterval)
+	}
+	out := formatter.NewBufferedWriter(dest, flushInterval(terminal))
+	defer out.Close() //nolint:errcheck // ProcessStream already reports flush errors
+
+	// Report writes to a closed pipe as errors rather than being killed by
+	// SIGPIPE, so the stream can end cleanly
+	signal.Ignore(syscall.SIGPIPE)
+
+	// Stop reading on interrupt, so the stream ends the usual way, writing
+	// any held back records, flushing and summarizing. A second interrupt
+	// kills logista at once, in case that blocks.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	reloadable.SetSkipPatterns(skipPatterns)
+	if inputs != nil {
+		err = tmplFormatter.ProcessMergedStreams(ctx, inputs, out, reloadable, nil, handleNonJSON)
+	} else {
+		err = tmplFormatter.ProcessStreamContext(ctx, input, out, reloadable, nil, handleNonJSON)
+	}
+	summarize()
+
+	if ctx.Err() != nil {
+		cmd.SilenceErrors, cmd.SilenceUsage = true, true
+		return errInterrupted
+	}
+
+	// The reader going away (e.g. piping into head) isn't an error
+	if errors.Is(err, formatter.ErrOutputClosed) {
+		return nil
+	}
+	return err
+}
+
+// watchConfig watches the loaded config files, rebuilding the formatter and
+// skip patterns whenever one changes. If the new config has an invalid
+// template the previous formatter and skip patterns are kept.
+func watchConfig(reloadable *formatter.ReloadableFormatter) (func(), error) {
+	files := configFilesUsed
+	if len(files) == 0 {
+		return nil, fmt.Errorf("--%s requires a config file", keyWatch)
+	}
+
+	reload := func() {
+		configMu.Lock()
+		defer configMu.Unlock()
+
+		loadConfigFiles()
+		format, err := loadFormatTemplate()
+		if err == nil {
+			var reloaded *formatter.TemplateFormatter
+			if reloaded, err = newFormatter(format); err == nil {
+				reloadable.Set(withSchemaDetection(reloaded))
+				reloadable.SetSkipPatterns(parseSkipPatterns())
+				diagnostics.Infof("Reloaded config")
+				return
+			}
+		}
+		diagnostics.Warnf("keeping previous config, reload failed: %v", err)
+	}
+
+	var stops []func() error
+	stopAll := func() {
+		for _, stop := range stops {
+			_ = stop()
+		}
+	}
+	for _, path := range files {
+		stop, err := watchFile(path, reload)
+		if err != nil {
+			stopAll()
+			return nil, fmt.Errorf("failed to watch config file: %w", err)
+		}
+		stops = append(stops, stop)
+	}
+
+	return stopAll, nil
+}
+
+// loadFormatTemplate returns the format template from the template file if
+// one is given, then from the named template, then from an explicitly set
+// format, then from the preset, falling back to the default format, which
+// shows level badges with --level_badges
+func loadFormatTemplate() (string, error) {
+	if formatFile := viper.GetString(keyFormatFile); formatFile != "" {
+		return readFormatFile(formatFile)
+	}
+	if name := viper.GetString(keyTemplateName); name != "" {
+		return namedTemplate(name)
+	}
+	if preset := viper.GetString(keyPreset); preset != "" {
+		format, err := formatter.Preset(preset)
+		if err != nil {
+			return "", err
+		}
+		if !viper.IsSet(keyFormat) {
+			return format, nil
+		}
+	}
+	if viper.GetBool(keyLevelBadges) && !viper.IsSet(keyFormat) {
+		return badgeFormat, nil
+	}
+	return templateFlag(keyFormat)
+}
+
+// namedTemplate returns a template from the templates map in config. Config
+// keys are case-insensitive, so template names are too.
+func namedTemplate(name string) (string, error) {
+	templates := viper.GetStringMapString(keyTemplates)
+	if format, ok := templates[strings.ToLower(name)]; ok {
+		return format, nil
+	}
+
+	if len(templates) == 0 {
+		return "", fmt.Errorf("unknown template %q: no templates are defined in the config file", name)
+	}
+	names := make([]string, 0, len(templates))
+	for templateName := range templates {
+		names = append(names, templateName)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// preprocessOptions returns the template preprocessor options from config
+func preprocessOptions() formatter.PreProcessTemplateOptions {
+	options := formatter.DefaultPreProcessTemplateOptions()
+	options.EnableSimpleSyntax = viper.GetBool(keyEnableSimple)
+	return options
+}
+
+// newFormatter creates a formatter for the given template, using the
+// formatter options from config followed by any extra options
+func newFormatter(formatTemplate string, extra ...formatter.FormatterOption) (*formatter.TemplateFormatter, error) {
+	parser, err := formatter.NewInputParser(viper.GetString(keyInputFormat))
+	if err != nil {
+		return nil, err
+	}
+	delim, err := parseRecordDelimiter()
+	if err != nil {
+		return nil, err
+	}
+	highlight, err := parseHighlight()
+	if err != nil {
+		return nil, err
+	}
+
 	// Apply options from configuration
 	options := []formatter.FormatterOption{
 		formatter.WithPreferredDateFormat(viper.GetString(keyDateFormat)),
+		formatter.WithInputParser(parser),
+		formatter.WithRecordDelimiter(delim),
+		formatter.WithColorDepth(viper.GetString(keyColorDepth)),
+		formatter.WithMapSort(viper.GetString(keyMapSort)),
+		formatter.WithPrettyMaxDepth(viper.GetInt(keyPrettyMaxDepth)),
+		formatter.WithIndent(viper.GetInt(keyIndent)),
+		formatter.WithCompactNonJSON(viper.GetBool(keyCompactNonJSON)),
+		formatter.WithComments(viper.GetString(keyComments), viper.GetString(keyCommentPrefix)),
+		formatter.WithKeyNormalization(viper.GetString(keyNormalizeKeys)),
+		formatter.WithLiftedFields(viper.GetStringSlice(keyLift)...),
+		formatter.WithLiftOverwrite(viper.GetBool(keyLiftOverwrite)),
+		formatter.WithLevelNormalization(viper.GetString(keyNormalizeLevel)),
+		formatter.WithTimeNormalization(viper.GetString(keyNormalizeTime)),
+		formatter.WithStrictEquality(viper.GetBool(keyStrictEquality)),
+		formatter.WithDurationRounding(viper.GetBool(keyRoundDurations)),
+		formatter.WithDurationStyle(viper.GetString(keyDurationStyle)),
+		formatter.WithMaxFieldLength(viper.GetInt(keyMaxFieldLength)),
+		formatter.WithEscapeNewlines(viper.GetBool(keyEscapeNewlines)),
 	}
 
-	// Add no-colors option if set
-	if viper.GetBool(keyNoColors) {
+	// Disable colors if set, or by default when NO_COLOR is set or when
+	// writing to a file. Otherwise
+	// strip them from pipes and from Windows consoles that can't show them,
+	// deciding for stdout and stderr separately when streams are split.
+	switch {
+	case viper.GetBool(keyNoColors):
+		options = append(options, formatter.WithNoColors(true))
+	case viper.IsSet(keyNoColors):
+		// Colors were asked for, so keep them everywhere
+	case noColorEnv(), writingToFile():
 		options = append(options, formatter.WithNoColors(true))
+	default:
+		options = append(options, formatter.WithColorOutput(showsColors))
 	}
 
-	// Get format template from config
-	formatTemplate := viper.GetString(keyFormat)
+	// Only override the default separators when explicitly configured
+	if viper.IsSet(keyKVSeparator) {
+		options = append(options, formatter.WithKVSeparator(viper.GetString(keyKVSeparator)))
+	}
 
-	// Create preprocessor options
-	preprocessOptions := formatter.DefaultPreProcessTemplateOptions()
-	preprocessOptions.EnableSimpleSyntax = viper.GetBool(keyEnableSimple)
+	if viper.GetBool(keySingleLine) {
+		options = append(options, formatter.WithSingleLine(viper.GetString(keySingleLineSep)))
+	}
 
-	// Create the formatter with format template, preprocessor options, and formatter options
-	tmplFormatter, err := formatter.NewTemplateFormatterWithOptions(formatTemplate, preprocessOptions, options...)
+	if rules := parseSmartFields(); len(rules) > 0 {
+		options = append(options, formatter.WithSmartFieldFormatting(rules))
+	}
+
+	// Style aliases are only read from the config file
+	if aliases := viper.GetStringMapStringSlice(keyStyles); len(aliases) > 0 {
+		options = append(options, formatter.WithStyleAliases(aliases))
+	}
+
+	if fields := parseDerivedFields(); len(fields) > 0 {
+		options = append(options, formatter.WithDerivedFields(fields...))
+	}
+
+	if highlight != nil {
+		options = append(options, formatter.WithHighlight(highlight))
+	}
+
+	prefix, err := templateFlag(keyPrefix)
 	if err != nil {
-		return fmt.Errorf("invalid format template: %w", err)
+		return nil, err
+	}
+	suffix, err := templateFlag(keySuffix)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" || suffix != "" {
+		options = append(options, formatter.WithLineAffixes(prefix, suffix))
+	}
+
+	if commands := parseFieldCommands(); len(commands) > 0 {
+		options = append(options,
+			formatter.WithFieldCommands(commands...),
+			formatter.WithFieldCommandTimeout(execTimeout))
+	}
+
+	options = append(options, extra...)
+	return formatter.NewTemplateFormatterWithOptions(formatTemplate, preprocessOptions(), options...)
+}
+
+// withSchemaDetection wraps a formatter for the default template so that it
+// switches to the preset for the logger that wrote the first record, unless
+// a template, template name or preset was given, --level_badges picked the
+// badge template or --no_auto is set
+func withSchemaDetection(tf *formatter.TemplateFormatter) formatter.Formatter {
+	if viper.GetBool(keyNoAuto) || viper.GetString(keyFormatFile) != "" ||
+		viper.GetString(keyTemplateName) != "" || viper.GetString(keyPreset) != "" ||
+		viper.IsSet(keyFormat) || viper.GetBool(keyLevelBadges) {
+		return tf
+	}
+
+	// Formatters are built on the stream's goroutine, which may run while a
+	// config reload writes to viper
+	build := func(schema string) (formatter.Formatter, error) {
+		format, err := formatter.Preset(schema)
+		if err != nil {
+			return nil, err
+		}
+		configMu.Lock()
+		defer configMu.Unlock()
+		return newFormatter(format)
+	}
+	return formatter.NewAutoFormatter(tf, build, func(schema string) {
+		diagnostics.Infof("Detected %s logs, using the %s preset (disable with --%s)", schema, schema, keyNoAuto)
+	})
+}
+
+// flushInterval returns how often output should be flushed. A positive
+// --flush_interval batches output, unless --line_buffered is set too.
+// Otherwise output is flushed after every record if --line_buffered is set,
+// which is the default when writing to a terminal, and only when the buffer
+// fills if not.
+func flushInterval(terminal bool) time.Duration {
+	lineBuffered := terminal
+	if viper.IsSet(keyLineBuffered) {
+		lineBuffered = viper.GetBool(keyLineBuffered)
+	}
+
+	if interval := viper.GetDuration(keyFlushInterval); interval > 0 {
+		if viper.IsSet(keyLineBuffered) && lineBuffered {
+			return 0
+		}
+		return interval
 	}
 
-	// Process skip patterns
-	skipFlags := viper.GetStringSlice(keySkip)
+	if lineBuffered {
+		return 0
+	}
+	return formatter.FlushWhenFull
+}
+
+// parseSkipPatterns returns the skip patterns from config, warning about any
+// that are malformed
+func parseSkipPatterns() []formatter.SkipPattern {
 	var skipPatterns []formatter.SkipPattern
 
-	for _, skipFlag := range skipFlags {
+	for _, skipFlag := range viper.GetStringSlice(keySkip) {
 		parts := strings.SplitN(skipFlag, "=", 2)
 		if len(parts) == 2 {
+			// key==value matches the value exactly, key=value as a substring
+			value, exact := strings.CutPrefix(parts[1], "=")
 			skipPatterns = append(skipPatterns, formatter.SkipPattern{
 				Field: parts[0],
-				Value: parts[1],
+				Value: value,
+				Exact: exact,
 			})
 		} else {
-			fmt.Fprintf(os.Stderr, "Warning: invalid skip pattern format (expected key=value): %s\n", skipFlag)
+			diagnostics.Warnf("invalid skip pattern format (expected key=value or key==value): %s", skipFlag)
 		}
 	}
 
-	// Get the handleNonJSON flag value
-	handleNonJSON := viper.GetBool(keyHandleNonJSON)
+	return skipPatterns
+}
 
-	return tmplFormatter.ProcessStream(os.Stdin, os.Stdout, tmplFormatter, skipPatterns, handleNonJSON)
+// templateFlag returns the value of a template flag, such as --format. Go
+// escapes in the text of a value given on the command line are interpreted,
+// unless --raw_format is set; values from config files, the environment or
+// stdin are used as written.
+func templateFlag(key string) (string, error) {
+	value := viper.GetString(key)
+	flag := commandLine.PersistentFlags().Lookup(key)
+	if viper.GetBool(keyRawFormat) || !flag.Changed || flag.Value.String() != value {
+		return value, nil
+	}
+
+	unescaped, err := unescapeTemplateText(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid escape in --%s %q: use \\\\ for a backslash, or --%s to keep backslashes as they are", key, value, keyRawFormat)
+	}
+	return unescaped, nil
+}
+
+// unescapeTemplateText interprets Go escapes, such as \t and \n, in the text
+// of a template, so separators such as tabs can be typed in a shell. Actions,
+// in {field} or {{...}}, are left as they are, since their strings have
+// escapes of their own.
+func unescapeTemplateText(format string) (string, error) {
+	if !strings.Contains(format, `\`) {
+		return format, nil
+	}
+
+	var b strings.Builder
+	depth := 0
+	var quote byte
+
+	// text is where the text since the last action starts
+	text := 0
+	writeText := func(end int) error {
+		unescaped, err := unescapeGo(format[text:end])
+		b.WriteString(unescaped)
+		return err
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case quote != 0:
+			// Skip over strings within actions, which may contain braces
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case depth > 0 && (c == '"' || c == '`'):
+			quote = c
+		case c == '{':
+			if depth == 0 {
+				if err := writeText(i); err != nil {
+					return "", err
+				}
+				text = i
+			}
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+			if depth == 0 {
+				b.WriteString(format[text : i+1])
+				text = i + 1
+			}
+		}
+	}
+
+	// An action that isn't closed is left for the template parser to report
+	if depth > 0 {
+		b.WriteString(format[text:])
+	} else if err := writeText(len(format)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// unescapeGo interprets the escapes of Go string literals in s, such as \t,
+// \x00 and \u00e9, returning an error for a backslash that doesn't start one
+func unescapeGo(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for s != "" {
+		value, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return "", err
+		}
+		if multibyte {
+			b.WriteRune(value)
+		} else {
+			b.WriteByte(byte(value))
+		}
+		s = tail
+	}
+	return b.String(), nil
+}
+
+// parseRecordDelimiter returns the --record_delimiter, with Go escapes such
+// as \x00 and \t interpreted. \0 is accepted as a short form of \x00.
+func parseRecordDelimiter() (string, error) {
+	delim := viper.GetString(keyRecordDelim)
+	if delim == "" {
+		return "", nil
+	}
+	if delim == `\0` {
+		return "\x00", nil
+	}
+
+	unquoted, err := unescapeGo(delim)
+	if err != nil {
+		return "", fmt.Errorf("invalid --%s %q: use a string with Go escapes such as \\x00", keyRecordDelim, delim)
+	}
+	return unquoted, nil
+}
+
+// parseHighlight compiles the --highlight pattern, matching case-insensitively,
+// returning nil if there isn't one
+func parseHighlight() (*regexp.Regexp, error) {
+	pattern := viper.GetString(keyHighlight)
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s pattern: %w", keyHighlight, err)
+	}
+	return re, nil
+}
+
+// parseSampling returns the formatter options for --sample and --sample_rate
+func parseSampling() ([]formatter.FormatterOption, error) {
+	every := viper.GetInt(keySample)
+	if every < 0 {
+		return nil, fmt.Errorf("--%s must not be negative", keySample)
+	}
+	options := []formatter.FormatterOption{formatter.WithSample(every)}
+
+	rate := viper.GetFloat64(keySampleRate)
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("--%s must be between 0 and 1", keySampleRate)
+	}
+	if rate > 0 {
+		options = append(options, formatter.WithSampleRate(rate, viper.GetUint64(keySampleSeed)))
+	}
+	return options, nil
+}
+
+// parseSmartFields returns the smart field formatting rules from config,
+// keyed by suffix, warning about any that are malformed
+func parseSmartFields() map[string]string {
+	rules := make(map[string]string)
+
+	for _, rule := range viper.GetStringSlice(keySmartFields) {
+		suffix, format, ok := strings.Cut(rule, "=")
+		if !ok {
+			diagnostics.Warnf("invalid smart field format (expected suffix=format): %s", rule)
+			continue
+		}
+		rules[suffix] = format
+	}
+
+	return rules
+}
+
+// parseDerivedFields returns the fields computed by --set, warning about any
+// that are malformed
+func parseDerivedFields() []formatter.DerivedField {
+	var fields []formatter.DerivedField
+
+	for _, flag := range viper.GetStringSlice(keySet) {
+		name, tmpl, ok := strings.Cut(flag, "=")
+		if ok && name != "" {
+			fields = append(fields, formatter.DerivedField{Name: name, Template: tmpl})
+		} else {
+			diagnostics.Warnf("invalid set format (expected name=template): %s", flag)
+		}
+	}
+
+	return fields
+}
+
+// parseFieldCommands returns the field commands given by --exec_field,
+// warning about any that are malformed. They are deliberately read from the
+// command line only, and never from config files or the environment, so that
+// a config file in the current directory can't run arbitrary commands.
+func parseFieldCommands() []formatter.FieldCommand {
+	var commands []formatter.FieldCommand
+
+	for _, flag := range execFields {
+		parts := strings.SplitN(flag, "=", 2)
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			commands = append(commands, formatter.FieldCommand{
+				Field:   parts[0],
+				Command: parts[1],
+			})
+		} else {
+			diagnostics.Warnf("invalid exec field format (expected name=command): %s", flag)
+		}
+	}
+
+	return commands
+}
+
+// readFormatFile reads a format template from a file, dropping the trailing
+// newline most editors add since each record is already newline-terminated
+func readFormatFile(path string) (string, error) {
+	content, err := os.ReadFile(path) //nolint:gosec // Reading a user-specified template file is intended
+	if err != nil {
+		return "", fmt.Errorf("failed to read format file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// explainConfig writes the preprocessed template and the effective options to w
+func explainConfig(w io.Writer, format string, preprocessOptions formatter.PreProcessTemplateOptions, skipPatterns []formatter.SkipPattern) {
+	if name := viper.GetString(keyTemplateName); name != "" {
+		fmt.Fprintf(w, "Template name: %s\n", name)
+	} else if preset := viper.GetString(keyPreset); preset != "" {
+		fmt.Fprintf(w, "Preset: %s\n", preset)
+	}
+	fmt.Fprintln(w, "Template:")
+	fmt.Fprintf(w, "  %s\n", format)
+	fmt.Fprintln(w, "Preprocessed template:")
+	fmt.Fprintf(w, "  %s\n", formatter.PreProcessTemplate(format, preprocessOptions))
+	fmt.Fprintln(w, "Options:")
+	fmt.Fprintf(w, "  %s: %q\n", keyDateFormat, viper.GetString(keyDateFormat))
+	fmt.Fprintf(w, "  %s: %t\n", keyNoColors, viper.GetBool(keyNoColors))
+	if depth := viper.GetString(keyColorDepth); depth != formatter.ColorDepthAuto {
+		fmt.Fprintf(w, "  %s: %s\n", keyColorDepth, depth)
+	}
+	if aliases := viper.GetStringMapStringSlice(keyStyles); len(aliases) > 0 {
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s.%s: %s\n", keyStyles, name, strings.Join(aliases[name], ", "))
+		}
+	}
+	fmt.Fprintf(w, "  %s: %s\n", keyMapSort, viper.GetString(keyMapSort))
+	if rules := viper.GetStringSlice(keySmartFields); len(rules) > 0 {
+		fmt.Fprintf(w, "  %s: %s\n", keySmartFields, strings.Join(rules, ", "))
+	}
+	if depth := viper.GetInt(keyPrettyMaxDepth); depth > 0 {
+		fmt.Fprintf(w, "  %s: %d\n", keyPrettyMaxDepth, depth)
+	}
+	if indent := viper.GetInt(keyIndent); indent != formatter.DefaultIndent {
+		fmt.Fprintf(w, "  %s: %d\n", keyIndent, indent)
+	}
+	if normalize := viper.GetString(keyNormalizeKeys); normalize != "" {
+		fmt.Fprintf(w, "  %s: %s\n", keyNormalizeKeys, normalize)
+	}
+	if fields := viper.GetStringSlice(keyLift); len(fields) > 0 {
+		fmt.Fprintf(w, "  %s: %s\n", keyLift, strings.Join(fields, ", "))
+		if viper.GetBool(keyLiftOverwrite) {
+			fmt.Fprintf(w, "  %s: true\n", keyLiftOverwrite)
+		}
+	}
+	if field := viper.GetString(keyNormalizeLevel); field != "" {
+		fmt.Fprintf(w, "  %s: %s\n", keyNormalizeLevel, field)
+	}
+	if layout := viper.GetString(keyNormalizeTime); layout != "" {
+		fmt.Fprintf(w, "  %s: %q\n", keyNormalizeTime, layout)
+	}
+	if maxLen := viper.GetInt(keyMaxFieldLength); maxLen > 0 {
+		fmt.Fprintf(w, "  %s: %d\n", keyMaxFieldLength, maxLen)
+	}
+	if viper.GetBool(keyEscapeNewlines) {
+		fmt.Fprintf(w, "  %s: true\n", keyEscapeNewlines)
+	}
+	if field := viper.GetString(keyCountBy); field != "" {
+		fmt.Fprintf(w, "  %s: %s\n", keyCountBy, field)
+	}
+	if columns := viper.GetStringSlice(keyColumns); len(columns) > 0 {
+		fmt.Fprintf(w, "  %s: %s (in batches of %d)\n", keyColumns, strings.Join(columns, ", "), viper.GetInt(keyColumnsBatch))
+	}
+	if field := viper.GetString(keyGroupBy); field != "" {
+		fmt.Fprintf(w, "  %s: %s\n", keyGroupBy, field)
+	}
+	if fields := viper.GetStringSlice(keyUniqueBy); len(fields) > 0 {
+		fmt.Fprintf(w, "  %s: %s\n", keyUniqueBy, strings.Join(fields, ", "))
+		if n := viper.GetInt(keyUniqueCap); n > 0 {
+			fmt.Fprintf(w, "  %s: %d\n", keyUniqueCap, n)
+		}
+	}
+	if n := viper.GetInt(keySample); n > 1 {
+		fmt.Fprintf(w, "  %s: 1 in %d\n", keySample, n)
+	}
+	if rate := viper.GetFloat64(keySampleRate); rate > 0 {
+		fmt.Fprintf(w, "  %s: %g\n", keySampleRate, rate)
+	}
+	if n := viper.GetInt(keyHead); n > 0 {
+		fmt.Fprintf(w, "  %s: %d\n", keyHead, n)
+	}
+	if n := viper.GetInt(keyTail); n > 0 {
+		fmt.Fprintf(w, "  %s: %d\n", keyTail, n)
+	}
+	if level := viper.GetString(keySplitStreams); level != "" {
+		fmt.Fprintf(w, "  %s: %s and above to stderr\n", keySplitStreams, level)
+	}
+	if speed := viper.GetFloat64(keyReplay); speed > 0 {
+		fmt.Fprintf(w, "  %s: %gx (max gap %s)\n", keyReplay, speed, viper.GetDuration(keyReplayMaxGap))
+	}
+	if spec := viper.GetString(keySplit); spec != "" {
+		fmt.Fprintf(w, "  %s: %s (in %s)\n", keySplit, spec, viper.GetString(keyOutputDir))
+	}
+	if viper.IsSet(keyKVSeparator) {
+		fmt.Fprintf(w, "  %s: %q\n", keyKVSeparator, viper.GetString(keyKVSeparator))
+	}
+	if viper.GetBool(keySingleLine) {
+		fmt.Fprintf(w, "  %s: %q\n", keySingleLineSep, viper.GetString(keySingleLineSep))
+	}
+	fmt.Fprintf(w, "  %s: %t\n", keyStrictEquality, viper.GetBool(keyStrictEquality))
+	if viper.GetBool(keyRoundDurations) {
+		fmt.Fprintf(w, "  %s: true\n", keyRoundDurations)
+	}
+	if style := viper.GetString(keyDurationStyle); style != formatter.DurationStyleShort {
+		fmt.Fprintf(w, "  %s: %s\n", keyDurationStyle, style)
+	}
+	fmt.Fprintf(w, "  %s: %t\n", keyEnableSimple, preprocessOptions.EnableSimpleSyntax)
+	fmt.Fprintf(w, "  %s: %s\n", keyInputFormat, viper.GetString(keyInputFormat))
+	if delim, err := parseRecordDelimiter(); err == nil && delim != "" {
+		fmt.Fprintf(w, "  %s: %q\n", keyRecordDelim, delim)
+	}
+	fmt.Fprintf(w, "  %s: %t\n", keyHandleNonJSON, viper.GetBool(keyHandleNonJSON))
+	fmt.Fprintf(w, "  %s: %t\n", keyCompactNonJSON, viper.GetBool(keyCompactNonJSON))
+	if comments := viper.GetString(keyComments); comments != "" {
+		fmt.Fprintf(w, "  %s: %s (prefix %q)\n", keyComments, comments, viper.GetString(keyCommentPrefix))
+	}
+	if commands := parseFieldCommands(); len(commands) > 0 {
+		fmt.Fprintf(w, "  %s (timeout %s):\n", keyExecField, execTimeout)
+		for _, command := range commands {
+			fmt.Fprintf(w, "    %s=%s\n", command.Field, command.Command)
+		}
+	}
+	if fields := parseDerivedFields(); len(fields) > 0 {
+		fmt.Fprintf(w, "  %s:\n", keySet)
+		for _, field := range fields {
+			fmt.Fprintf(w, "    %s=%s\n", field.Name, field.Template)
+		}
+	}
+	if pattern := viper.GetString(keyHighlight); pattern != "" {
+		fmt.Fprintf(w, "  %s: %s\n", keyHighlight, pattern)
+	}
+	if prefix := viper.GetString(keyPrefix); prefix != "" {
+		fmt.Fprintf(w, "  %s: %q\n", keyPrefix, prefix)
+	}
+	if suffix := viper.GetString(keySuffix); suffix != "" {
+		fmt.Fprintf(w, "  %s: %q\n", keySuffix, suffix)
+	}
+	if len(skipPatterns) == 0 {
+		fmt.Fprintf(w, "  %s: none\n", keySkip)
+	} else {
+		fmt.Fprintf(w, "  %s:\n", keySkip)
+		for _, pattern := range skipPatterns {
+			operator := "="
+			if pattern.Exact {
+				operator = "=="
+			}
+			fmt.Fprintf(w, "    %s%s%s\n", pattern.Field, operator, pattern.Value)
+		}
+	}
 }
 
 // Execute runs the root command
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dpup/logista/internal/formatter"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoadFormatTemplate(t *testing.T) {
+	templates := map[string]interface{}{
+		"grpc": "{grpc.method} {grpc.code}",
+		"http": "{method} {path} {status}",
+	}
+
+	tests := []struct {
+		name         string
+		templateName string
+		format       string
+		configFormat string
+		preset       string
+		levelBadges  bool
+		rawFormat    bool
+		templates    map[string]interface{}
+		expected     string
+		wantErr      bool
+	}{
+		{
+			name:         "selects a named template",
+			templateName: "grpc",
+			templates:    templates,
+			expected:     "{grpc.method} {grpc.code}",
+		},
+		{
+			name:         "names are case-insensitive",
+			templateName: "HTTP",
+			templates:    templates,
+			expected:     "{method} {path} {status}",
+		},
+		{
+			name:         "named template takes precedence over format and preset",
+			templateName: "http",
+			format:       "{message}",
+			preset:       "zap",
+			templates:    templates,
+			expected:     "{method} {path} {status}",
+		},
+		{
+			name:      "falls back to format without a name",
+			format:    "{message}",
+			templates: templates,
+			expected:  "{message}",
+		},
+		{
+			name:        "level badges in the default format",
+			levelBadges: true,
+			expected:    badgeFormat,
+		},
+		{
+			name:        "level badges don't replace a format",
+			format:      "{message}",
+			levelBadges: true,
+			expected:    "{message}",
+		},
+		{
+			name:     "interprets escapes in the format",
+			format:   `{time}\t{level}\t{message}\n{stack}`,
+			expected: "{time}\t{level}\t{message}\n{stack}",
+		},
+		{
+			name:         "formats from config are used as written",
+			configFormat: `{time}\t{message}`,
+			expected:     `{time}\t{message}`,
+		},
+		{
+			name:    "invalid escape in the format",
+			format:  `{time}\d{message}`,
+			wantErr: true,
+		},
+		{
+			name:      "raw format keeps escapes",
+			format:    `{time}\t{message}`,
+			rawFormat: true,
+			expected:  `{time}\t{message}`,
+		},
+		{
+			name:         "named templates are used as written",
+			templateName: "tabs",
+			templates:    map[string]interface{}{"tabs": `{method}\t{path}`},
+			expected:     `{method}\t{path}`,
+		},
+		{
+			name:         "unknown name",
+			templateName: "syslog",
+			templates:    templates,
+			wantErr:      true,
+		},
+		{
+			name:         "no templates defined",
+			templateName: "grpc",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(keyTemplateName, tt.templateName)
+			viper.Set(keyPreset, tt.preset)
+			viper.Set(keyLevelBadges, tt.levelBadges)
+			viper.Set(keyRawFormat, tt.rawFormat)
+			if tt.format != "" {
+				setCommandLineFlag(t, keyFormat, tt.format)
+			}
+			if tt.configFormat != "" {
+				viper.Set(keyFormat, tt.configFormat)
+			}
+			if tt.templates != nil {
+				viper.Set(keyTemplates, tt.templates)
+			}
+
+			format, err := loadFormatTemplate()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", format)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadFormatTemplate failed: %v", err)
+			}
+			if format != tt.expected {
+				t.Errorf("Expected format %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
+
+// setCommandLineFlag sets a flag, and viper's value for it, as if it was
+// given on the command line
+func setCommandLineFlag(t *testing.T, key, value string) {
+	t.Helper()
+	flag := rootCmd.PersistentFlags().Lookup(key)
+	if err := flag.Value.Set(value); err != nil {
+		t.Fatalf("Failed to set --%s: %v", key, err)
+	}
+	flag.Changed = true
+	viper.Set(key, value)
+	t.Cleanup(func() {
+		_ = flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+}
+
+func TestWithSchemaDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		auto     bool
+	}{
+		{
+			name: "default template",
+			auto: true,
+		},
+		{
+			name:     "disabled",
+			settings: map[string]interface{}{keyNoAuto: true},
+		},
+		{
+			name:     "format",
+			settings: map[string]interface{}{keyFormat: "{message}"},
+		},
+		{
+			name:     "preset",
+			settings: map[string]interface{}{keyPreset: "zap"},
+		},
+		{
+			name:     "level badges",
+			settings: map[string]interface{}{keyLevelBadges: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			for key, value := range tt.settings {
+				viper.Set(key, value)
+			}
+
+			tf, err := formatter.NewTemplateFormatter("{message}")
+			if err != nil {
+				t.Fatalf("Failed to create formatter: %v", err)
+			}
+			_, auto := withSchemaDetection(tf).(*formatter.AutoFormatter)
+			if auto != tt.auto {
+				t.Errorf("Expected schema detection %v, got %v", tt.auto, auto)
+			}
+		})
+	}
+}
+
+func TestUnescapeTemplateText(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+		wantErr  bool
+	}{
+		{format: "{message}", expected: "{message}"},
+		{format: `{a}\t{b}`, expected: "{a}\t{b}"},
+		{format: `{a}\n\r\\`, expected: "{a}\n\r\\"},
+		{format: `\x1e{a}\u00e9`, expected: "\x1e{a}\u00e9"},
+		{format: `C:\\temp\\q {a}`, expected: `C:\temp\q {a}`},
+		{format: `{a | default "\t"}\t`, expected: "{a | default \"\\t\"}\t"},
+		{format: `{{if eq .a "}\n"}}\n{{end}}`, expected: "{{if eq .a \"}\\n\"}}\n{{end}}"},
+		{format: `{a | default "x\"}"}\t`, expected: "{a | default \"x\\\"}\"}\t"},
+		{format: `{a}\t{b`, expected: "{a}\t{b"},
+		{format: `C:\temp\q {a}`, wantErr: true},
+		{format: `trailing\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := unescapeTemplateText(tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseRecordDelimiter(t *testing.T) {
+	tests := []struct {
+		flag     string
+		expected string
+		wantErr  bool
+	}{
+		{flag: "", expected: ""},
+		{flag: `\0`, expected: "\x00"},
+		{flag: `\x00`, expected: "\x00"},
+		{flag: `\x1e`, expected: "\x1e"},
+		{flag: `\n---\n`, expected: "\n---\n"},
+		{flag: `"|"`, expected: `"|"`},
+		{flag: "||", expected: "||"},
+		{flag: `\q`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(keyRecordDelim, tt.flag)
+
+			delim, err := parseRecordDelimiter()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", delim)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if delim != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, delim)
+			}
+		})
+	}
+}
+
+func TestParseSkipPatterns(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set(keySkip, []string{"level=debug", "status==200", "msg=a==b", "arrow==>", "invalid"})
+
+	expected := []formatter.SkipPattern{
+		{Field: "level", Value: "debug"},
+		{Field: "status", Value: "200", Exact: true},
+		{Field: "msg", Value: "a==b"},
+		{Field: "arrow", Value: ">", Exact: true},
+	}
+	if patterns := parseSkipPatterns(); !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, patterns)
+	}
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	writeFile := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		config   string
+		xdgHome  bool
+		noHome   bool
+		expected string
+		loaded   []string
+	}{
+		{
+			name:     "yaml in home",
+			files:    map[string]string{"home/.logista.yaml": "format: home"},
+			expected: "home",
+			loaded:   []string{"home/.logista.yaml"},
+		},
+		{
+			name: "home before XDG",
+			files: map[string]string{
+				"home/.logista.yaml":               "format: home",
+				"home/.config/logista/config.yaml": "format: xdg",
+			},
+			expected: "home",
+			loaded:   []string{"home/.logista.yaml"},
+		},
+		{
+			name: "XDG default with overlay",
+			files: map[string]string{
+				"home/.config/logista/config.json":       `{"format": "xdg", "date_format": "15:04"}`,
+				"home/.config/logista/config.local.json": `{"format": "local"}`,
+			},
+			expected: "local",
+			loaded:   []string{"home/.config/logista/config.json", "home/.config/logista/config.local.json"},
+		},
+		{
+			name:     "XDG_CONFIG_HOME",
+			files:    map[string]string{"xdg/logista/config.toml": `format = "xdg"`},
+			xdgHome:  true,
+			expected: "xdg",
+			loaded:   []string{"xdg/logista/config.toml"},
+		},
+		{
+			name:     "current directory",
+			files:    map[string]string{"work/.logista.yml": "format: cwd"},
+			expected: "cwd",
+			loaded:   []string{".logista.yml"},
+		},
+		{
+			name:     "without a home directory",
+			files:    map[string]string{"home/.logista.yaml": "format: home", "work/.logista.yaml": "format: cwd"},
+			noHome:   true,
+			expected: "cwd",
+			loaded:   []string{".logista.yaml"},
+		},
+		{
+			name:     "XDG_CONFIG_HOME without a home directory",
+			files:    map[string]string{"xdg/logista/config.yaml": "format: xdg"},
+			xdgHome:  true,
+			noHome:   true,
+			expected: "xdg",
+			loaded:   []string{"xdg/logista/config.yaml"},
+		},
+		{
+			name:     "--config without a home directory",
+			files:    map[string]string{"team.yaml": "format: team"},
+			config:   "team.yaml",
+			noHome:   true,
+			expected: "team",
+			loaded:   []string{"team.yaml"},
+		},
+		{
+			name:     "--config in any format",
+			files:    map[string]string{"team.toml": `format = "team"`, "team.local.toml": `date_format = "15:04"`},
+			config:   "team.toml",
+			expected: "team",
+			loaded:   []string{"team.toml", "team.local.toml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+
+			dir := t.TempDir()
+			for path, content := range tt.files {
+				writeFile(t, filepath.Join(dir, path), content)
+			}
+			if err := os.MkdirAll(filepath.Join(dir, "work"), 0o750); err != nil {
+				t.Fatal(err)
+			}
+			t.Chdir(filepath.Join(dir, "work"))
+			t.Setenv("HOME", filepath.Join(dir, "home"))
+			t.Setenv("XDG_CONFIG_HOME", "")
+			t.Setenv(envConfigDir, "")
+			if tt.xdgHome {
+				t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+			}
+			if tt.noHome {
+				t.Setenv("HOME", "")
+			}
+
+			previous := cfgFile
+			t.Cleanup(func() { cfgFile = previous })
+			cfgFile = ""
+			if tt.config != "" {
+				cfgFile = filepath.Join(dir, tt.config)
+			}
+
+			// Files found in the current directory are loaded by relative path
+			var loaded []string
+			for _, path := range loadConfigFiles() {
+				if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsAbs(path) {
+					path = rel
+				}
+				loaded = append(loaded, path)
+			}
+			if !reflect.DeepEqual(loaded, tt.loaded) {
+				t.Errorf("Expected files %v, got %v", tt.loaded, loaded)
+			}
+			if format := viper.GetString(keyFormat); format != tt.expected {
+				t.Errorf("Expected format %q, got %q", tt.expected, format)
+			}
+		})
+	}
+}
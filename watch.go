@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait after the last change event before
+// notifying, since editors often write a file in several steps
+const watchDebounce = 100 * time.Millisecond
+
+// watchFile calls onChange whenever the file at path is written, created or
+// replaced. The parent directory is watched rather than the file itself so
+// that editors which save by renaming a temporary file are still detected.
+// The returned function stops watching.
+func watchFile(path string, onChange func()) (func() error, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != absPath {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				diagnostics.Warnf("error watching %s: %v", path, err)
+			}
+		}
+	}()
+
+	return watcher.Close, nil
+}